@@ -0,0 +1,499 @@
+package triplestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrCanonicalizationLimit is returned when canonicalizing needs more calls
+// of the Hash N-Degree Quads algorithm than allowed. Datasets crafted with
+// many undistinguishable blank nodes need an exponential number of them.
+var ErrCanonicalizationLimit = errors.New("triplestore: canonicalization call limit exceeded")
+
+// DefaultCanonicalizationLimit is the number of calls of the Hash N-Degree
+// Quads algorithm allowed when canonicalizing without an explicit limit, as
+// RDFC-1.0 recommends bounding the work on any input
+const DefaultCanonicalizationLimit = 10000
+
+// CanonicalizeQuads relabels the blank nodes of the quads, including blank
+// graph names, following the W3C RDF Dataset Canonicalization (RDFC-1.0).
+// Isomorphic datasets give the same quads, sorted in canonical N-Quads order.
+// It fails with ErrCanonicalizationLimit past DefaultCanonicalizationLimit.
+func CanonicalizeQuads(quads []Quad) ([]Quad, error) {
+	return CanonicalizeQuadsWithLimit(quads, DefaultCanonicalizationLimit)
+}
+
+// CanonicalizeQuadsWithLimit is like CanonicalizeQuads but fails with
+// ErrCanonicalizationLimit past the given number of calls of the Hash
+// N-Degree Quads algorithm. A negative limit means no limit.
+func CanonicalizeQuadsWithLimit(quads []Quad, maxCalls int) ([]Quad, error) {
+	c := newCanonicalizer(quads)
+	c.maxCalls = maxCalls
+	if err := c.run(); err != nil {
+		return nil, err
+	}
+
+	type canonicalQuad struct {
+		q    Quad
+		line string
+	}
+	var out []canonicalQuad
+	for _, q := range c.quads {
+		t := q.Triple().(*triple)
+		relabelled := &triple{sub: t.sub, isSubBnode: t.isSubBnode, pred: t.pred, obj: t.obj}
+		if t.isSubBnode {
+			relabelled.sub = c.canonical.ids[t.sub]
+		}
+		if t.obj.isBnode {
+			relabelled.obj.bnode = c.canonical.ids[t.obj.bnode]
+		}
+		graph := q.Graph()
+		if strings.HasPrefix(graph, "_:") {
+			graph = "_:" + c.canonical.ids[graph[2:]]
+		}
+		line := canonicalNQuad(relabelled, graph, verbatim)
+		out = append(out, canonicalQuad{q: NewQuad(relabelled, graph), line: line})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].line < out[j].line })
+
+	quads = make([]Quad, len(out))
+	for i, cq := range out {
+		quads[i] = cq.q
+	}
+	return quads, nil
+}
+
+// Canonicalize relabels the blank nodes of the triples following RDFC-1.0,
+// failing as CanonicalizeQuads does
+func Canonicalize(tris []Triple) ([]Triple, error) {
+	return CanonicalizeWithLimit(tris, DefaultCanonicalizationLimit)
+}
+
+// CanonicalizeWithLimit is like Canonicalize but fails with
+// ErrCanonicalizationLimit as CanonicalizeQuadsWithLimit does
+func CanonicalizeWithLimit(tris []Triple, maxCalls int) ([]Triple, error) {
+	quads := make([]Quad, len(tris))
+	for i, t := range tris {
+		quads[i] = NewQuad(t, DefaultGraph)
+	}
+	quads, err := CanonicalizeQuadsWithLimit(quads, maxCalls)
+	if err != nil {
+		return nil, err
+	}
+	var out []Triple
+	for _, q := range quads {
+		out = append(out, q.Triple())
+	}
+	return out, nil
+}
+
+// CanonicalNQuads returns the canonical N-Quads document of the quads
+func CanonicalNQuads(quads []Quad) (string, error) {
+	quads, err := CanonicalizeQuads(quads)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, q := range quads {
+		buf.WriteString(canonicalNQuad(q.Triple().(*triple), q.Graph(), verbatim))
+	}
+	return buf.String(), nil
+}
+
+// CanonicalNTriples returns the canonical N-Triples document of the triples
+func CanonicalNTriples(tris []Triple) (string, error) {
+	tris, err := Canonicalize(tris)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	for _, t := range tris {
+		buf.WriteString(canonicalNQuad(t.(*triple), DefaultGraph, verbatim))
+	}
+	return buf.String(), nil
+}
+
+// GraphHash returns the hexadecimal SHA-256 of the canonical N-Triples of the
+// graph. Graphs differing only by their blank node labels have the same hash.
+func GraphHash(g RDFGraph) (string, error) {
+	nt, err := CanonicalNTriples(g.Triples())
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(nt), nil
+}
+
+// DatasetHash returns the hexadecimal SHA-256 of the canonical N-Quads of the dataset
+func DatasetHash(d Dataset) (string, error) {
+	nq, err := CanonicalNQuads(d.Quads())
+	if err != nil {
+		return "", err
+	}
+	return sha256Hex(nq), nil
+}
+
+// Isomorphic returns true when both triples sets are equal up to blank node labels
+func (ts Triples) Isomorphic(others Triples) (bool, error) {
+	nt, err := CanonicalNTriples(ts)
+	if err != nil {
+		return false, err
+	}
+	otherNT, err := CanonicalNTriples(others)
+	if err != nil {
+		return false, err
+	}
+	return nt == otherNT, nil
+}
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// identifierIssuer issues identifiers for blank nodes, in order
+type identifierIssuer struct {
+	prefix  string
+	counter int
+	ids     map[string]string
+	order   []string
+}
+
+func newIdentifierIssuer(prefix string) *identifierIssuer {
+	return &identifierIssuer{prefix: prefix, ids: make(map[string]string)}
+}
+
+func (is *identifierIssuer) issue(bnode string) string {
+	if id, ok := is.ids[bnode]; ok {
+		return id
+	}
+	id := fmt.Sprintf("%s%d", is.prefix, is.counter)
+	is.counter++
+	is.ids[bnode] = id
+	is.order = append(is.order, bnode)
+	return id
+}
+
+func (is *identifierIssuer) copy() *identifierIssuer {
+	c := &identifierIssuer{prefix: is.prefix, counter: is.counter, ids: make(map[string]string, len(is.ids))}
+	for k, v := range is.ids {
+		c.ids[k] = v
+	}
+	c.order = append([]string{}, is.order...)
+	return c
+}
+
+type canonicalizer struct {
+	quads        []Quad
+	bnodeToQuads map[string][]Quad
+	firstDegree  map[string]string
+	canonical    *identifierIssuer
+	// calls of hashNDegreeQuads, failing past maxCalls unless negative
+	calls, maxCalls int
+}
+
+func newCanonicalizer(quads []Quad) *canonicalizer {
+	c := &canonicalizer{
+		bnodeToQuads: make(map[string][]Quad),
+		firstDegree:  make(map[string]string),
+		canonical:    newIdentifierIssuer("c14n"),
+	}
+	seen := make(map[string]bool)
+	for _, q := range quads {
+		k := q.Triple().(*triple).key() + " " + q.Graph()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		c.quads = append(c.quads, q)
+		for _, bnode := range quadBnodes(q) {
+			c.bnodeToQuads[bnode] = append(c.bnodeToQuads[bnode], q)
+		}
+	}
+	return c
+}
+
+// quadBnodes returns the blank nodes of the subject, object and graph of the quad
+func quadBnodes(q Quad) (bnodes []string) {
+	t := q.Triple().(*triple)
+	if t.isSubBnode {
+		bnodes = append(bnodes, t.sub)
+	}
+	if t.obj.isBnode && !(t.isSubBnode && t.obj.bnode == t.sub) {
+		bnodes = append(bnodes, t.obj.bnode)
+	}
+	if g := q.Graph(); strings.HasPrefix(g, "_:") {
+		if l := g[2:]; !(t.isSubBnode && l == t.sub) && !(t.obj.isBnode && l == t.obj.bnode) {
+			bnodes = append(bnodes, l)
+		}
+	}
+	return
+}
+
+func (c *canonicalizer) run() error {
+	hashToBnodes := make(map[string][]string)
+	for bnode := range c.bnodeToQuads {
+		h := c.hashFirstDegreeQuads(bnode)
+		hashToBnodes[h] = append(hashToBnodes[h], bnode)
+	}
+
+	var hashes []string
+	for h := range hashToBnodes {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	var shared []string
+	for _, h := range hashes {
+		if len(hashToBnodes[h]) > 1 {
+			shared = append(shared, h)
+			continue
+		}
+		c.canonical.issue(hashToBnodes[h][0])
+	}
+
+	for _, h := range shared {
+		type result struct {
+			hash   string
+			issuer *identifierIssuer
+		}
+		var results []result
+		bnodes := hashToBnodes[h]
+		sort.Strings(bnodes)
+		for _, bnode := range bnodes {
+			if _, ok := c.canonical.ids[bnode]; ok {
+				continue
+			}
+			issuer := newIdentifierIssuer("b")
+			issuer.issue(bnode)
+			hash, issuer := c.hashNDegreeQuads(bnode, issuer)
+			if c.exceeded() {
+				return ErrCanonicalizationLimit
+			}
+			results = append(results, result{hash: hash, issuer: issuer})
+		}
+		sort.SliceStable(results, func(i, j int) bool { return results[i].hash < results[j].hash })
+		for _, r := range results {
+			for _, bnode := range r.issuer.order {
+				c.canonical.issue(bnode)
+			}
+		}
+	}
+	return nil
+}
+
+// exceeded tells whether hashNDegreeQuads was called more than allowed
+func (c *canonicalizer) exceeded() bool {
+	return c.maxCalls >= 0 && c.calls > c.maxCalls
+}
+
+func (c *canonicalizer) hashFirstDegreeQuads(bnode string) string {
+	if h, ok := c.firstDegree[bnode]; ok {
+		return h
+	}
+	var lines []string
+	for _, q := range c.bnodeToQuads[bnode] {
+		lines = append(lines, canonicalNQuad(q.Triple().(*triple), q.Graph(), func(label string) string {
+			if label == bnode {
+				return "a"
+			}
+			return "z"
+		}))
+	}
+	sort.Strings(lines)
+	h := sha256Hex(strings.Join(lines, ""))
+	c.firstDegree[bnode] = h
+	return h
+}
+
+func (c *canonicalizer) hashRelatedBnode(related string, q Quad, issuer *identifierIssuer, position string) string {
+	input := position
+	if position != "g" {
+		input += "<" + q.Triple().Predicate() + ">"
+	}
+	if id, ok := c.canonical.ids[related]; ok {
+		input += "_:" + id
+	} else if id, ok := issuer.ids[related]; ok {
+		input += "_:" + id
+	} else {
+		input += c.hashFirstDegreeQuads(related)
+	}
+	return sha256Hex(input)
+}
+
+// hashNDegreeQuads returns early once the call limit is exceeded
+func (c *canonicalizer) hashNDegreeQuads(bnode string, issuer *identifierIssuer) (string, *identifierIssuer) {
+	if c.calls++; c.exceeded() {
+		return "", issuer
+	}
+	relatedByHash := make(map[string][]string)
+	for _, q := range c.bnodeToQuads[bnode] {
+		t := q.Triple().(*triple)
+		add := func(related, position string) {
+			h := c.hashRelatedBnode(related, q, issuer, position)
+			relatedByHash[h] = append(relatedByHash[h], related)
+		}
+		if t.isSubBnode && t.sub != bnode {
+			add(t.sub, "s")
+		}
+		if t.obj.isBnode && t.obj.bnode != bnode {
+			add(t.obj.bnode, "o")
+		}
+		if g := q.Graph(); strings.HasPrefix(g, "_:") && g[2:] != bnode {
+			add(g[2:], "g")
+		}
+	}
+
+	var hashes []string
+	for h := range relatedByHash {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	var data bytes.Buffer
+	for _, h := range hashes {
+		data.WriteString(h)
+		var chosenPath string
+		var chosenIssuer *identifierIssuer
+
+		permute(relatedByHash[h], func(permutation []string) bool {
+			issuerCopy := issuer.copy()
+			var path string
+			var recursion []string
+			for _, related := range permutation {
+				if id, ok := c.canonical.ids[related]; ok {
+					path += "_:" + id
+				} else {
+					if _, ok := issuerCopy.ids[related]; !ok {
+						recursion = append(recursion, related)
+					}
+					path += "_:" + issuerCopy.issue(related)
+				}
+				if chosenPath != "" && len(path) >= len(chosenPath) && path > chosenPath {
+					return true
+				}
+			}
+			for _, related := range recursion {
+				hash, resultIssuer := c.hashNDegreeQuads(related, issuerCopy)
+				if c.exceeded() {
+					return false
+				}
+				path += "_:" + issuerCopy.issue(related)
+				path += "<" + hash + ">"
+				issuerCopy = resultIssuer
+				if chosenPath != "" && len(path) >= len(chosenPath) && path > chosenPath {
+					return true
+				}
+			}
+			if chosenPath == "" || path < chosenPath {
+				chosenPath, chosenIssuer = path, issuerCopy
+			}
+			return true
+		})
+		if c.exceeded() {
+			return "", issuer
+		}
+
+		data.WriteString(chosenPath)
+		issuer = chosenIssuer
+	}
+	return sha256Hex(data.String()), issuer
+}
+
+// permute calls the function with every permutation of the values,
+// until it returns false
+func permute(values []string, fn func([]string) bool) {
+	values = append([]string{}, values...)
+	sort.Strings(values)
+	var generate func(int) bool
+	generate = func(k int) bool {
+		if k == len(values) {
+			return fn(values)
+		}
+		for i := k; i < len(values); i++ {
+			values[k], values[i] = values[i], values[k]
+			more := generate(k + 1)
+			values[k], values[i] = values[i], values[k]
+			if !more {
+				return false
+			}
+		}
+		return true
+	}
+	generate(0)
+}
+
+// canonicalNQuad writes a quad in canonical N-Quads, with blank nodes relabelled
+func canonicalNQuad(t *triple, graph string, label func(string) string) string {
+	var buf bytes.Buffer
+	if t.isSubBnode {
+		buf.WriteString("_:" + label(t.sub))
+	} else {
+		buf.WriteString("<" + t.sub + ">")
+	}
+	buf.WriteString(" <" + t.pred + "> ")
+	switch {
+	case t.obj.isBnode:
+		buf.WriteString("_:" + label(t.obj.bnode))
+	case t.obj.isLit:
+		buf.WriteString(canonicalLiteral(t.obj.lit))
+	default:
+		buf.WriteString("<" + t.obj.resource + ">")
+	}
+	switch {
+	case graph == DefaultGraph:
+	case strings.HasPrefix(graph, "_:"):
+		buf.WriteString(" _:" + label(graph[2:]))
+	default:
+		buf.WriteString(" <" + graph + ">")
+	}
+	buf.WriteString(" .\n")
+	return buf.String()
+}
+
+func canonicalLiteral(lit literal) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, r := range lit.val {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&buf, "\\u%04X", r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+
+	switch {
+	case lit.langtag != "":
+		buf.WriteString("@" + lit.langtag)
+	case lit.typ == XsdString || lit.typ == "":
+	default:
+		typ := string(lit.typ)
+		if strings.HasPrefix(typ, "xsd:") {
+			typ = lit.typ.NTriplesNamespaced()
+		}
+		buf.WriteString("^^<" + typ + ">")
+	}
+	return buf.String()
+}
@@ -0,0 +1,233 @@
+package triplestore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalNTriples(t *testing.T) {
+	tris := []Triple{
+		SubjPred("http://ex.org/me", "http://ex.org/knows").Bnode("x"),
+		BnodePred("x", "http://ex.org/name").StringLiteral("John \"Jo\"\tSmith"),
+		BnodePred("x", "http://ex.org/age").IntegerLiteral(26),
+		BnodePred("x", "http://ex.org/nick").StringLiteralWithLang("jo", "en"),
+		SubjPred("http://ex.org/me", "http://ex.org/knows").Resource("http://ex.org/you"),
+	}
+	expected := `<http://ex.org/me> <http://ex.org/knows> <http://ex.org/you> .
+<http://ex.org/me> <http://ex.org/knows> _:c14n0 .
+_:c14n0 <http://ex.org/age> "26"^^<http://www.w3.org/2001/XMLSchema#integer> .
+_:c14n0 <http://ex.org/name> "John \"Jo\"\tSmith" .
+_:c14n0 <http://ex.org/nick> "jo"@en .
+`
+	if got, want := canonicalNTriples(t, tris), expected; got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCanonicalizeIsomorphicGraphs(t *testing.T) {
+	tcases := []string{
+		// undistinguishable blank nodes
+		`_:a <http://ex.org/p> _:b .
+_:b <http://ex.org/p> _:a .`,
+		// a cycle and a chain sharing first degree hashes
+		`_:a <http://ex.org/p> _:b .
+_:b <http://ex.org/p> _:c .
+_:c <http://ex.org/p> _:a .
+_:d <http://ex.org/p> _:e .
+_:e <http://ex.org/p> _:f .
+_:f <http://ex.org/p> _:d .
+_:g <http://ex.org/q> _:d .`,
+		// blank nodes distinguished by literals and IRIs
+		`_:a <http://ex.org/name> "one" .
+_:b <http://ex.org/name> "two" .
+_:a <http://ex.org/knows> _:b .
+<http://ex.org/me> <http://ex.org/knows> _:a .
+_:c <http://ex.org/p> _:c .`,
+	}
+
+	rnd := rand.New(rand.NewSource(42))
+	for i, tc := range tcases {
+		tris, err := NewTurtleDecoder(strings.NewReader(tc)).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		canonical := canonicalNTriples(t, tris)
+
+		for j := 0; j < 10; j++ {
+			labels := make(map[string]string)
+			relabel := func(l string) string {
+				if _, ok := labels[l]; !ok {
+					labels[l] = string(rune('k'+rnd.Intn(10))) + l
+				}
+				return labels[l]
+			}
+			var relabelled []Triple
+			for _, k := range rnd.Perm(len(tris)) {
+				tt := tris[k].(*triple)
+				r := &triple{sub: tt.sub, isSubBnode: tt.isSubBnode, pred: tt.pred, obj: tt.obj}
+				if r.isSubBnode {
+					r.sub = relabel(r.sub)
+				}
+				if r.obj.isBnode {
+					r.obj.bnode = relabel(r.obj.bnode)
+				}
+				relabelled = append(relabelled, r)
+			}
+
+			if got, want := canonicalNTriples(t, relabelled), canonical; got != want {
+				t.Fatalf("case %d: got\n%s\nwant\n%s", i, got, want)
+			}
+			if ok, err := Triples(tris).Isomorphic(Triples(relabelled)); err != nil || !ok {
+				t.Fatalf("case %d: expected isomorphic triples", i)
+			}
+		}
+	}
+
+	one, _ := NewTurtleDecoder(strings.NewReader(tcases[1])).Decode()
+	other, _ := NewTurtleDecoder(strings.NewReader(strings.Replace(tcases[1], "_:g <http://ex.org/q> _:d", "_:g <http://ex.org/q> _:g", 1))).Decode()
+	if ok, err := Triples(one).Isomorphic(Triples(other)); err != nil || ok {
+		t.Fatal("expected non isomorphic triples")
+	}
+}
+
+func TestGraphAndDatasetHash(t *testing.T) {
+	one, other := NewSource(), NewSource()
+	one.Add(SubjPred("me", "knows").Bnode("x"), BnodePred("x", "name").StringLiteral("jsmith"))
+	other.Add(SubjPred("me", "knows").Bnode("y"), BnodePred("y", "name").StringLiteral("jsmith"))
+
+	if got, want := graphHash(t, one.Snapshot()), graphHash(t, other.Snapshot()); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	other.Add(SubjPred("me", "age").IntegerLiteral(26))
+	if graphHash(t, one.Snapshot()) == graphHash(t, other.Snapshot()) {
+		t.Fatal("expected different hashes")
+	}
+
+	d1, d2 := NewDataset(), NewDataset()
+	d1.Add("_:g1", BnodePred("x", "p").Bnode("y"))
+	d1.Add("http://ex.org/g", SubjPred("a", "b").Resource("c"))
+	d2.Add("_:other", BnodePred("z", "p").Bnode("t"))
+	d2.Add("http://ex.org/g", SubjPred("a", "b").Resource("c"))
+	h1, err := DatasetHash(d1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := DatasetHash(d2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := h1, h2; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	expected := `<a> <b> <c> <http://ex.org/g> .
+_:c14n0 <p> _:c14n1 _:c14n2 .
+`
+	if got, want := canonicalNQuads(t, d1.Quads()), expected; got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestRDFC10W3CTestSuite runs a selection of the evaluation tests of the W3C
+// RDF Dataset Canonicalization suite, which keeps the test vectors of URDNA2015.
+// Escaping tests are not included as N-Quads are decoded leniently.
+func TestRDFC10W3CTestSuite(t *testing.T) {
+	path := filepath.Join("testdata", "rdfc10", "w3c_suite", "*-in.nq")
+	filenames, _ := filepath.Glob(path)
+	if len(filenames) == 0 {
+		t.Fatal("no test found")
+	}
+
+	for _, filename := range filenames {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("cannot read file %s", filename)
+		}
+		ds, err := NewNQuadsDecoder(bytes.NewReader(b)).DecodeDataset()
+		if err != nil {
+			t.Fatalf("file %s: %s", filename, err)
+		}
+		expected, err := ioutil.ReadFile(strings.TrimSuffix(filename, "-in.nq") + "-rdfc10.nq")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := canonicalNQuads(t, ds.Quads()), string(expected); got != want {
+			t.Fatalf("file %s: got\n%s\nwant\n%s", filename, got, want)
+		}
+	}
+}
+
+func TestCanonicalizeCallLimit(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "rdfc10", "w3c_suite", "test044-in.nq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := NewNQuadsDecoder(bytes.NewReader(b)).DecodeDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CanonicalizeQuadsWithLimit(ds.Quads(), 10); err != ErrCanonicalizationLimit {
+		t.Fatalf("got %v, want %v", err, ErrCanonicalizationLimit)
+	}
+	quads, err := CanonicalizeQuadsWithLimit(ds.Quads(), 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for _, q := range quads {
+		buf.WriteString(canonicalNQuad(q.Triple().(*triple), q.Graph(), verbatim))
+	}
+	if got, want := buf.String(), canonicalNQuads(t, ds.Quads()); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	tris := []Triple{BnodePred("a", "p").Bnode("b"), BnodePred("b", "p").Bnode("a")}
+	if _, err := CanonicalizeWithLimit(tris, 1); err != ErrCanonicalizationLimit {
+		t.Fatalf("got %v, want %v", err, ErrCanonicalizationLimit)
+	}
+	if _, err := CanonicalizeWithLimit(tris, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	// undistinguishable blank nodes need more calls than allowed by default
+	var poison []Triple
+	for i := 0; i < 12; i++ {
+		for j := 0; j < 12; j++ {
+			if i != j {
+				poison = append(poison, BnodePred(fmt.Sprint("b", i), "p").Bnode(fmt.Sprint("b", j)))
+			}
+		}
+	}
+	if _, err := Canonicalize(poison); err != ErrCanonicalizationLimit {
+		t.Fatalf("got %v, want %v", err, ErrCanonicalizationLimit)
+	}
+}
+
+func canonicalNTriples(t *testing.T, tris []Triple) string {
+	nt, err := CanonicalNTriples(tris)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nt
+}
+
+func canonicalNQuads(t *testing.T, quads []Quad) string {
+	nq, err := CanonicalNQuads(quads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nq
+}
+
+func graphHash(t *testing.T, g RDFGraph) string {
+	h, err := GraphHash(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
@@ -21,8 +21,8 @@ var (
 )
 
 func init() {
-	flag.StringVar(&outFormatFlag, "out", "ntriples", "output format (ntriples, bin)")
-	flag.StringVar(&inFormatFlag, "in", "bin", "input format (ntriples, bin)")
+	flag.StringVar(&outFormatFlag, "out", "ntriples", "output format (ntriples, nquads, turtle, jsonld, bin, dot)")
+	flag.StringVar(&inFormatFlag, "in", "bin", "input format (bin, auto, ntriples, nquads, turtle, jsonld, rdfxml)")
 	flag.Var(&filesFlag, "files", "input file paths")
 	flag.BoolVar(&useRdfPrefixesFlag, "rdf-prefixes", false, "use default RDF prefixes (rdf, rdfs, xsd)")
 	flag.Var(&prefixesFlag, "prefix", "RDF custom prefixes (format: \"prefix:http://my.uri\"")
@@ -74,15 +74,35 @@ func convert(inFilePaths []string, outFormatFlag string, context *tstore.Context
 
 	var inDecoder func(io.Reader) tstore.Decoder
 	switch inFormatFlag {
+	case "auto":
+		inDecoder = tstore.NewAutoDecoder
 	case "bin":
 		inDecoder = tstore.NewBinaryDecoder
 	case "ntriples":
 		inDecoder = tstore.NewLenientNTDecoder
+	case "nquads":
+		inDecoder = func(r io.Reader) tstore.Decoder { return tstore.NewNQuadsDecoder(r) }
+	case "turtle":
+		inDecoder = func(r io.Reader) tstore.Decoder { return tstore.NewTurtleDecoderWithContext(r, context) }
+	case "jsonld":
+		inDecoder = tstore.NewJSONLDDecoder
+	case "rdfxml":
+		inDecoder = func(r io.Reader) tstore.Decoder { return tstore.NewRDFXMLDecoderWithContext(r, context) }
 	default:
-		return fmt.Errorf("unknown in flag '%s': expect 'ntriples' or 'bin'", outFormatFlag)
+		return fmt.Errorf("unknown in flag '%s': expect 'auto', 'ntriples', 'nquads', 'turtle', 'jsonld', 'rdfxml' or 'bin'", inFormatFlag)
 	}
 
-	triples, err := tstore.NewDatasetDecoder(inDecoder, inFiles...).Decode()
+	dec := tstore.NewFileDatasetDecoder(inDecoder, inFiles...)
+
+	if outFormatFlag == "nquads" {
+		ds, err := dec.DecodeDataset()
+		if err != nil {
+			return err
+		}
+		return tstore.NewNQuadsEncoderWithContext(os.Stdout, context).EncodeQuads(ds.Quads()...)
+	}
+
+	triples, err := dec.Decode()
 	if err != nil {
 		return err
 	}
@@ -91,6 +111,10 @@ func convert(inFilePaths []string, outFormatFlag string, context *tstore.Context
 	switch outFormatFlag {
 	case "ntriples":
 		encoder = tstore.NewLenientNTEncoderWithContext(os.Stdout, context)
+	case "turtle":
+		encoder = tstore.NewTurtleEncoderWithContext(os.Stdout, context)
+	case "jsonld":
+		encoder = tstore.NewJSONLDEncoderWithContext(os.Stdout, context)
 	case "bin":
 		encoder = tstore.NewBinaryEncoder(os.Stdout)
 	case "dot":
@@ -99,7 +123,7 @@ func convert(inFilePaths []string, outFormatFlag string, context *tstore.Context
 		}
 		encoder = tstore.NewDotGraphEncoder(os.Stdout, dotPredicateFlag)
 	default:
-		return fmt.Errorf("unknown out flag '%s': expect 'ntriples, 'dot' or 'bin'", outFormatFlag)
+		return fmt.Errorf("unknown out flag '%s': expect 'ntriples', 'nquads', 'turtle', 'jsonld', 'dot' or 'bin'", outFormatFlag)
 	}
 
 	if err := encoder.Encode(triples...); err != nil {
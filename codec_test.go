@@ -368,3 +368,37 @@ func compareMultiline(t *testing.T, actual, expected []byte) {
 		}
 	}
 }
+
+func TestLogRecordsHoldBinaryTriples(t *testing.T) {
+	triples := []Triple{
+		SubjPred("one", "two").Resource("three"),
+		BnodePred("b", "label").StringLiteralWithLang("été", "fr"),
+		SubjPred("one", "count").IntegerLiteral(3),
+		SubjPred("one", "path").StringLiteral(`C:\new` + "\n"),
+	}
+	record := encodeLogRecord(operations(addOperation, triples))
+
+	var want bytes.Buffer
+	for _, tri := range triples {
+		want.WriteByte(addOperation)
+		if err := encodeBinTriple(tri, &want, verbatim); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := record[logHeaderSize:]; !bytes.Equal(got, want.Bytes()) {
+		t.Fatalf("got %q, want %q", got, want.Bytes())
+	}
+
+	ops, err := decodeLogRecord(record[logHeaderSize:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, o := range ops {
+		if got, want := o.triple, triples[i]; o.op != addOperation || !got.Equal(want) || got.(*triple).key() != want.(*triple).key() {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if _, err := decodeLogRecord(record[logHeaderSize : len(record)-1]); err == nil {
+		t.Fatal("expected error on truncated record")
+	}
+}
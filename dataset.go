@@ -0,0 +1,174 @@
+package triplestore
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultGraph is the name of the unnamed graph of a dataset
+const DefaultGraph = ""
+
+// A Quad is a triple belonging to a graph of a dataset
+type Quad interface {
+	Triple() Triple
+	Graph() string
+}
+
+type quad struct {
+	tri   Triple
+	graph string
+}
+
+func NewQuad(t Triple, graph string) Quad {
+	return &quad{tri: t, graph: graph}
+}
+
+func (q *quad) Triple() Triple {
+	return q.tri
+}
+
+func (q *quad) Graph() string {
+	return q.graph
+}
+
+// A Dataset is a collection of sources, each one identified by a graph name.
+// The default graph has the empty name.
+type Dataset interface {
+	Add(graph string, ts ...Triple)
+	Remove(graph string, ts ...Triple)
+	AddQuads(...Quad)
+	AddGraph(name string, src Source)
+	Graph(name string) (Source, bool)
+	GraphNames() []string
+	Snapshot(graph string) RDFGraph
+	UnionSnapshot() RDFGraph
+	Quads() []Quad
+}
+
+type dataset struct {
+	mu      sync.RWMutex
+	sources map[string]Source
+
+	unionMu    sync.Mutex
+	unionSnaps map[string]RDFGraph
+	union      RDFGraph
+}
+
+func NewDataset() Dataset {
+	return &dataset{sources: make(map[string]Source)}
+}
+
+func (d *dataset) source(graph string) Source {
+	d.mu.RLock()
+	src, ok := d.sources[graph]
+	d.mu.RUnlock()
+	if ok {
+		return src
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if src, ok = d.sources[graph]; !ok {
+		src = NewSource()
+		d.sources[graph] = src
+	}
+	return src
+}
+
+// Add triples to the given graph, creating it when needed
+func (d *dataset) Add(graph string, ts ...Triple) {
+	d.source(graph).Add(ts...)
+}
+
+func (d *dataset) Remove(graph string, ts ...Triple) {
+	if src, ok := d.Graph(graph); ok {
+		src.Remove(ts...)
+	}
+}
+
+func (d *dataset) AddQuads(qs ...Quad) {
+	byGraph := make(map[string][]Triple)
+	for _, q := range qs {
+		byGraph[q.Graph()] = append(byGraph[q.Graph()], q.Triple())
+	}
+	for graph, tris := range byGraph {
+		d.Add(graph, tris...)
+	}
+}
+
+// AddGraph registers an existing source under the given graph name,
+// replacing any previous source of that name
+func (d *dataset) AddGraph(name string, src Source) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sources[name] = src
+}
+
+func (d *dataset) Graph(name string) (Source, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	src, ok := d.sources[name]
+	return src, ok
+}
+
+// GraphNames returns the sorted names of the graphs of the dataset
+func (d *dataset) GraphNames() (names []string) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for name := range d.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Snapshot returns the snapshot of a single graph. Unknown graphs are empty.
+func (d *dataset) Snapshot(graph string) RDFGraph {
+	if src, ok := d.Graph(graph); ok {
+		return src.Snapshot()
+	}
+	return newGraph()
+}
+
+// UnionSnapshot returns a view of the union of all graphs of the dataset.
+// It is only recomputed when one of the graphs has changed.
+func (d *dataset) UnionSnapshot() RDFGraph {
+	snaps := make(map[string]RDFGraph)
+	var graphs []RDFGraph
+	for _, name := range d.GraphNames() {
+		snaps[name] = d.Snapshot(name)
+		graphs = append(graphs, snaps[name])
+	}
+
+	d.unionMu.Lock()
+	defer d.unionMu.Unlock()
+
+	if d.union != nil && sameSnapshots(d.unionSnaps, snaps) {
+		return d.union
+	}
+
+	d.union, d.unionSnaps = Union(graphs...), snaps
+
+	return d.union
+}
+
+func sameSnapshots(one, other map[string]RDFGraph) bool {
+	if len(one) != len(other) {
+		return false
+	}
+	for name, snap := range one {
+		if other[name] != snap {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *dataset) Quads() (out []Quad) {
+	for _, name := range d.GraphNames() {
+		for _, t := range d.Snapshot(name).Triples() {
+			out = append(out, NewQuad(t, name))
+		}
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package triplestore_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestDataset(t *testing.T) {
+	one := tstore.SubjPred("one", "pred").StringLiteral("1")
+	two := tstore.SubjPred("two", "pred").StringLiteral("2")
+	three := tstore.SubjPred("three", "pred").StringLiteral("3")
+
+	ds := tstore.NewDataset()
+	ds.Add(tstore.DefaultGraph, one)
+	ds.Add("g1", one, two)
+	ds.AddQuads(tstore.NewQuad(three, "g2"))
+
+	if got, want := ds.GraphNames(), []string{"", "g1", "g2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	t.Run("per graph snapshots", func(t *testing.T) {
+		if got, want := ds.Snapshot("g1").Count(), 2; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		if !ds.Snapshot("g2").Contains(three) || ds.Snapshot("g2").Contains(one) {
+			t.Fatal("graph g2 should only contain its own triples")
+		}
+		if got, want := ds.Snapshot("unknown").Count(), 0; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("union snapshot", func(t *testing.T) {
+		union := ds.UnionSnapshot()
+		if got, want := union.Count(), 3; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		if got, want := ds.UnionSnapshot(), union; got != want {
+			t.Fatal("expected cached union snapshot when no graph changed")
+		}
+
+		ds.Remove("g1", two)
+		union = ds.UnionSnapshot()
+		if got, want := union.Count(), 2; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		if union.Contains(two) {
+			t.Fatalf("union should not contain removed triple %v", two)
+		}
+	})
+
+	t.Run("quads", func(t *testing.T) {
+		quads := ds.Quads()
+		if got, want := len(quads), 3; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		for _, q := range quads {
+			if src, _ := ds.Graph(q.Graph()); !src.Snapshot().Contains(q.Triple()) {
+				t.Fatalf("quad %v not in graph '%s'", q.Triple(), q.Graph())
+			}
+		}
+	})
+
+	t.Run("existing source as graph", func(t *testing.T) {
+		src := tstore.NewSource()
+		src.Add(two)
+		ds.AddGraph("g3", src)
+		if !ds.Snapshot("g3").Contains(two) {
+			t.Fatalf("graph g3 should contain %v", two)
+		}
+		if !ds.UnionSnapshot().Contains(two) {
+			t.Fatalf("union should contain %v", two)
+		}
+	})
+}
+
+func TestDecodeDatasetInNamedGraphs(t *testing.T) {
+	one := tstore.SubjPred("one", "pred1").StringLiteral("lit1")
+	two := tstore.SubjPred("two", "pred2").StringLiteral("lit2")
+
+	var first, second bytes.Buffer
+	tstore.NewBinaryEncoder(&first).Encode(one)
+	tstore.NewBinaryEncoder(&second).Encode(two)
+
+	t.Run("named readers", func(t *testing.T) {
+		dec := tstore.NewNamedDatasetDecoder(tstore.NewBinaryDecoder, map[string]io.Reader{
+			"first":  bytes.NewReader(first.Bytes()),
+			"second": bytes.NewReader(second.Bytes()),
+		})
+		ds, err := dec.DecodeDataset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := ds.GraphNames(), []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if !ds.Snapshot("first").Contains(one) || ds.Snapshot("first").Contains(two) {
+			t.Fatal("graph first should only contain first reader triples")
+		}
+		if !ds.Snapshot("second").Contains(two) {
+			t.Fatal("graph second should contain second reader triples")
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.Write(first.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		f.Seek(0, 0)
+
+		ds, err := tstore.NewFileDatasetDecoder(tstore.NewBinaryDecoder, f, bytes.NewReader(second.Bytes())).DecodeDataset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ds.Snapshot(f.Name()).Contains(one) {
+			t.Fatalf("graph named after file should contain %v", one)
+		}
+		if !ds.Snapshot("1").Contains(two) {
+			t.Fatalf("graph named after reader position should contain %v", two)
+		}
+	})
+}
@@ -5,11 +5,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type Decoder interface {
@@ -17,21 +20,37 @@ type Decoder interface {
 }
 
 type DecodeResult struct {
-	Tri Triple
-	Err error
+	Tri   Triple
+	Graph string // only set when decoding quads
+	Err   error
 }
 
 type StreamDecoder interface {
 	StreamDecode(context.Context) <-chan DecodeResult
 }
 
-// Use for retro compatibilty when changing file format on existing stores
+// NewAutoDecoder detects the format of the input among RDF/XML, N-Triples,
+// JSON-LD, Turtle and binary, the latter for retro compatibilty when
+// changing file format on existing stores. Other inputs fail to decode.
 func NewAutoDecoder(r io.Reader) Decoder {
-	ok, newR := IsNTFormat(r)
+	ok, newR := IsRDFXMLFormat(r)
+	if ok {
+		return NewRDFXMLDecoder(newR)
+	}
+	ok, newR = IsNTFormat(newR)
 	if ok {
 		return NewLenientNTDecoder(newR)
 	}
-	return NewBinaryDecoder(newR)
+	if ok, newR = IsJSONLDFormat(newR); ok {
+		return NewJSONLDDecoder(newR)
+	}
+	if ok, newR = IsTurtleFormat(newR); ok {
+		return NewTurtleDecoder(newR)
+	}
+	if ok, newR = isBinaryFormat(newR); ok {
+		return NewBinaryDecoder(newR)
+	}
+	return &unknownFormatDecoder{}
 }
 
 // Loosely detect if a ntriples format contrary to a binary format
@@ -46,6 +65,51 @@ func IsNTFormat(r io.Reader) (bool, io.Reader) {
 	return bytes.Equal(firstChar, []byte{'<'}), multi
 }
 
+// Loosely detect if a JSON-LD document, i.e. a JSON object or an array of objects
+func IsJSONLDFormat(r io.Reader) (bool, io.Reader) {
+	buffered := bufio.NewReader(r)
+	b := peekText(buffered)
+	if bytes.HasPrefix(b, []byte("[")) {
+		b = bytes.TrimLeft(b[1:], " \t\r\n")
+	}
+	return bytes.HasPrefix(b, []byte("{")), buffered
+}
+
+// Loosely detect if a turtle document, i.e. starting with a directive, a
+// comment, an IRI, a blank node, a collection or a prefixed name
+func IsTurtleFormat(r io.Reader) (bool, io.Reader) {
+	buffered := bufio.NewReader(r)
+	b := peekText(buffered)
+	if len(b) == 0 {
+		return false, buffered
+	}
+	c := b[0]
+	isLetter := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+	return isLetter || strings.IndexByte("@#<_[(:", c) >= 0, buffered
+}
+
+// Binary encoded triples always start with a boolean byte (i.e. 0 or 1)
+func isBinaryFormat(r io.Reader) (bool, io.Reader) {
+	buffered := bufio.NewReader(r)
+	b, err := buffered.Peek(1)
+	if err != nil {
+		return true, buffered
+	}
+	return b[0] <= 1, buffered
+}
+
+// peekText returns the beginning of the input, without byte order mark and leading spaces
+func peekText(buffered *bufio.Reader) []byte {
+	b, _ := buffered.Peek(512)
+	return bytes.TrimLeft(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")), " \t\r\n")
+}
+
+type unknownFormatDecoder struct{}
+
+func (d *unknownFormatDecoder) Decode() ([]Triple, error) {
+	return nil, errors.New("triplestore: unknown format, expected RDF/XML, N-Triples, JSON-LD, Turtle or binary")
+}
+
 func NewLenientNTDecoder(r io.Reader) Decoder {
 	return &ntDecoder{r: r}
 }
@@ -63,23 +127,127 @@ func (d *ntDecoder) Decode() ([]Triple, error) {
 }
 
 func (d *ntDecoder) StreamDecode(ctx context.Context) <-chan DecodeResult {
+	return streamDecodeLines(ctx, d.r, false)
+}
+
+// NewTurtleDecoder decodes the W3C Turtle format. Relative IRIs are
+// kept as is, unless the document declares a base.
+func NewTurtleDecoder(r io.Reader) Decoder {
+	return &turtleDecoder{r: r}
+}
+
+// NewTurtleDecoderWithContext decodes the W3C Turtle format, with
+// the base and prefixes of the context declared upfront
+func NewTurtleDecoderWithContext(r io.Reader, c *Context) Decoder {
+	return &turtleDecoder{r: r, context: c}
+}
+
+func NewTurtleStreamDecoder(r io.Reader) StreamDecoder {
+	return &turtleDecoder{r: r}
+}
+
+type turtleDecoder struct {
+	r       io.Reader
+	context *Context
+}
+
+func (d *turtleDecoder) Decode() ([]Triple, error) {
+	return newTurtleParser(d.r, d.context).Parse()
+}
+
+func (d *turtleDecoder) StreamDecode(ctx context.Context) <-chan DecodeResult {
+	decC := make(chan DecodeResult)
+
+	go func() {
+		defer close(decC)
+
+		err := newTurtleParser(d.r, d.context).parse(func(t Triple) error {
+			select {
+			case <-ctx.Done():
+				return errStopParsing
+			case decC <- DecodeResult{Tri: t}:
+				return nil
+			}
+		})
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			case decC <- DecodeResult{Err: err}:
+			}
+		}
+	}()
+
+	return decC
+}
+
+// QuadDecoder decodes statements of named graphs as quads,
+// as a dataset or as a flat list of triples
+type QuadDecoder interface {
+	DatasetDecoder
+	DecodeQuads() ([]Quad, error)
+}
+
+func NewNQuadsDecoder(r io.Reader) QuadDecoder {
+	return &nquadsDecoder{r: r}
+}
+
+// NewNQuadsStreamDecoder streams decoded triples along with the name of their graph
+func NewNQuadsStreamDecoder(r io.Reader) StreamDecoder {
+	return &nquadsDecoder{r: r}
+}
+
+type nquadsDecoder struct {
+	r io.Reader
+}
+
+func (d *nquadsDecoder) DecodeQuads() ([]Quad, error) {
+	return newLenientNTParser(d.r).ParseQuads()
+}
+
+func (d *nquadsDecoder) Decode() ([]Triple, error) {
+	quads, err := d.DecodeQuads()
+	var out []Triple
+	for _, q := range quads {
+		out = append(out, q.Triple())
+	}
+	return out, err
+}
+
+func (d *nquadsDecoder) DecodeDataset() (Dataset, error) {
+	quads, err := d.DecodeQuads()
+	if err != nil {
+		return nil, err
+	}
+	ds := NewDataset()
+	ds.AddQuads(quads...)
+	return ds, nil
+}
+
+func (d *nquadsDecoder) StreamDecode(ctx context.Context) <-chan DecodeResult {
+	return streamDecodeLines(ctx, d.r, true)
+}
+
+func streamDecodeLines(ctx context.Context, r io.Reader, quads bool) <-chan DecodeResult {
 	decC := make(chan DecodeResult)
 
 	go func() {
 		defer close(decC)
 
-		scanner := bufio.NewScanner(d.r)
+		scanner := bufio.NewScanner(r)
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			default:
 				if scanner.Scan() {
-					tris, err := newLenientNTParser(strings.NewReader(scanner.Text())).Parse()
+					var res *DecodeResult
+					err := newLenientNTParser(strings.NewReader(scanner.Text())).parse(quads, func(t Triple, graph string) {
+						res = &DecodeResult{Tri: t, Graph: graph}
+					})
 					if err != nil {
 						decC <- DecodeResult{Err: err}
-					} else if len(tris) == 1 {
-						decC <- DecodeResult{Tri: tris[0]}
+					} else if res != nil {
+						decC <- *res
 					}
 				} else {
 					if err := scanner.Err(); err != nil {
@@ -114,7 +282,7 @@ func (dec *binaryDecoder) StreamDecode(ctx context.Context) <-chan DecodeResult
 			case <-ctx.Done():
 				return
 			default:
-				tri, done, err := decodeTriple(dec.rc)
+				tri, done, err := decodeTriple(dec.rc, unescapeStringLiteral)
 				if done {
 					return
 				}
@@ -133,7 +301,7 @@ func NewBinaryDecoder(r io.Reader) Decoder {
 func (dec *binaryDecoder) Decode() ([]Triple, error) {
 	var out []Triple
 	for {
-		tri, done, err := decodeTriple(dec.r)
+		tri, done, err := decodeTriple(dec.r, unescapeStringLiteral)
 		if tri != nil {
 			out = append(out, tri)
 		}
@@ -147,7 +315,9 @@ func (dec *binaryDecoder) Decode() ([]Triple, error) {
 	return out, nil
 }
 
-func decodeTriple(r io.Reader) (Triple, bool, error) {
+// decodeTriple reads a binary triple, with the string literal values
+// unescaped by the given function
+func decodeTriple(r io.Reader, unescape func(string) string) (Triple, bool, error) {
 	var isSubBNode bool
 	err := binary.Read(r, binary.BigEndian, &isSubBNode)
 	if err == io.EOF {
@@ -194,6 +364,7 @@ func decodeTriple(r io.Reader) (Triple, bool, error) {
 			if err != nil {
 				return nil, false, fmt.Errorf("lang: %s", err)
 			}
+			decodedLiteral.typ = XsdString
 			decodedLiteral.langtag = string(lang)
 		} else {
 			litType, err := readWord(r)
@@ -207,8 +378,8 @@ func decodeTriple(r io.Reader) (Triple, bool, error) {
 		if err != nil {
 			return nil, false, fmt.Errorf("literate: %s", err)
 		}
-		if decodedLiteral.typ == XsdString || objType == literalWithLangEncoding {
-			decodedLiteral.val = unescapeStringLiteral(string(val))
+		if decodedLiteral.typ == XsdString {
+			decodedLiteral.val = unescape(string(val))
 		} else {
 			decodedLiteral.val = string(val)
 		}
@@ -241,18 +412,68 @@ func readWord(r io.Reader) ([]byte, error) {
 type datasetDecoder struct {
 	newDecoderFunc func(io.Reader) Decoder
 	rs             []io.Reader
+	names          []string
+}
+
+// DatasetDecoder decodes several readers either as a flat list of triples
+// or as a dataset where each reader gives a named graph
+type DatasetDecoder interface {
+	Decoder
+	DecodeDataset() (Dataset, error)
 }
 
 // NewDatasetDecoder - a dataset is a basically a collection of RDFGraph.
 func NewDatasetDecoder(fn func(io.Reader) Decoder, readers ...io.Reader) Decoder {
-	return &datasetDecoder{newDecoderFunc: fn, rs: readers}
+	return NewFileDatasetDecoder(fn, readers...)
+}
+
+// NewFileDatasetDecoder decodes readers as NewDatasetDecoder, but can also decode
+// them as a dataset: triples of a file are put in a graph named after the file,
+// other readers give graphs named after their position.
+func NewFileDatasetDecoder(fn func(io.Reader) Decoder, readers ...io.Reader) DatasetDecoder {
+	var names []string
+	for i, r := range readers {
+		if f, ok := r.(*os.File); ok {
+			names = append(names, f.Name())
+		} else {
+			names = append(names, strconv.Itoa(i))
+		}
+	}
+	return &datasetDecoder{newDecoderFunc: fn, rs: readers, names: names}
+}
+
+// NewNamedDatasetDecoder decodes each reader into the graph of the given name
+func NewNamedDatasetDecoder(fn func(io.Reader) Decoder, readers map[string]io.Reader) DatasetDecoder {
+	dec := &datasetDecoder{newDecoderFunc: fn}
+	for name, r := range readers {
+		dec.names = append(dec.names, name)
+		dec.rs = append(dec.rs, r)
+	}
+	return dec
 }
 
 func (dec *datasetDecoder) Decode() ([]Triple, error) {
+	var all []Triple
+	err := dec.decodeAll(func(_ string, tris []Triple) {
+		all = append(all, tris...)
+	})
+	return all, err
+}
+
+func (dec *datasetDecoder) DecodeDataset() (Dataset, error) {
+	ds := NewDataset()
+	err := dec.decodeAll(func(name string, tris []Triple) {
+		ds.Add(name, tris...)
+	})
+	return ds, err
+}
+
+func (dec *datasetDecoder) decodeAll(each func(string, []Triple)) error {
 	type result struct {
 		err    error
 		tris   []Triple
 		reader io.Reader
+		name   string
 	}
 
 	results := make(chan *result, len(dec.rs))
@@ -260,17 +481,17 @@ func (dec *datasetDecoder) Decode() ([]Triple, error) {
 	defer close(done)
 
 	var wg sync.WaitGroup
-	for _, reader := range dec.rs {
+	for i, reader := range dec.rs {
 		wg.Add(1)
-		go func(r io.Reader) {
+		go func(r io.Reader, name string) {
 			defer wg.Done()
 			tris, err := dec.newDecoderFunc(r).Decode()
 			select {
-			case results <- &result{tris: tris, err: err, reader: r}:
+			case results <- &result{tris: tris, err: err, reader: r, name: name}:
 			case <-done:
 				return
 			}
-		}(reader)
+		}(reader, dec.names[i])
 	}
 
 	go func() {
@@ -278,20 +499,44 @@ func (dec *datasetDecoder) Decode() ([]Triple, error) {
 		close(results)
 	}()
 
-	var all []Triple
 	for r := range results {
 		if r.err != nil {
 			switch rr := r.reader.(type) {
 			case *os.File:
-				return all, fmt.Errorf("file '%s': %s", rr.Name(), r.err)
+				return fmt.Errorf("file '%s': %s", rr.Name(), r.err)
 			default:
-				return all, r.err
+				return r.err
 			}
 		}
-		all = append(all, r.tris...)
+		each(r.name, r.tris)
 	}
 
-	return all, nil
+	return nil
+}
+
+// generatedBnodePrefix labels the blank nodes created by decoders for anonymous
+// nodes, followed by a counter. Labels found in documents starting with it are
+// relabelled by documentBnode so that both never collide.
+const generatedBnodePrefix = "genid"
+
+func generatedBnode(n int) string {
+	return generatedBnodePrefix + strconv.Itoa(n)
+}
+
+// generatedBnodes counts the blank nodes generated outside of documents,
+// so that the triples generated by different calls never share them
+var generatedBnodes uint64
+
+// newGeneratedBnode returns a generated label not returned by another call
+func newGeneratedBnode() string {
+	return generatedBnode(int(atomic.AddUint64(&generatedBnodes, 1)))
+}
+
+func documentBnode(label string) string {
+	if strings.HasPrefix(label, generatedBnodePrefix) {
+		return generatedBnodePrefix + "_" + label
+	}
+	return label
 }
 
 var unescaper = strings.NewReplacer("\\n", "\n", "\\r", "\r")
@@ -0,0 +1,189 @@
+package triplestore
+
+import (
+	"strings"
+	"sync"
+)
+
+// idKey is a tuple of term IDs used as key in the indexes of a graph.
+// Unused positions are zero, as IDs start at 1.
+type idKey struct {
+	a, b, c uint32
+}
+
+// bnodeID flags the ID of a subject that is a blank node
+const bnodeID = uint32(1) << 31
+
+// A dictionary interns the terms of triples as integer IDs. It is shared
+// by the snapshots of a source and only grows: IDs of terms that are not
+// used anymore are kept, so that older snapshots remain valid. Sources
+// reclaim them by moving their latest snapshot to a new dictionary.
+type dictionary struct {
+	mu sync.RWMutex
+	// subjects and predicates
+	names   []string
+	nameIDs map[string]uint32
+	// objects are stored as their keys, which they are rebuilt from
+	objects []string
+	objIDs  map[string]uint32
+	// types of language-tagged literals other than xsd:string, as keys omit them
+	langTypes map[uint32]XsdType
+}
+
+func newDictionary() *dictionary {
+	return &dictionary{
+		names:   []string{""},
+		nameIDs: make(map[string]uint32),
+		objects: []string{""},
+		objIDs:  make(map[string]uint32),
+	}
+}
+
+// size returns the number of terms of the dictionary
+func (d *dictionary) size() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.names) + len(d.objects) - 2
+}
+
+// internAdded returns the IDs of the triples of the operations under a single
+// lock, interning the terms of added triples only. Removed triples with
+// unknown terms are not known.
+func (d *dictionary) internAdded(ops []operation) (keys []idKey, known []bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys, known = make([]idKey, len(ops)), make([]bool, len(ops))
+	for i, o := range ops {
+		t := o.triple.(*triple)
+		if o.op == addOperation {
+			keys[i], known[i] = d.intern(t), true
+		} else {
+			keys[i], known[i] = d.lookupTriple(t)
+		}
+	}
+	return
+}
+
+// intern returns the ID of the triple, adding its terms when unknown.
+// It must be called with the lock held.
+func (d *dictionary) intern(t *triple) idKey {
+	k := idKey{a: d.internName(t.sub), b: d.internName(t.pred)}
+	if t.isSubBnode {
+		k.a |= bnodeID
+	}
+	objKey := t.obj.key()
+	id, ok := d.objIDs[objKey]
+	if !ok {
+		id = uint32(len(d.objects))
+		d.objects = append(d.objects, objKey)
+		d.objIDs[objKey] = id
+		if lit := t.obj.lit; t.obj.isLit && lit.langtag != "" && lit.typ != XsdString {
+			if d.langTypes == nil {
+				d.langTypes = make(map[uint32]XsdType)
+			}
+			d.langTypes[id] = lit.typ
+		}
+	}
+	k.c = id
+	return k
+}
+
+func (d *dictionary) internName(name string) uint32 {
+	id, ok := d.nameIDs[name]
+	if !ok {
+		id = uint32(len(d.names))
+		d.names = append(d.names, name)
+		d.nameIDs[name] = id
+	}
+	return id
+}
+
+// lookup returns the ID of the triple, false when one of its terms is unknown
+func (d *dictionary) lookup(t *triple) (idKey, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.lookupTriple(t)
+}
+
+func (d *dictionary) lookupTriple(t *triple) (idKey, bool) {
+	sub, subOk := d.nameIDs[t.sub]
+	pred, predOk := d.nameIDs[t.pred]
+	obj, objOk := d.objIDs[t.obj.key()]
+	if t.isSubBnode {
+		sub |= bnodeID
+	}
+	return idKey{a: sub, b: pred, c: obj}, subOk && predOk && objOk
+}
+
+func (d *dictionary) nameID(name string) (uint32, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	id, ok := d.nameIDs[name]
+	return id, ok
+}
+
+func (d *dictionary) name(id uint32) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.names[id&^bnodeID]
+}
+
+func (d *dictionary) object(id uint32) object {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.objectOf(id)
+}
+
+// objectOf rebuilds the object of the given ID from its key.
+// It must be called with the lock held.
+func (d *dictionary) objectOf(id uint32) object {
+	key := d.objects[id]
+	switch {
+	case key == "":
+		return object{}
+	case key[0] == '<':
+		return object{resource: key[1 : len(key)-1]}
+	case strings.HasPrefix(key, "_:"):
+		return object{isBnode: true, bnode: key[2:]}
+	}
+	if strings.HasSuffix(key, ">") {
+		if i := strings.LastIndex(key, "\"^^<"); i > 0 {
+			return object{isLit: true, lit: literal{typ: XsdType(key[i+4 : len(key)-1]), val: key[1:i]}}
+		}
+	}
+	i := strings.LastIndex(key, "\"@")
+	typ, ok := d.langTypes[id]
+	if !ok {
+		typ = XsdString
+	}
+	return object{isLit: true, lit: literal{typ: typ, val: key[1:i], langtag: key[i+2:]}}
+}
+
+func (d *dictionary) objectID(o object) (uint32, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	id, ok := d.objIDs[o.key()]
+	return id, ok
+}
+
+// triples rebuilds the triples of the given IDs
+func (d *dictionary) triples(keys []idKey) []Triple {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	out := make([]Triple, len(keys))
+	for i, k := range keys {
+		out[i] = d.rebuild(k)
+	}
+	return out
+}
+
+func (d *dictionary) rebuild(k idKey) *triple {
+	return &triple{
+		sub:        d.names[k.a&^bnodeID],
+		isSubBnode: k.a&bnodeID != 0,
+		pred:       d.names[k.b],
+		obj:        d.objectOf(k.c),
+	}
+}
@@ -0,0 +1,90 @@
+package triplestore
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTxSnapshotDoesNotInternTerms(t *testing.T) {
+	s := NewSource()
+	s.Add(SubjPred("me", "age").IntegerLiteral(25))
+	dict := s.Snapshot().(*graph).dict
+	names, objects := len(dict.names), len(dict.objects)
+
+	tx := s.Begin()
+	tx.Remove(SubjPred("me", "age").IntegerLiteral(25))
+	tx.Add(SubjPred("you", "name").StringLiteral("jsmith"))
+	if got, want := Triples(tx.Snapshot().Triples()), (Triples{SubjPred("you", "name").StringLiteral("jsmith")}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	tx.Rollback()
+
+	if got, want := len(dict.names), names; got != want {
+		t.Fatalf("got %d names, want %d", got, want)
+	}
+	if got, want := len(dict.objects), objects; got != want {
+		t.Fatalf("got %d objects, want %d", got, want)
+	}
+}
+
+func TestDictionaryRebuildsObjectsFromKeys(t *testing.T) {
+	objs := []object{
+		{resource: "http://ex.org/me"},
+		{isBnode: true, bnode: "b1"},
+		{isLit: true, lit: literal{typ: XsdInteger, val: "25"}},
+		{isLit: true, lit: literal{typ: XsdString, val: `quote" and "^^<x>`}},
+		{isLit: true, lit: literal{typ: XsdString, val: `"@en`, langtag: "fr"}},
+		{isLit: true, lit: literal{val: "untyped", langtag: "en"}},
+		{isLit: true, lit: literal{}},
+	}
+	d := newDictionary()
+	d.mu.Lock()
+	for _, o := range objs {
+		d.intern(&triple{sub: "s", pred: "p", obj: o})
+	}
+	d.mu.Unlock()
+	for _, o := range objs {
+		id, ok := d.objectID(o)
+		if !ok {
+			t.Fatalf("%v: unknown object", o)
+		}
+		if got, want := d.object(id), o; got != want {
+			t.Fatalf("got %#v, want %#v", got, want)
+		}
+	}
+}
+
+func TestSourceReclaimsUnusedTerms(t *testing.T) {
+	s := NewSource()
+	s.Add(SubjPred("me", "age").IntegerLiteral(25))
+	first := s.Snapshot()
+
+	for i := 0; i < 20; i++ {
+		var tris []Triple
+		for j := 0; j < 1000; j++ {
+			tris = append(tris, SubjPred(fmt.Sprint("s", i, "-", j), "p").IntegerLiteral(i*1000+j))
+		}
+		s.Add(tris...)
+		s.Snapshot()
+		s.Remove(tris...)
+		s.Snapshot()
+	}
+
+	if got, max := s.Snapshot().(*graph).dict.size(), reclaimSize; got > max {
+		t.Fatalf("got %d terms, want at most %d", got, max)
+	}
+	if got, want := Triples(s.Snapshot().Triples()), (Triples{SubjPred("me", "age").IntegerLiteral(25)}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// older snapshots keep their dictionary
+	if got, want := Triples(first.WithPredicate("age")), (Triples{SubjPred("me", "age").IntegerLiteral(25)}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	s.Add(SubjPred("you", "age").IntegerLiteral(25))
+	s.Remove(SubjPred("you", "age").IntegerLiteral(25))
+	s.(*source).compact()
+	if got, want := s.Snapshot().(*graph).dict.size(), 3; got != want {
+		t.Fatalf("got %d terms, want %d", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+package triplestore
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A GraphDiff holds the triples to remove from and to add to
+// a graph to obtain another one
+type GraphDiff struct {
+	Added, Removed []Triple
+}
+
+// IsEmpty returns true when both graphs of the diff are equal
+func (d GraphDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// ApplyTo removes then adds the triples of the diff to the source in a single transaction
+func (d GraphDiff) ApplyTo(src Source) error {
+	return src.Update(func(tx Tx) error {
+		tx.Remove(d.Removed...)
+		tx.Add(d.Added...)
+		return nil
+	})
+}
+
+// Diff returns the triples of b not in a as added, and the triples
+// of a not in b as removed. Blank nodes match on their labels.
+func Diff(a, b RDFGraph) GraphDiff {
+	var d GraphDiff
+	for _, t := range b.Triples() {
+		if !a.Contains(t) {
+			d.Added = append(d.Added, t)
+		}
+	}
+	for _, t := range a.Triples() {
+		if !b.Contains(t) {
+			d.Removed = append(d.Removed, t)
+		}
+	}
+	sortTriples(d.Added)
+	sortTriples(d.Removed)
+	return d
+}
+
+// IsomorphicDiff is like Diff but matches the blank nodes of b to the
+// blank nodes of a that have the same surrounding triples, whatever their
+// labels. Added triples are relabelled accordingly, so that the diff
+// can be applied to a. Unmatched blank nodes of b are given labels
+// not used in a.
+//
+// Blank nodes are told apart by refining colors with their neighbours
+// (1-WL), then matched greedily within each color. The diff is always
+// correct, but not always minimal: in symmetric graphs such as cycles or
+// regular structures, blank nodes of a color are not distinguished and may
+// be paired so that more triples differ than with the best matching.
+func IsomorphicDiff(a, b RDFGraph) GraphDiff {
+	aTris, bTris := a.Triples(), b.Triples()
+	colors := bnodeColors(aTris, bTris)
+	aColors, bColors := colors[0], colors[1]
+
+	mapping := make(map[string]string)
+	matched := make(map[string]bool)
+	byColor := make(map[string][]string)
+	for _, bnode := range sortedLabels(aColors) {
+		byColor[aColors[bnode]] = append(byColor[aColors[bnode]], bnode)
+	}
+	for _, bnode := range sortedLabels(bColors) {
+		candidates := byColor[bColors[bnode]]
+		if len(candidates) == 0 {
+			continue
+		}
+		mapping[bnode] = candidates[0]
+		matched[candidates[0]] = true
+		byColor[bColors[bnode]] = candidates[1:]
+	}
+	for _, bnode := range sortedLabels(bColors) {
+		if _, ok := mapping[bnode]; ok {
+			continue
+		}
+		label := bnode
+		for i := 1; aColors[label] != "" || matched[label]; i++ {
+			label = fmt.Sprintf("%s_%d", bnode, i)
+		}
+		mapping[bnode] = label
+		matched[label] = true
+	}
+
+	aKeys := make(map[string]bool, len(aTris))
+	for _, t := range aTris {
+		aKeys[t.(*triple).key()] = true
+	}
+	bKeys := make(map[string]bool, len(bTris))
+	var d GraphDiff
+	for _, t := range bTris {
+		relabelled := relabelBnodes(t.(*triple), mapping)
+		bKeys[relabelled.key()] = true
+		if !aKeys[relabelled.key()] {
+			d.Added = append(d.Added, relabelled)
+		}
+	}
+	for _, t := range aTris {
+		if !bKeys[t.(*triple).key()] {
+			d.Removed = append(d.Removed, t)
+		}
+	}
+	sortTriples(d.Added)
+	sortTriples(d.Removed)
+	return d
+}
+
+type graphBnode struct {
+	graph int
+	label string
+}
+
+// bnodeColors hashes the blank nodes of each set of triples with their
+// surrounding triples, refined until blank nodes are not distinguished any
+// further. Blank nodes with the same color are interchangeable, even across sets.
+func bnodeColors(sets ...[]Triple) []map[string]string {
+	colors := make(map[graphBnode]string)
+	for g, tris := range sets {
+		for _, t := range tris {
+			tt := t.(*triple)
+			if tt.isSubBnode {
+				colors[graphBnode{g, tt.sub}] = "_"
+			}
+			if tt.obj.isBnode {
+				colors[graphBnode{g, tt.obj.bnode}] = "_"
+			}
+		}
+	}
+
+	distinct := 1
+	for round := 0; round <= len(colors); round++ {
+		neighbours := make(map[graphBnode][]string)
+		for g, tris := range sets {
+			for _, t := range tris {
+				tt := t.(*triple)
+				subNode, objNode := graphBnode{g, tt.sub}, graphBnode{g, tt.obj.bnode}
+				sub, obj := "<"+tt.sub+">", tt.obj.key()
+				if tt.isSubBnode {
+					sub = "_:" + colors[subNode]
+				}
+				if tt.obj.isBnode {
+					obj = "_:" + colors[objNode]
+				}
+				if tt.isSubBnode {
+					neighbours[subNode] = append(neighbours[subNode], "s <"+tt.pred+"> "+obj)
+				}
+				if tt.obj.isBnode {
+					neighbours[objNode] = append(neighbours[objNode], "o "+sub+" <"+tt.pred+">")
+				}
+			}
+		}
+
+		refined := make(map[graphBnode]string, len(colors))
+		set := make(map[string]bool)
+		for bnode, color := range colors {
+			sort.Strings(neighbours[bnode])
+			h := sha1.Sum([]byte(color + "\n" + strings.Join(neighbours[bnode], "\n")))
+			refined[bnode] = hex.EncodeToString(h[:])
+			set[refined[bnode]] = true
+		}
+		colors = refined
+		if len(set) == distinct {
+			break
+		}
+		distinct = len(set)
+	}
+
+	out := make([]map[string]string, len(sets))
+	for i := range out {
+		out[i] = make(map[string]string)
+	}
+	for bnode, color := range colors {
+		out[bnode.graph][bnode.label] = color
+	}
+	return out
+}
+
+func sortedLabels(m map[string]string) []string {
+	var labels []string
+	for l := range m {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func relabelBnodes(t *triple, mapping map[string]string) *triple {
+	relabelled := &triple{sub: t.sub, isSubBnode: t.isSubBnode, pred: t.pred, obj: t.obj}
+	if t.isSubBnode {
+		relabelled.sub = mapping[t.sub]
+	}
+	if t.obj.isBnode {
+		relabelled.obj.bnode = mapping[t.obj.bnode]
+	}
+	return relabelled
+}
+
+func sortTriples(tris []Triple) {
+	sort.Slice(tris, func(i, j int) bool { return tris[i].(*triple).key() < tris[j].(*triple).key() })
+}
@@ -0,0 +1,161 @@
+package triplestore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := NewSource()
+	a.Add(
+		SubjPred("me", "name").StringLiteral("jsmith"),
+		SubjPred("me", "age").IntegerLiteral(25),
+		SubjPred("me", "address").Bnode("addr"),
+		BnodePred("addr", "city").StringLiteral("Paris"),
+	)
+	b := NewSource()
+	b.Add(
+		SubjPred("me", "name").StringLiteral("jsmith"),
+		SubjPred("me", "age").IntegerLiteral(26),
+		SubjPred("me", "address").Bnode("b0"),
+		BnodePred("b0", "city").StringLiteral("Paris"),
+		SubjPred("me", "knows").Bnode("addr"),
+		BnodePred("addr", "name").StringLiteral("John"),
+	)
+
+	d := Diff(a.Snapshot(), b.Snapshot())
+	if got, want := Triples(d.Added), (Triples{
+		SubjPred("me", "age").IntegerLiteral(26),
+		SubjPred("me", "address").Bnode("b0"),
+		BnodePred("b0", "city").StringLiteral("Paris"),
+		SubjPred("me", "knows").Bnode("addr"),
+		BnodePred("addr", "name").StringLiteral("John"),
+	}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := Triples(d.Removed), (Triples{
+		SubjPred("me", "age").IntegerLiteral(25),
+		SubjPred("me", "address").Bnode("addr"),
+		BnodePred("addr", "city").StringLiteral("Paris"),
+	}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	d = IsomorphicDiff(a.Snapshot(), b.Snapshot())
+	if got, want := Triples(d.Added), (Triples{
+		SubjPred("me", "age").IntegerLiteral(26),
+		SubjPred("me", "knows").Bnode("addr_1"),
+		BnodePred("addr_1", "name").StringLiteral("John"),
+	}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := Triples(d.Removed), (Triples{SubjPred("me", "age").IntegerLiteral(25)}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if err := d.ApplyTo(a); err != nil {
+		t.Fatal(err)
+	}
+	if d := IsomorphicDiff(a.Snapshot(), b.Snapshot()); !d.IsEmpty() {
+		t.Fatalf("expected empty diff, got %v", d)
+	}
+	if d := Diff(b.Snapshot(), b.Snapshot()); !d.IsEmpty() {
+		t.Fatalf("expected empty diff, got %v", d)
+	}
+}
+
+func TestPatchEncodeDecode(t *testing.T) {
+	d := GraphDiff{
+		Added: []Triple{
+			SubjPred("me", "age").IntegerLiteral(26),
+			SubjPred("me", "knows").Bnode("you"),
+			BnodePred("you", "name").StringLiteralWithLang("Jean\nJo", "fr"),
+			SubjPred("me", "motto").StringLiteral(`say "hi" . \o/`),
+		},
+		Removed: []Triple{SubjPred("me", "age").IntegerLiteral(25)},
+	}
+
+	var buf bytes.Buffer
+	if err := NewPatchEncoder(&buf).EncodePatch(d); err != nil {
+		t.Fatal(err)
+	}
+	expected := `TX .
+D <me> <age> "25"^^<http://www.w3.org/2001/XMLSchema#integer> .
+A <me> <age> "26"^^<http://www.w3.org/2001/XMLSchema#integer> .
+A <me> <knows> _:you .
+A _:you <name> "Jean\nJo"@fr .
+A <me> <motto> "say \"hi\" . \\o/" .
+TC .
+`
+	if got, want := buf.String(), expected; got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	decoded, err := NewPatchDecoder(&buf).DecodePatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Triples(decoded.Added), Triples(d.Added); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := Triples(decoded.Removed), Triples(d.Removed); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// labels generated by the decoders round-trip
+	src := NewSource()
+	src.Add(BnodePred("genid1", "p").Resource("o"))
+	target := NewSource()
+	target.Add(BnodePred("genid2", "p").Resource("o"))
+	buf.Reset()
+	if err := NewPatchEncoder(&buf).EncodePatch(Diff(src.Snapshot(), target.Snapshot())); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err = NewPatchDecoder(&buf).DecodePatch(); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.ApplyTo(src); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Triples(src.CopyTriples()), Triples(target.CopyTriples()); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if tris := src.Snapshot().WithSubject("genid1"); len(tris) != 0 {
+		t.Fatalf("expected genid1 to be removed, got %v", tris)
+	}
+
+	patch := `H id <uuid:0123> .
+# a comment
+TX .
+A <one> <two> <three> .
+D <four> <five> <six> .
+TC .
+TX .
+A <seven> <eight> <nine> .
+TA .
+A <four> <five> <six> .
+D <one> <two> <three> .
+`
+	decoded, err = NewPatchDecoder(strings.NewReader(patch)).DecodePatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Triples(decoded.Added), (Triples{SubjPred("four", "five").Resource("six")}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := Triples(decoded.Removed), (Triples{SubjPred("one", "two").Resource("three")}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	for _, invalid := range []string{
+		"A <one> <two> .",
+		"PA ex <http://ex.org/> .",
+		"X <one> <two> <three> .",
+		"TX .\nA <one> <two> <three> .\n",
+	} {
+		if _, err := NewPatchDecoder(strings.NewReader(invalid)).DecodePatch(); err == nil {
+			t.Fatalf("patch %s: expected error, got none", invalid)
+		}
+	}
+}
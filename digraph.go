@@ -0,0 +1,345 @@
+package triplestore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Digraph is a directed graph defined from a RDF Graph
+// when given a set of predicates as edges and
+// considering triples from and pointing to RDF resources
+//
+// Contrary to a Tree, nodes can have several parents and edges can form
+// cycles. Nodes and neighbours are always visited in lexical order.
+type Digraph struct {
+	g          RDFGraph
+	predicates []string
+	reversed   bool
+
+	once         sync.Once
+	nodes        []string
+	successors   map[string][]string
+	predecessors map[string][]string
+}
+
+func NewDigraph(g RDFGraph, preds ...string) *Digraph {
+	if g == nil {
+		panic("given RDF graph is nil")
+	}
+	return &Digraph{g: g, predicates: preds}
+}
+
+// Reverse returns the digraph with the direction of its edges reversed,
+// for instance to traverse ancestors instead of descendants
+func (d *Digraph) Reverse() *Digraph {
+	return &Digraph{g: d.g, predicates: d.predicates, reversed: !d.reversed}
+}
+
+func (d *Digraph) edges() {
+	d.once.Do(func() {
+		succ := make(map[string]map[string]bool)
+		pred := make(map[string]map[string]bool)
+		nodes := make(map[string]bool)
+		add := func(m map[string]map[string]bool, from, to string) {
+			if m[from] == nil {
+				m[from] = make(map[string]bool)
+			}
+			m[from][to] = true
+		}
+		for _, p := range d.predicates {
+			for _, t := range d.g.WithPredicate(p) {
+				tt := t.(*triple)
+				obj, ok := tt.obj.iri()
+				if !ok || tt.isSubBnode {
+					continue
+				}
+				from, to := tt.sub, obj
+				if d.reversed {
+					from, to = to, from
+				}
+				add(succ, from, to)
+				add(pred, to, from)
+				nodes[from], nodes[to] = true, true
+			}
+		}
+
+		d.nodes = sortedStrings(nodes)
+		d.successors = make(map[string][]string, len(succ))
+		for n, set := range succ {
+			d.successors[n] = sortedStrings(set)
+		}
+		d.predecessors = make(map[string][]string, len(pred))
+		for n, set := range pred {
+			d.predecessors[n] = sortedStrings(set)
+		}
+	})
+}
+
+// Nodes returns the subjects and objects of the edges
+func (d *Digraph) Nodes() []string {
+	d.edges()
+	return append([]string(nil), d.nodes...)
+}
+
+// Successors returns the nodes the given node has an edge to
+func (d *Digraph) Successors(node string) []string {
+	d.edges()
+	return append([]string(nil), d.successors[node]...)
+}
+
+// Predecessors returns the nodes having an edge to the given node
+func (d *Digraph) Predecessors(node string) []string {
+	d.edges()
+	return append([]string(nil), d.predecessors[node]...)
+}
+
+// TraverseBFS visits each node reachable from the given node once, in
+// breadth first order, with its distance to the given node. Nodes further
+// than maxDepth are not visited, a negative maxDepth meaning no limit.
+func (d *Digraph) TraverseBFS(node string, maxDepth int, each func(RDFGraph, string, int) error) error {
+	d.edges()
+	visited := map[string]bool{node: true}
+	current := []string{node}
+	for depth := 0; len(current) > 0; depth++ {
+		var next []string
+		for _, n := range current {
+			if err := each(d.g, n, depth); err != nil {
+				return err
+			}
+			if depth == maxDepth {
+				continue
+			}
+			for _, s := range d.successors[n] {
+				if !visited[s] {
+					visited[s] = true
+					next = append(next, s)
+				}
+			}
+		}
+		current = next
+	}
+	return nil
+}
+
+// TraverseDFS visits each node reachable from the given node once, in
+// pre-order depth first order, with the depth it is first reached at. Nodes
+// deeper than maxDepth are not visited, a negative maxDepth meaning no limit.
+// With a limit, a node first reached deeper is expanded again when reached
+// at a shallower depth, so that all the nodes within maxDepth are visited.
+func (d *Digraph) TraverseDFS(node string, maxDepth int, each func(RDFGraph, string, int) error) error {
+	d.edges()
+	depths := make(map[string]int)
+	var visit func(string, int) error
+	visit = func(n string, depth int) error {
+		if _, ok := depths[n]; !ok {
+			if err := each(d.g, n, depth); err != nil {
+				return err
+			}
+		}
+		depths[n] = depth
+		if depth == maxDepth {
+			return nil
+		}
+		for _, s := range d.successors[n] {
+			if found, ok := depths[s]; ok && (maxDepth < 0 || found <= depth+1) {
+				continue
+			}
+			if err := visit(s, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(node, 0)
+}
+
+// ShortestPath returns the nodes of a path with the fewest edges from a
+// node to another, both included, false when there is no such path
+func (d *Digraph) ShortestPath(from, to string) ([]string, bool) {
+	d.edges()
+	return shortestPath(d.successors, from, to)
+}
+
+func shortestPath(successors map[string][]string, from, to string) ([]string, bool) {
+	parents := map[string]string{from: ""}
+	current := []string{from}
+	for len(current) > 0 {
+		var next []string
+		for _, n := range current {
+			if n == to {
+				var path []string
+				for ; n != from; n = parents[n] {
+					path = append(path, n)
+				}
+				path = append(path, from)
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path, true
+			}
+			for _, s := range successors[n] {
+				if _, ok := parents[s]; !ok {
+					parents[s] = n
+					next = append(next, s)
+				}
+			}
+		}
+		current = next
+	}
+	return nil, false
+}
+
+// Paths returns all the paths without repeated nodes from a node to
+// another of at most maxHops edges, a negative maxHops meaning no limit.
+// Beware that the number of paths can grow exponentially without limit.
+func (d *Digraph) Paths(from, to string, maxHops int) [][]string {
+	d.edges()
+	var paths [][]string
+	path := []string{from}
+	onPath := map[string]bool{from: true}
+	var walk func(string)
+	walk = func(n string) {
+		if n == to {
+			paths = append(paths, append([]string(nil), path...))
+			return
+		}
+		if len(path)-1 == maxHops {
+			return
+		}
+		for _, s := range d.successors[n] {
+			if onPath[s] {
+				continue
+			}
+			onPath[s] = true
+			path = append(path, s)
+			walk(s)
+			path = path[:len(path)-1]
+			onPath[s] = false
+		}
+	}
+	walk(from)
+	return paths
+}
+
+// StronglyConnectedComponents returns the sets of nodes mutually reachable
+// from one another, each sorted, in reverse topological order: no component
+// has an edge to a component given after it.
+func (d *Digraph) StronglyConnectedComponents() [][]string {
+	d.edges()
+	// Tarjan's algorithm
+	var (
+		components [][]string
+		stack      []string
+		counter    int
+		index      = make(map[string]int)
+		lowlink    = make(map[string]int)
+		onStack    = make(map[string]bool)
+	)
+	var connect func(string)
+	connect = func(n string) {
+		index[n], lowlink[n] = counter, counter
+		counter++
+		stack = append(stack, n)
+		onStack[n] = true
+
+		for _, s := range d.successors[n] {
+			if _, ok := index[s]; !ok {
+				connect(s)
+				if lowlink[s] < lowlink[n] {
+					lowlink[n] = lowlink[s]
+				}
+			} else if onStack[s] && index[s] < lowlink[n] {
+				lowlink[n] = index[s]
+			}
+		}
+
+		if lowlink[n] == index[n] {
+			var comp []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				comp = append(comp, top)
+				if top == n {
+					break
+				}
+			}
+			sort.Strings(comp)
+			components = append(components, comp)
+		}
+	}
+	for _, n := range d.nodes {
+		if _, ok := index[n]; !ok {
+			connect(n)
+		}
+	}
+	return components
+}
+
+// Cycle returns the nodes of a cycle, the first node having an edge
+// from the last one, false when the digraph is acyclic
+func (d *Digraph) Cycle() ([]string, bool) {
+	d.edges()
+	for _, comp := range d.StronglyConnectedComponents() {
+		n := comp[0]
+		if isOneOf(d.successors[n], n) {
+			return []string{n}, true
+		}
+		if len(comp) == 1 {
+			continue
+		}
+		inComp := make(map[string]bool, len(comp))
+		for _, c := range comp {
+			inComp[c] = true
+		}
+		within := make(map[string][]string, len(comp))
+		for _, c := range comp {
+			for _, s := range d.successors[c] {
+				if inComp[s] {
+					within[c] = append(within[c], s)
+				}
+			}
+		}
+		// n reaches back from any of its successors in the component
+		return shortestPath(within, within[n][0], n)
+	}
+	return nil, false
+}
+
+// TopologicalSort returns the nodes ordered so that each node comes before
+// the nodes it has an edge to, lexical order breaking ties. It returns an
+// error with a cycle when the digraph is not acyclic.
+func (d *Digraph) TopologicalSort() ([]string, error) {
+	d.edges()
+	if cycle, ok := d.Cycle(); ok {
+		return nil, fmt.Errorf("digraph[%s]: cycle %s", strings.Join(d.predicates, ","), strings.Join(append(cycle, cycle[0]), " -> "))
+	}
+
+	inDegree := make(map[string]int, len(d.nodes))
+	var ready []string
+	for _, n := range d.nodes {
+		inDegree[n] = len(d.predecessors[n])
+		if inDegree[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	sorted := make([]string, 0, len(d.nodes))
+	for len(ready) > 0 {
+		n := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, n)
+		for _, s := range d.successors[n] {
+			inDegree[s]--
+			if inDegree[s] == 0 {
+				i := sort.SearchStrings(ready, s)
+				ready = append(ready, "")
+				copy(ready[i+1:], ready[i:])
+				ready[i] = s
+			}
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,124 @@
+package triplestore_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestDigraph(t *testing.T) {
+	s := tstore.NewSource()
+	s.Add(
+		tstore.SubjPred("app", "dependsOn").Resource("http"),
+		tstore.SubjPred("app", "dependsOn").Resource("log"),
+		tstore.SubjPred("http", "dependsOn").Resource("net"),
+		tstore.SubjPred("http", "dependsOn").Resource("log"),
+		tstore.SubjPred("log", "dependsOn").Resource("io"),
+		tstore.SubjPred("net", "buildsWith").Resource("io"),
+		tstore.SubjPred("net", "dependsOn").StringLiteral("ignored"),
+		tstore.SubjPred("app", "other").Resource("ignored"),
+	)
+	d := tstore.NewDigraph(s.Snapshot(), "dependsOn", "buildsWith")
+
+	var result bytes.Buffer
+	each := func(gph tstore.RDFGraph, node string, depth int) error {
+		result.WriteString(fmt.Sprintf("(%d)%s ", depth, node))
+		return nil
+	}
+
+	t.Run("traversals", func(t *testing.T) {
+		tcases := []struct {
+			traverse func(string, int, func(tstore.RDFGraph, string, int) error) error
+			node     string
+			maxDepth int
+			want     string
+		}{
+			{d.TraverseBFS, "app", -1, "(0)app (1)http (1)log (2)net (2)io "},
+			{d.TraverseBFS, "app", 1, "(0)app (1)http (1)log "},
+			{d.TraverseDFS, "app", -1, "(0)app (1)http (2)log (3)io (2)net "},
+			{d.TraverseDFS, "app", 2, "(0)app (1)http (2)log (2)net (2)io "}, // io through log found again at depth 1
+			{d.TraverseDFS, "app", 1, "(0)app (1)http (1)log "},
+			{d.Reverse().TraverseBFS, "io", -1, "(0)io (1)log (1)net (2)app (2)http "},
+			{d.TraverseDFS, "none", -1, "(0)none "},
+		}
+		for i, tc := range tcases {
+			result.Reset()
+			if err := tc.traverse(tc.node, tc.maxDepth, each); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := result.String(), tc.want; got != want {
+				t.Fatalf("case %d: got %s, want %s", i+1, got, want)
+			}
+		}
+	})
+
+	t.Run("paths", func(t *testing.T) {
+		if got, ok := d.ShortestPath("app", "io"); !ok || !reflect.DeepEqual(got, []string{"app", "log", "io"}) {
+			t.Fatalf("got %v", got)
+		}
+		if _, ok := d.ShortestPath("io", "app"); ok {
+			t.Fatal("expected no path")
+		}
+		want := [][]string{{"app", "http", "log", "io"}, {"app", "http", "net", "io"}, {"app", "log", "io"}}
+		if got := d.Paths("app", "io", -1); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if got := d.Paths("app", "io", 2); !reflect.DeepEqual(got, want[2:]) {
+			t.Fatalf("got %v, want %v", got, want[2:])
+		}
+	})
+
+	t.Run("acyclic", func(t *testing.T) {
+		if cycle, ok := d.Cycle(); ok {
+			t.Fatalf("unexpected cycle %v", cycle)
+		}
+		sorted, err := d.TopologicalSort()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := sorted, []string{"app", "http", "log", "net", "io"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if got, want := len(d.StronglyConnectedComponents()), 5; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		s.Add(
+			tstore.SubjPred("io", "dependsOn").Resource("app"),
+			tstore.SubjPred("solo", "dependsOn").Resource("solo"),
+		)
+		d := tstore.NewDigraph(s.Snapshot(), "dependsOn", "buildsWith")
+
+		want := [][]string{{"app", "http", "io", "log", "net"}, {"solo"}}
+		if got := d.StronglyConnectedComponents(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		cycle, ok := d.Cycle()
+		if !ok {
+			t.Fatal("expected cycle")
+		}
+		for i, n := range cycle {
+			if next := cycle[(i+1)%len(cycle)]; !containsString(d.Successors(n), next) {
+				t.Fatalf("%v: no edge from %s to %s", cycle, n, next)
+			}
+		}
+		if _, err := d.TopologicalSort(); err == nil || !strings.Contains(err.Error(), "cycle") {
+			t.Fatalf("expected cycle error, got %v", err)
+		}
+	})
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
@@ -62,6 +62,10 @@ func Resource(s string) Object {
 	return object{resource: s}
 }
 
+func Bnode(s string) Object {
+	return object{bnode: s, isBnode: true}
+}
+
 func (b *tripleBuilder) Lang(l string) *tripleBuilder {
 	b.langtag = l
 	return b
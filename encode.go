@@ -87,7 +87,7 @@ func (enc *binaryEncoder) Encode(tris ...Triple) error {
 }
 
 func (enc *binaryEncoder) writeTriple(t Triple, buf *bytes.Buffer) error {
-	if err := encodeBinTriple(t, buf); err != nil {
+	if err := encodeBinTriple(t, buf, escapeStringLiteral); err != nil {
 		return err
 	}
 	if _, err := enc.w.Write(buf.Bytes()); err != nil {
@@ -97,7 +97,9 @@ func (enc *binaryEncoder) writeTriple(t Triple, buf *bytes.Buffer) error {
 	return nil
 }
 
-func encodeBinTriple(t Triple, buff *bytes.Buffer) error {
+// encodeBinTriple writes the triple in binary, with the string literal values
+// escaped by the given function
+func encodeBinTriple(t Triple, buff *bytes.Buffer, escape func(string) string) error {
 	sub, pred := t.Subject(), t.Predicate()
 
 	binary.Write(buff, binary.BigEndian, t.(*triple).isSubBnode)
@@ -123,7 +125,7 @@ func encodeBinTriple(t Triple, buff *bytes.Buffer) error {
 
 		litVal := lit.Value()
 		if lit.Type() == XsdString {
-			litVal = escapeStringLiteral(litVal)
+			litVal = escape(litVal)
 		}
 		binary.Write(buff, binary.BigEndian, wordLength(len(litVal)))
 		buff.WriteString(litVal)
@@ -191,6 +193,25 @@ func (enc *ntriplesEncoder) Encode(tris ...Triple) error {
 }
 
 func encodeNTriple(t Triple, ctx *Context, buff *bytes.Buffer) {
+	encodeNTerms(t, ctx, buff)
+	buff.Write([]byte(" .\n"))
+}
+
+// encodeNQuad writes the triple followed by its graph term,
+// omitted for the default graph
+func encodeNQuad(t Triple, graph string, ctx *Context, buff *bytes.Buffer) {
+	encodeNTerms(t, ctx, buff)
+	switch {
+	case graph == DefaultGraph:
+	case strings.HasPrefix(graph, "_:"):
+		buff.WriteString(" " + graph)
+	default:
+		buff.WriteString(" <" + buildIRI(ctx, graph) + ">")
+	}
+	buff.Write([]byte(" .\n"))
+}
+
+func encodeNTerms(t Triple, ctx *Context, buff *bytes.Buffer) {
 	var sub string
 	if tt := t.(*triple); tt.isSubBnode {
 		sub = "_:" + buildIRI(ctx, t.Subject())
@@ -224,7 +245,101 @@ func encodeNTriple(t Triple, ctx *Context, buff *bytes.Buffer) {
 			}
 		}
 	}
-	buff.Write([]byte(" .\n"))
+}
+
+// QuadEncoder encodes triples of named graphs. Triples encoded
+// without graph name belong to the default graph.
+type QuadEncoder interface {
+	Encoder
+	EncodeQuads(...Quad) error
+}
+
+type QuadStreamEncoder interface {
+	StreamEncoder
+	StreamEncodeQuads(context.Context, <-chan Quad) error
+}
+
+type nquadsEncoder struct {
+	w io.Writer
+	c *Context
+}
+
+func NewNQuadsStreamEncoder(w io.Writer) QuadStreamEncoder {
+	return &nquadsEncoder{w: w}
+}
+
+func NewNQuadsEncoder(w io.Writer) QuadEncoder {
+	return &nquadsEncoder{w: w}
+}
+
+func NewNQuadsEncoderWithContext(w io.Writer, c *Context) QuadEncoder {
+	return &nquadsEncoder{w: w, c: c}
+}
+
+func (enc *nquadsEncoder) Encode(tris ...Triple) error {
+	var buff bytes.Buffer
+	for _, t := range tris {
+		encodeNQuad(t, DefaultGraph, enc.c, &buff)
+	}
+	_, err := enc.w.Write(buff.Bytes())
+	return err
+}
+
+func (enc *nquadsEncoder) EncodeQuads(quads ...Quad) error {
+	var buff bytes.Buffer
+	for _, q := range quads {
+		encodeNQuad(q.Triple(), q.Graph(), enc.c, &buff)
+	}
+	_, err := enc.w.Write(buff.Bytes())
+	return err
+}
+
+func (enc *nquadsEncoder) StreamEncode(ctx context.Context, triples <-chan Triple) error {
+	if triples == nil {
+		return nil
+	}
+	quads := make(chan Quad)
+	go func() {
+		defer close(quads)
+		for {
+			select {
+			case tri, ok := <-triples:
+				if !ok {
+					return
+				}
+				select {
+				case quads <- NewQuad(tri, DefaultGraph):
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return enc.StreamEncodeQuads(ctx, quads)
+}
+
+func (enc *nquadsEncoder) StreamEncodeQuads(ctx context.Context, quads <-chan Quad) error {
+	if quads == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	finalWrite := func() error {
+		_, err := enc.w.Write(buf.Bytes())
+		return err
+	}
+	for {
+		select {
+		case q, ok := <-quads:
+			if !ok {
+				return finalWrite()
+			}
+			encodeNQuad(q.Triple(), q.Graph(), enc.c, &buf)
+		case <-ctx.Done():
+			return finalWrite()
+		}
+	}
 }
 
 func buildIRI(ctx *Context, id string) string {
@@ -245,6 +360,20 @@ func buildIRI(ctx *Context, id string) string {
 	return id
 }
 
+// expandPrefixedIRI expands an IRI given in its prefixed form
+// (ex: rdf:type) with the namespaces of the context
+func expandPrefixedIRI(c *Context, id string) string {
+	if c == nil {
+		return id
+	}
+	for prefix, ns := range c.Prefixes {
+		if strings.HasPrefix(id, prefix+":") && !strings.HasPrefix(id, prefix+"://") {
+			return ns + strings.TrimPrefix(id, prefix+":")
+		}
+	}
+	return id
+}
+
 type dotGraphEncoder struct {
 	pred string
 	w    io.Writer
@@ -309,3 +438,8 @@ var escaper = strings.NewReplacer("\n", "\\n", "\r", "\\r")
 func escapeStringLiteral(s string) string {
 	return escaper.Replace(s)
 }
+
+// verbatim returns the string as is, where an escaping or relabelling is expected
+func verbatim(s string) string {
+	return s
+}
@@ -0,0 +1,508 @@
+package triplestore
+
+import "math/bits"
+
+// pset is a persistent set of ID tuples and pmap a persistent map from ID
+// tuples to such sets, both implemented as compressed hash array mapped
+// tries. Updates return a new version sharing its unchanged nodes with the
+// original one, which is left unmodified.
+//
+// Updates given a non nil edit token modify in place the nodes created
+// with the same token. A batch of updates thus allocates each node once,
+// as long as the token is not used anymore once the batch is done.
+type pset struct {
+	root *pnode
+	// the only key of a set of one key, kept without a node
+	one   idKey
+	count uint32
+}
+
+type pmap struct {
+	root  *pnode
+	count int
+}
+
+// A pnode holds its keys inline and its sub-tries apart, the datamap and
+// the nodemap telling which hash fragments lead to a key or to a sub-trie.
+// Nodes of maps hold the value of each key, nodes of sets hold no values.
+type pnode struct {
+	datamap, nodemap uint32
+	keys             []idKey
+	vals             []pset
+	children         []*pnode
+	edit             *int
+}
+
+const (
+	pmapBits = 5
+	pmapMask = 1<<pmapBits - 1
+	// beyond this shift, hashes are exhausted and nodes are lists of colliding keys
+	pmapMaxShift = 64
+)
+
+func hashKey(key idKey) uint64 {
+	h := uint64(key.a)*0x9E3779B97F4A7C15 ^ uint64(key.b)*0xC2B2AE3D27D4EB4F ^ uint64(key.c)*0x165667B19E3779F9
+	// finalizer of murmur3
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (s pset) len() int {
+	return int(s.count)
+}
+
+func (s pset) has(key idKey) bool {
+	if s.root == nil {
+		return s.count == 1 && s.one == key
+	}
+	_, ok := s.root.get(0, hashKey(key), key)
+	return ok
+}
+
+func (s pset) add(key idKey, edit *int) pset {
+	switch {
+	case s.count == 0:
+		return pset{one: key, count: 1}
+	case s.root == nil:
+		if s.one == key {
+			return s
+		}
+		root := &pnode{edit: edit}
+		root, _ = root.update(0, hashKey(s.one), s.one, nil, edit)
+		s = pset{root: root, count: 1}
+	}
+	root, added := s.root.update(0, hashKey(key), key, nil, edit)
+	return pset{root: root, count: s.count + uint32(added)}
+}
+
+func (s pset) remove(key idKey, edit *int) pset {
+	if s.root == nil {
+		if s.count == 1 && s.one == key {
+			return pset{}
+		}
+		return s
+	}
+	root, removed := s.root.delete(0, hashKey(key), key, edit)
+	switch {
+	case !removed:
+		return s
+	case s.count == 2:
+		// keep the remaining key without a node
+		return pset{one: root.keys[0], count: 1}
+	}
+	return pset{root: root, count: s.count - 1}
+}
+
+func (s pset) each(fn func(key idKey)) {
+	s.walk(func(key idKey) bool {
+		fn(key)
+		return true
+	})
+}
+
+// walk calls fn on each key until it returns false. It returns false when interrupted.
+func (s pset) walk(fn func(key idKey) bool) bool {
+	if s.root == nil {
+		return s.count == 0 || fn(s.one)
+	}
+	return s.root.walk(func(key idKey, _ pset) bool { return fn(key) })
+}
+
+func (m pmap) len() int {
+	return m.count
+}
+
+func (m pmap) get(key idKey) (pset, bool) {
+	if m.root == nil {
+		return pset{}, false
+	}
+	return m.root.get(0, hashKey(key), key)
+}
+
+// update sets the value of the key to fn of its current value, an empty set
+// when the key is absent. The key is removed when fn returns an empty set.
+func (m pmap) update(key idKey, fn func(pset) pset, edit *int) pmap {
+	root := m.root
+	if root == nil {
+		root = &pnode{edit: edit}
+	}
+	root, delta := root.update(0, hashKey(key), key, fn, edit)
+	return pmap{root: root, count: m.count + delta}
+}
+
+func (m pmap) each(fn func(key idKey, val pset)) {
+	m.walk(func(key idKey, val pset) bool {
+		fn(key, val)
+		return true
+	})
+}
+
+// walk calls fn on each entry until it returns false. It returns false when interrupted.
+func (m pmap) walk(fn func(key idKey, val pset) bool) bool {
+	if m.root == nil {
+		return true
+	}
+	return m.root.walk(fn)
+}
+
+func index(bitmap, bit uint32) int {
+	return bits.OnesCount32(bitmap & (bit - 1))
+}
+
+func (n *pnode) val(i int) pset {
+	if n.vals == nil {
+		return pset{}
+	}
+	return n.vals[i]
+}
+
+// editable returns the node itself when created with the edit token, a copy otherwise
+func (n *pnode) editable(edit *int) *pnode {
+	if edit != nil && n.edit == edit {
+		return n
+	}
+	c := &pnode{datamap: n.datamap, nodemap: n.nodemap, edit: edit}
+	c.keys = append(make([]idKey, 0, len(n.keys)+1), n.keys...)
+	if n.vals != nil {
+		c.vals = append(make([]pset, 0, len(n.vals)+1), n.vals...)
+	}
+	if len(n.children) > 0 {
+		c.children = append([]*pnode(nil), n.children...)
+	}
+	return c
+}
+
+func (n *pnode) get(shift uint, h uint64, key idKey) (pset, bool) {
+	for ; shift < pmapMaxShift; shift += pmapBits {
+		bit := uint32(1) << ((h >> shift) & pmapMask)
+		switch {
+		case n.datamap&bit != 0:
+			i := index(n.datamap, bit)
+			if n.keys[i] == key {
+				return n.val(i), true
+			}
+			return pset{}, false
+		case n.nodemap&bit != 0:
+			n = n.children[index(n.nodemap, bit)]
+		default:
+			return pset{}, false
+		}
+	}
+	for i, k := range n.keys {
+		if k == key {
+			return n.val(i), true
+		}
+	}
+	return pset{}, false
+}
+
+// update sets the value of the key to fn of its current value, removing the
+// key when fn returns an empty set. In the nodes of sets, fn is nil and the
+// key is added. It returns the difference in the number of keys.
+func (n *pnode) update(shift uint, h uint64, key idKey, fn func(pset) pset, edit *int) (*pnode, int) {
+	if shift >= pmapMaxShift {
+		for i, k := range n.keys {
+			if k == key {
+				return n.updateKey(i, 0, fn, edit)
+			}
+		}
+		return n.insert(len(n.keys), 0, key, fn, edit)
+	}
+
+	bit := uint32(1) << ((h >> shift) & pmapMask)
+	switch {
+	case n.datamap&bit != 0:
+		i := index(n.datamap, bit)
+		if n.keys[i] == key {
+			return n.updateKey(i, bit, fn, edit)
+		}
+		var val pset
+		if fn != nil {
+			if val = fn(pset{}); val.len() == 0 {
+				return n, 0
+			}
+		}
+		// push down the existing key in a sub-trie holding both keys
+		child := &pnode{edit: edit}
+		child, _ = child.update(shift+pmapBits, hashKey(n.keys[i]), n.keys[i], constant(n.val(i), fn != nil), edit)
+		child, _ = child.update(shift+pmapBits, h, key, constant(val, fn != nil), edit)
+		n = n.editable(edit)
+		n.removeKey(i)
+		n.datamap &^= bit
+		n.insertChild(index(n.nodemap, bit), child)
+		n.nodemap |= bit
+		return n, 1
+	case n.nodemap&bit != 0:
+		j := index(n.nodemap, bit)
+		child, delta := n.children[j].update(shift+pmapBits, h, key, fn, edit)
+		if child == n.children[j] && delta == 0 {
+			// unchanged or edited in place
+			return n, 0
+		}
+		return n.replaceChild(j, bit, child, edit), delta
+	default:
+		return n.insert(index(n.datamap, bit), bit, key, fn, edit)
+	}
+}
+
+// constant returns a function giving the value, nil in the nodes of sets
+func constant(val pset, valued bool) func(pset) pset {
+	if !valued {
+		return nil
+	}
+	return func(pset) pset { return val }
+}
+
+func (n *pnode) updateKey(i int, bit uint32, fn func(pset) pset, edit *int) (*pnode, int) {
+	if fn == nil {
+		return n, 0
+	}
+	if val := fn(n.vals[i]); val.len() > 0 {
+		n = n.editable(edit)
+		n.vals[i] = val
+		return n, 0
+	}
+	return n.without(i, bit, edit), -1
+}
+
+func (n *pnode) insert(i int, bit uint32, key idKey, fn func(pset) pset, edit *int) (*pnode, int) {
+	var val pset
+	if fn != nil {
+		if val = fn(pset{}); val.len() == 0 {
+			return n, 0
+		}
+	}
+	n = n.editable(edit)
+	n.insertKey(i, key, fn != nil, val)
+	n.datamap |= bit
+	return n, 1
+}
+
+// delete returns a nil node when it has no entries left
+func (n *pnode) delete(shift uint, h uint64, key idKey, edit *int) (*pnode, bool) {
+	if shift >= pmapMaxShift {
+		for i, k := range n.keys {
+			if k == key {
+				return n.without(i, 0, edit), true
+			}
+		}
+		return n, false
+	}
+
+	bit := uint32(1) << ((h >> shift) & pmapMask)
+	switch {
+	case n.datamap&bit != 0:
+		i := index(n.datamap, bit)
+		if n.keys[i] != key {
+			return n, false
+		}
+		return n.without(i, bit, edit), true
+	case n.nodemap&bit != 0:
+		j := index(n.nodemap, bit)
+		child, removed := n.children[j].delete(shift+pmapBits, h, key, edit)
+		if !removed {
+			return n, false
+		}
+		return n.replaceChild(j, bit, child, edit), true
+	default:
+		return n, false
+	}
+}
+
+// without returns the node without the key at i, nil when it has no entries left
+func (n *pnode) without(i int, bit uint32, edit *int) *pnode {
+	if len(n.keys) == 1 && len(n.children) == 0 {
+		return nil
+	}
+	n = n.editable(edit)
+	n.removeKey(i)
+	n.datamap &^= bit
+	return n
+}
+
+// replaceChild replaces the sub-trie at j, pulling up its key when a single one is left.
+// It returns nil when the node has no entries left.
+func (n *pnode) replaceChild(j int, bit uint32, child *pnode, edit *int) *pnode {
+	if child == nil && len(n.keys) == 0 && len(n.children) == 1 {
+		return nil
+	}
+	n = n.editable(edit)
+	switch {
+	case child == nil:
+		n.removeChild(j)
+		n.nodemap &^= bit
+	case len(child.keys) == 1 && len(child.children) == 0:
+		n.removeChild(j)
+		n.nodemap &^= bit
+		n.insertKey(index(n.datamap, bit), child.keys[0], child.vals != nil, child.val(0))
+		n.datamap |= bit
+	default:
+		n.children[j] = child
+	}
+	return n
+}
+
+func (n *pnode) insertKey(i int, key idKey, valued bool, val pset) {
+	n.keys = append(n.keys, idKey{})
+	copy(n.keys[i+1:], n.keys[i:])
+	n.keys[i] = key
+	if valued {
+		n.vals = append(n.vals, pset{})
+		copy(n.vals[i+1:], n.vals[i:])
+		n.vals[i] = val
+	}
+}
+
+func (n *pnode) removeKey(i int) {
+	copy(n.keys[i:], n.keys[i+1:])
+	n.keys = n.keys[:len(n.keys)-1]
+	if n.vals != nil {
+		copy(n.vals[i:], n.vals[i+1:])
+		n.vals[len(n.vals)-1] = pset{}
+		n.vals = n.vals[:len(n.vals)-1]
+	}
+}
+
+func (n *pnode) insertChild(j int, child *pnode) {
+	n.children = append(n.children, nil)
+	copy(n.children[j+1:], n.children[j:])
+	n.children[j] = child
+}
+
+func (n *pnode) removeChild(j int) {
+	copy(n.children[j:], n.children[j+1:])
+	n.children[len(n.children)-1] = nil
+	n.children = n.children[:len(n.children)-1]
+}
+
+func (n *pnode) walk(fn func(key idKey, val pset) bool) bool {
+	for i, k := range n.keys {
+		if !fn(k, n.val(i)) {
+			return false
+		}
+	}
+	for _, c := range n.children {
+		if !c.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// A bentry is a key to build a trie of, along with the triple it indexes
+type bentry struct {
+	h        uint64
+	key, tri idKey
+}
+
+// buildMap returns the map from the keys of the entries to the sets of their
+// triples. Nodes are built at once with their final size, so building from
+// scratch is cheaper than adding the keys one by one. Entries are reordered
+// and overwritten, scratch is of the same length.
+func buildMap(entries, scratch []bentry) pmap {
+	for i := range entries {
+		entries[i].h = hashKey(entries[i].key)
+	}
+	root, count := build(0, entries, scratch, true)
+	return pmap{root: root, count: count}
+}
+
+// buildSet returns the set of the triples of the entries, which must be distinct
+func buildSet(entries []bentry, scratch []bentry) pset {
+	if len(entries) == 1 {
+		return pset{one: entries[0].tri, count: 1}
+	}
+	for i := range entries {
+		entries[i].key = entries[i].tri
+		entries[i].h = hashKey(entries[i].tri)
+	}
+	root, _ := build(0, entries, scratch, false)
+	return pset{root: root, count: uint32(len(entries))}
+}
+
+// build returns the node of the entries at the given shift, and its number of
+// distinct keys. Entries are reordered, scratch is of the same length.
+func build(shift uint, entries, scratch []bentry, valued bool) (*pnode, int) {
+	n := &pnode{}
+	if shift >= pmapMaxShift {
+		for len(entries) > 0 {
+			key, same := entries[0].key, 0
+			for i, e := range entries {
+				if e.key == key {
+					entries[i], entries[same] = entries[same], entries[i]
+					same++
+				}
+			}
+			n.keys = append(n.keys, key)
+			if valued {
+				n.vals = append(n.vals, buildSet(entries[:same], scratch[:same]))
+			}
+			entries, scratch = entries[same:], scratch[same:]
+		}
+		return n, len(n.keys)
+	}
+
+	// sort the entries by hash fragment in the scratch space
+	var starts [pmapMask + 2]int
+	for _, e := range entries {
+		starts[(e.h>>shift)&pmapMask+1]++
+	}
+	for i := 1; i < len(starts); i++ {
+		starts[i] += starts[i-1]
+	}
+	next := starts
+	for _, e := range entries {
+		f := (e.h >> shift) & pmapMask
+		scratch[next[f]] = e
+		next[f]++
+	}
+	entries, scratch = scratch, entries
+
+	for f := uint32(0); f <= pmapMask; f++ {
+		bucket := entries[starts[f]:starts[f+1]]
+		if len(bucket) == 0 {
+			continue
+		}
+		single := true
+		for _, e := range bucket[1:] {
+			if e.key != bucket[0].key {
+				single = false
+				break
+			}
+		}
+		if single {
+			n.datamap |= 1 << f
+		} else {
+			n.nodemap |= 1 << f
+		}
+	}
+	n.keys = make([]idKey, 0, bits.OnesCount32(n.datamap))
+	if valued {
+		n.vals = make([]pset, 0, bits.OnesCount32(n.datamap))
+	}
+	if n.nodemap != 0 {
+		n.children = make([]*pnode, 0, bits.OnesCount32(n.nodemap))
+	}
+
+	count := 0
+	for f := uint32(0); f <= pmapMask; f++ {
+		bucket, space := entries[starts[f]:starts[f+1]], scratch[starts[f]:starts[f+1]]
+		switch {
+		case n.datamap&(1<<f) != 0:
+			n.keys = append(n.keys, bucket[0].key)
+			if valued {
+				n.vals = append(n.vals, buildSet(bucket, space))
+			}
+			count++
+		case n.nodemap&(1<<f) != 0:
+			child, c := build(shift+pmapBits, bucket, space, valued)
+			n.children = append(n.children, child)
+			count += c
+		}
+	}
+	return n, count
+}
@@ -0,0 +1,201 @@
+package triplestore
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPersistentMap(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	expected := make(map[idKey]int)
+	var m pmap
+
+	var versions []pmap
+	var expectedVersions []map[idKey]int
+
+	for i := 0; i < 20000; i++ {
+		key := idKey{a: uint32(rnd.Intn(3000)), b: uint32(rnd.Intn(2))}
+		var edit *int
+		if i%3 == 0 {
+			edit = new(int)
+		}
+		if rnd.Intn(3) == 0 {
+			m = m.update(key, func(pset) pset { return pset{} }, edit)
+			delete(expected, key)
+		} else {
+			val := pset{}.add(idKey{c: uint32(i)}, nil)
+			m = m.update(key, func(pset) pset { return val }, edit)
+			expected[key] = i
+		}
+
+		if i%2000 == 0 {
+			versions = append(versions, m)
+			copied := make(map[idKey]int)
+			for k, v := range expected {
+				copied[k] = v
+			}
+			expectedVersions = append(expectedVersions, copied)
+		}
+	}
+	versions = append(versions, m)
+	expectedVersions = append(expectedVersions, expected)
+
+	for i, version := range versions {
+		checkPersistentMap(t, version, expectedVersions[i])
+	}
+}
+
+func TestPersistentSet(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	expected := make(map[idKey]bool)
+	var s pset
+
+	var versions []pset
+	var expectedVersions []map[idKey]bool
+
+	for i := 0; i < 20000; i++ {
+		// few keys so that sets often shrink back to a single key
+		size := 3000
+		if i%5000 < 1000 {
+			size = 3
+		}
+		key := idKey{a: uint32(rnd.Intn(size)), c: uint32(rnd.Intn(2))}
+		var edit *int
+		if i%3 == 0 {
+			edit = new(int)
+		}
+		if rnd.Intn(3) == 0 {
+			s = s.remove(key, edit)
+			delete(expected, key)
+		} else {
+			s = s.add(key, edit)
+			expected[key] = true
+		}
+
+		if i%500 == 0 {
+			versions = append(versions, s)
+			copied := make(map[idKey]bool)
+			for k := range expected {
+				copied[k] = true
+			}
+			expectedVersions = append(expectedVersions, copied)
+		}
+	}
+	versions = append(versions, s)
+	expectedVersions = append(expectedVersions, expected)
+
+	for i, version := range versions {
+		if got, want := version.len(), len(expectedVersions[i]); got != want {
+			t.Fatalf("version %d: got %d, want %d", i, got, want)
+		}
+		for k := range expectedVersions[i] {
+			if !version.has(k) {
+				t.Fatalf("version %d: missing key %v", i, k)
+			}
+		}
+		count := 0
+		version.each(func(k idKey) {
+			count++
+			if !expectedVersions[i][k] {
+				t.Fatalf("version %d: unexpected key %v", i, k)
+			}
+		})
+		if got, want := count, len(expectedVersions[i]); got != want {
+			t.Fatalf("version %d: got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestBuildMap(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	var entries []bentry
+	expected := make(map[idKey]map[idKey]bool)
+	for i := 0; i < 20000; i++ {
+		tri := idKey{a: uint32(i), b: uint32(rnd.Intn(50)), c: uint32(rnd.Intn(3000))}
+		// few keys with many triples, many keys with a single one
+		key := idKey{b: tri.b}
+		if i%2 == 0 {
+			key = idKey{c: tri.c}
+		}
+		entries = append(entries, bentry{key: key, tri: tri})
+		if expected[key] == nil {
+			expected[key] = make(map[idKey]bool)
+		}
+		expected[key][tri] = true
+	}
+
+	m := buildMap(entries, make([]bentry, len(entries)))
+	if got, want := m.len(), len(expected); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	for key, tris := range expected {
+		set, ok := m.get(key)
+		if !ok {
+			t.Fatalf("missing key %v", key)
+		}
+		if got, want := set.len(), len(tris); got != want {
+			t.Fatalf("key %v: got %d, want %d", key, got, want)
+		}
+		for tri := range tris {
+			if !set.has(tri) {
+				t.Fatalf("key %v: missing triple %v", key, tri)
+			}
+		}
+	}
+
+	// built nodes are shared with later versions
+	edit := new(int)
+	for key := range expected {
+		m = m.update(key, func(set pset) pset { return pset{} }, edit)
+		if _, ok := m.get(key); ok {
+			t.Fatalf("key %v: expected removal", key)
+		}
+	}
+	if got, want := m.len(), 0; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestPersistentMapCollisions(t *testing.T) {
+	// keys with the same hash end up in a list at the maximum shift
+	n := &pnode{}
+	for _, k := range []idKey{{a: 1}, {a: 2}, {a: 3}} {
+		n, _ = n.update(pmapMaxShift, 0, k, nil, nil)
+	}
+	if got, want := len(n.keys), 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	deleted, removed := n.delete(pmapMaxShift, 0, idKey{a: 2}, nil)
+	if got, want := removed, true; got != want {
+		t.Fatalf("got %t, want %t", got, want)
+	}
+	if got, want := len(deleted.keys), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := len(n.keys), 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func checkPersistentMap(t *testing.T, m pmap, expected map[idKey]int) {
+	if got, want := m.len(), len(expected); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	for k, v := range expected {
+		got, ok := m.get(k)
+		if !ok || !got.has(idKey{c: uint32(v)}) {
+			t.Fatalf("key %v: got %v, want %d", k, got, v)
+		}
+	}
+	count := 0
+	m.each(func(k idKey, v pset) {
+		count++
+		if got, want := v.one.c, uint32(expected[k]); got != want {
+			t.Fatalf("key %v: got %d, want %d", k, got, want)
+		}
+	})
+	if got, want := count, len(expected); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
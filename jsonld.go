@@ -0,0 +1,612 @@
+package triplestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type jsonldEncoder struct {
+	w io.Writer
+	c *Context
+}
+
+// NewJSONLDEncoder encodes triples as an expanded JSON-LD document
+func NewJSONLDEncoder(w io.Writer) Encoder {
+	return &jsonldEncoder{w: w}
+}
+
+// NewJSONLDEncoderWithContext encodes triples as a compacted JSON-LD document,
+// with a @context generated from the prefixes and base of the given context
+func NewJSONLDEncoderWithContext(w io.Writer, c *Context) Encoder {
+	return &jsonldEncoder{w: w, c: c}
+}
+
+func (enc *jsonldEncoder) Encode(tris ...Triple) error {
+	var doc interface{}
+	nodes := enc.nodes(tris)
+	if enc.c == nil {
+		doc = nodes
+	} else {
+		jsonCtx := make(map[string]interface{})
+		if enc.c.Base != "" {
+			jsonCtx["@base"] = enc.c.Base
+		}
+		for prefix, ns := range enc.c.Prefixes {
+			jsonCtx[prefix] = ns
+		}
+		doc = map[string]interface{}{"@context": jsonCtx, "@graph": nodes}
+	}
+
+	e := json.NewEncoder(enc.w)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	return e.Encode(doc)
+}
+
+// nodes groups triples into node objects sorted by @id
+func (enc *jsonldEncoder) nodes(tris []Triple) []map[string]interface{} {
+	index := make(map[string]map[string]interface{})
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(node map[string]interface{}, key string, val interface{}) {
+		vals, _ := node[key].([]interface{})
+		node[key] = append(vals, val)
+	}
+
+	for _, t := range tris {
+		tt := t.(*triple)
+		if seen[tt.key()] {
+			continue
+		}
+		seen[tt.key()] = true
+
+		id := enc.id(subjectObject(tt))
+		node, ok := index[id]
+		if !ok {
+			node = map[string]interface{}{"@id": id}
+			index[id] = node
+			ids = append(ids, id)
+		}
+
+		pred := expandPrefixedIRI(enc.c, tt.pred)
+		if pred == rdfType || pred == "rdf:type" {
+			if !tt.obj.isLit {
+				add(node, "@type", enc.id(tt.obj))
+				continue
+			}
+		}
+		add(node, enc.iri(pred, false), enc.value(tt.obj))
+	}
+
+	sort.Strings(ids)
+	var out []map[string]interface{}
+	for _, id := range ids {
+		node := index[id]
+		if enc.c != nil {
+			for k, v := range node {
+				if vals, ok := v.([]interface{}); ok && len(vals) == 1 {
+					node[k] = vals[0]
+				}
+			}
+		}
+		out = append(out, node)
+	}
+	return out
+}
+
+func (enc *jsonldEncoder) id(o object) string {
+	if o.isBnode {
+		return "_:" + o.bnode
+	}
+	return enc.iri(o.resource, true)
+}
+
+func (enc *jsonldEncoder) value(o object) interface{} {
+	if !o.isLit {
+		return map[string]interface{}{"@id": enc.id(o)}
+	}
+	if o.lit.langtag != "" {
+		return map[string]interface{}{"@value": o.lit.val, "@language": o.lit.langtag}
+	}
+	if o.lit.typ == XsdString {
+		if enc.c != nil {
+			return o.lit.val
+		}
+		return map[string]interface{}{"@value": o.lit.val}
+	}
+	typ := string(o.lit.typ)
+	if strings.HasPrefix(typ, "xsd:") {
+		typ = o.lit.typ.NTriplesNamespaced()
+	}
+	return map[string]interface{}{"@value": o.lit.val, "@type": enc.iri(typ, false)}
+}
+
+// iri compacts the IRI with the namespaces of the context when compacting,
+// or relatively to the base when the IRI is document relative
+func (enc *jsonldEncoder) iri(id string, documentRelative bool) string {
+	if enc.c == nil {
+		return id
+	}
+	id = expandPrefixedIRI(enc.c, id)
+
+	var prefix, ns string
+	for p, n := range enc.c.Prefixes {
+		local := strings.TrimPrefix(id, n)
+		if strings.HasPrefix(id, n) && !strings.HasPrefix(local, "//") && len(n) > len(ns) {
+			prefix, ns = p, n
+		}
+	}
+	if rel, ok := relativeIRI(enc.c.Base, id); ok && documentRelative && len(enc.c.Base) > len(ns) {
+		return rel
+	}
+	if ns != "" {
+		return prefix + ":" + strings.TrimPrefix(id, ns)
+	}
+	return id
+}
+
+type jsonldDecoder struct {
+	r io.Reader
+}
+
+// NewJSONLDDecoder decodes JSON-LD documents with inline contexts.
+// Remote contexts are not supported. Unlike the JSON-LD algorithms, properties
+// which cannot be expanded to absolute IRIs are kept as is, rather than dropped.
+func NewJSONLDDecoder(r io.Reader) Decoder {
+	return &jsonldDecoder{r: r}
+}
+
+func (d *jsonldDecoder) Decode() ([]Triple, error) {
+	dec := json.NewDecoder(d.r)
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("jsonld: %s", err)
+	}
+
+	p := &jsonldParser{}
+	if err := p.parseValues(doc, newJSONLDContext(), jsonldTerm{}, func(object) {}); err != nil {
+		return nil, fmt.Errorf("jsonld: %s", err)
+	}
+	return p.triples, nil
+}
+
+type jsonldTerm struct {
+	id, typ, language   string
+	hasLanguage, isNull bool
+	list, reverse       bool
+}
+
+type jsonldContext struct {
+	base, vocab, language string
+	terms                 map[string]jsonldTerm
+}
+
+func newJSONLDContext() *jsonldContext {
+	return &jsonldContext{terms: make(map[string]jsonldTerm)}
+}
+
+func (ctx *jsonldContext) clone() *jsonldContext {
+	c := &jsonldContext{base: ctx.base, vocab: ctx.vocab, language: ctx.language, terms: make(map[string]jsonldTerm)}
+	for k, v := range ctx.terms {
+		c.terms[k] = v
+	}
+	return c
+}
+
+// process returns the active context updated with a local context
+func (ctx *jsonldContext) process(local interface{}) (*jsonldContext, error) {
+	switch l := local.(type) {
+	case nil:
+		reset := newJSONLDContext()
+		reset.base = ctx.base
+		return reset, nil
+	case string:
+		return nil, fmt.Errorf("remote context '%s' not supported", l)
+	case []interface{}:
+		var err error
+		for _, c := range l {
+			if ctx, err = ctx.process(c); err != nil {
+				return nil, err
+			}
+		}
+		return ctx, nil
+	case map[string]interface{}:
+		result := ctx.clone()
+		if base, ok := l["@base"]; ok {
+			switch b := base.(type) {
+			case nil:
+				result.base = ""
+			case string:
+				result.base = resolveIRIAgainst(result.base, b)
+			default:
+				return nil, errors.New("invalid @base")
+			}
+		}
+		if vocab, ok := l["@vocab"]; ok {
+			v, _ := vocab.(string)
+			result.vocab = v
+		}
+		if lang, ok := l["@language"]; ok {
+			result.language, _ = lang.(string)
+		}
+
+		defined := make(map[string]bool)
+		for _, term := range sortedKeys(l) {
+			if err := result.defineTerm(l, term, defined); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	default:
+		return nil, errors.New("invalid @context")
+	}
+}
+
+func (ctx *jsonldContext) defineTerm(local map[string]interface{}, term string, defined map[string]bool) error {
+	if strings.HasPrefix(term, "@") || defined[term] {
+		return nil
+	}
+	defined[term] = true
+
+	// prefixes of the term definitions are defined first
+	expand := func(iri string) string {
+		if i := strings.Index(iri, ":"); i > 0 {
+			if _, ok := local[iri[:i]]; ok {
+				ctx.defineTerm(local, iri[:i], defined)
+			}
+		} else if _, ok := local[iri]; ok {
+			ctx.defineTerm(local, iri, defined)
+		}
+		return ctx.expandIRI(iri, true, false)
+	}
+
+	var def jsonldTerm
+	switch v := local[term].(type) {
+	case nil:
+		def.isNull = true
+	case string:
+		def.id = expand(v)
+	case map[string]interface{}:
+		if id, ok := v["@id"].(string); ok {
+			def.id = expand(id)
+		} else if rev, ok := v["@reverse"].(string); ok {
+			def.id, def.reverse = expand(rev), true
+		} else if v["@id"] == nil && hasKey(v, "@id") {
+			def.isNull = true
+		}
+		if typ, ok := v["@type"].(string); ok {
+			if typ == "@id" || typ == "@vocab" {
+				def.typ = typ
+			} else {
+				def.typ = expand(typ)
+			}
+		}
+		if lang, ok := v["@language"]; ok {
+			def.language, _ = lang.(string)
+			def.hasLanguage = true
+		}
+		if container, ok := v["@container"].(string); ok {
+			def.list = container == "@list"
+		}
+	default:
+		return fmt.Errorf("invalid definition of term '%s'", term)
+	}
+
+	if def.id == "" && !def.isNull {
+		if strings.Contains(term, ":") {
+			def.id = expand(term)
+		} else if ctx.vocab != "" {
+			def.id = ctx.vocab + term
+		} else {
+			def.id = term
+		}
+	}
+	ctx.terms[term] = def
+	return nil
+}
+
+// expandIRI expands terms, compact IRIs and, when vocab is set, IRIs
+// relative to the vocabulary. Document relative IRIs are resolved against the base.
+func (ctx *jsonldContext) expandIRI(value string, vocab, documentRelative bool) string {
+	if strings.HasPrefix(value, "@") {
+		return value
+	}
+	if vocab {
+		if t, ok := ctx.terms[value]; ok {
+			if t.isNull {
+				return ""
+			}
+			return t.id
+		}
+	}
+	if i := strings.Index(value, ":"); i >= 0 {
+		prefix, suffix := value[:i], value[i+1:]
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return value
+		}
+		if t, ok := ctx.terms[prefix]; ok && !t.isNull {
+			return t.id + suffix
+		}
+		return value
+	}
+	if vocab && ctx.vocab != "" {
+		return ctx.vocab + value
+	}
+	if documentRelative {
+		return resolveIRIAgainst(ctx.base, value)
+	}
+	return value
+}
+
+func resolveIRIAgainst(base, iri string) string {
+	if base == "" {
+		return iri
+	}
+	return resolveIRI(base, iri)
+}
+
+type jsonldParser struct {
+	triples []Triple
+	genid   int
+}
+
+func (p *jsonldParser) freshBnode() object {
+	p.genid++
+	return object{bnode: generatedBnode(p.genid), isBnode: true}
+}
+
+func (p *jsonldParser) emit(sub object, pred string, obj object) {
+	p.triples = append(p.triples, &triple{
+		sub:        subjectString(sub),
+		isSubBnode: sub.isBnode,
+		pred:       pred,
+		obj:        obj,
+	})
+}
+
+func iriObject(iri string) object {
+	if strings.HasPrefix(iri, "_:") {
+		return object{bnode: documentBnode(strings.TrimPrefix(iri, "_:")), isBnode: true}
+	}
+	return object{resource: iri}
+}
+
+// parseValues parses a JSON-LD value, array of values or node,
+// passing each resulting RDF term to the given function
+func (p *jsonldParser) parseValues(v interface{}, ctx *jsonldContext, term jsonldTerm, each func(object)) error {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		if term.list {
+			list, err := p.parseList(val, ctx, term)
+			if err != nil {
+				return err
+			}
+			each(list)
+			return nil
+		}
+		for _, item := range val {
+			if err := p.parseValues(item, ctx, term, each); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		if _, ok := val["@value"]; ok {
+			lit, ok, err := p.parseValueObject(val, ctx)
+			if err != nil {
+				return err
+			}
+			if ok {
+				each(lit)
+			}
+			return nil
+		}
+		if list, ok := val["@list"]; ok {
+			items, isArray := list.([]interface{})
+			if !isArray {
+				items = []interface{}{list}
+			}
+			head, err := p.parseList(items, ctx, term)
+			if err != nil {
+				return err
+			}
+			each(head)
+			return nil
+		}
+		if set, ok := val["@set"]; ok {
+			return p.parseValues(set, ctx, term, each)
+		}
+		node, err := p.parseNode(val, ctx)
+		if err != nil {
+			return err
+		}
+		each(node)
+		return nil
+	case string:
+		switch term.typ {
+		case "@id":
+			each(iriObject(ctx.expandIRI(val, false, true)))
+		case "@vocab":
+			each(iriObject(ctx.expandIRI(val, true, true)))
+		case "":
+			lit := literal{typ: XsdString, val: val}
+			if term.hasLanguage {
+				lit.langtag = term.language
+			} else {
+				lit.langtag = ctx.language
+			}
+			each(object{isLit: true, lit: lit})
+		default:
+			each(object{isLit: true, lit: literal{typ: xsdTypeFromIRI(term.typ), val: val}})
+		}
+		return nil
+	case json.Number, bool:
+		lit := nativeLiteral(val)
+		if term.typ != "" && !strings.HasPrefix(term.typ, "@") {
+			lit.typ = xsdTypeFromIRI(term.typ)
+		}
+		each(object{isLit: true, lit: lit})
+		return nil
+	default:
+		return fmt.Errorf("unexpected value %v", v)
+	}
+}
+
+func nativeLiteral(v interface{}) literal {
+	switch val := v.(type) {
+	case bool:
+		return literal{typ: XsdBoolean, val: fmt.Sprint(val)}
+	case json.Number:
+		if strings.ContainsAny(val.String(), ".eE") {
+			return literal{typ: XsdDouble, val: val.String()}
+		}
+		return literal{typ: XsdInteger, val: val.String()}
+	default:
+		return literal{typ: XsdString, val: fmt.Sprint(val)}
+	}
+}
+
+func (p *jsonldParser) parseValueObject(m map[string]interface{}, ctx *jsonldContext) (object, bool, error) {
+	var lit literal
+	switch v := m["@value"].(type) {
+	case nil:
+		return object{}, false, nil
+	case string:
+		lit = literal{typ: XsdString, val: v}
+	case json.Number, bool:
+		lit = nativeLiteral(v)
+	default:
+		return object{}, false, errors.New("invalid @value")
+	}
+	if typ, ok := m["@type"].(string); ok {
+		lit.typ = xsdTypeFromIRI(ctx.expandIRI(typ, true, true))
+	}
+	if lang, ok := m["@language"].(string); ok {
+		if _, typed := m["@type"]; typed {
+			return object{}, false, errors.New("value with both @type and @language")
+		}
+		lit.langtag = lang
+	}
+	return object{isLit: true, lit: lit}, true, nil
+}
+
+func (p *jsonldParser) parseList(items []interface{}, ctx *jsonldContext, term jsonldTerm) (object, error) {
+	term.list = false
+	var values []object
+	for _, item := range items {
+		if err := p.parseValues(item, ctx, term, func(o object) { values = append(values, o) }); err != nil {
+			return object{}, err
+		}
+	}
+	if len(values) == 0 {
+		return object{resource: rdfNil}, nil
+	}
+
+	head := p.freshBnode()
+	node := head
+	for i, val := range values {
+		p.emit(node, rdfFirst, val)
+		next := object{resource: rdfNil}
+		if i < len(values)-1 {
+			next = p.freshBnode()
+		}
+		p.emit(node, rdfRest, next)
+		node = next
+	}
+	return head, nil
+}
+
+// parseNode emits the triples of a node object, returning its subject
+func (p *jsonldParser) parseNode(m map[string]interface{}, ctx *jsonldContext) (object, error) {
+	if local, ok := m["@context"]; ok {
+		var err error
+		if ctx, err = ctx.process(local); err != nil {
+			return object{}, err
+		}
+	}
+
+	var sub object
+	if id, ok := m["@id"].(string); ok {
+		sub = iriObject(ctx.expandIRI(id, false, true))
+	} else {
+		sub = p.freshBnode()
+	}
+
+	var types []string
+	switch typ := m["@type"].(type) {
+	case string:
+		types = append(types, typ)
+	case []interface{}:
+		for _, t := range typ {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	for _, typ := range types {
+		p.emit(sub, rdfType, iriObject(ctx.expandIRI(typ, true, true)))
+	}
+
+	if graph, ok := m["@graph"]; ok {
+		if err := p.parseValues(graph, ctx, jsonldTerm{}, func(object) {}); err != nil {
+			return object{}, err
+		}
+	}
+
+	if reverse, ok := m["@reverse"].(map[string]interface{}); ok {
+		for _, key := range sortedKeys(reverse) {
+			pred := ctx.expandIRI(key, true, false)
+			err := p.parseValues(reverse[key], ctx, jsonldTerm{}, func(o object) {
+				if !o.isLit {
+					p.emit(o, pred, sub)
+				}
+			})
+			if err != nil {
+				return object{}, err
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(m) {
+		if strings.HasPrefix(key, "@") {
+			continue
+		}
+		term := ctx.terms[key]
+		pred := ctx.expandIRI(key, true, false)
+		if term.isNull || pred == "" || strings.HasPrefix(pred, "_:") {
+			continue
+		}
+		err := p.parseValues(m[key], ctx, term, func(o object) {
+			if !term.reverse {
+				p.emit(sub, pred, o)
+			} else if !o.isLit {
+				p.emit(o, pred, sub)
+			}
+		})
+		if err != nil {
+			return object{}, err
+		}
+	}
+	return sub, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func hasKey(m map[string]interface{}, k string) bool {
+	_, ok := m[k]
+	return ok
+}
@@ -0,0 +1,245 @@
+package triplestore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONLD(t *testing.T) {
+	doc := `{
+  "@context": {
+    "@base": "http://ex.org/people/",
+    "foaf": "http://xmlns.com/foaf/0.1/",
+    "xsd": "http://www.w3.org/2001/XMLSchema#",
+    "name": "foaf:name",
+    "age": {"@id": "foaf:age", "@type": "xsd:integer"},
+    "knows": {"@id": "foaf:knows", "@type": "@id"},
+    "nick": {"@id": "foaf:nick", "@container": "@list"},
+    "comment": {"@id": "http://www.w3.org/2000/01/rdf-schema#comment", "@language": "en"}
+  },
+  "@graph": [
+    {
+      "@id": "me",
+      "@type": "foaf:Person",
+      "name": "jsmith",
+      "age": "26",
+      "knows": ["you", "_:friend"],
+      "nick": ["js", "john"],
+      "comment": "a comment",
+      "foaf:based_near": {"name": "Paris"},
+      "foaf:weight": 72.5,
+      "foaf:male": true,
+      "foaf:title": {"@value": "Monsieur", "@language": "fr"},
+      "foaf:birthday": {"@value": "09-01", "@type": "xsd:gMonthDay"}
+    },
+    {
+      "@id": "_:friend",
+      "@context": {"@vocab": "http://ex.org/vocab#"},
+      "nickname": "buddy"
+    }
+  ]
+}`
+
+	tris, err := NewJSONLDDecoder(strings.NewReader(doc)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foaf := "http://xmlns.com/foaf/0.1/"
+	me := "http://ex.org/people/me"
+	expected := []Triple{
+		SubjPred(me, rdfType).Resource(foaf + "Person"),
+		SubjPred(me, foaf+"name").StringLiteral("jsmith"),
+		SubjPred(me, foaf+"age").IntegerLiteral(26),
+		SubjPred(me, foaf+"knows").Resource("http://ex.org/people/you"),
+		SubjPred(me, foaf+"knows").Bnode("friend"),
+		SubjPred(me, foaf+"nick").Bnode("l1"),
+		BnodePred("l1", rdfFirst).StringLiteral("js"),
+		BnodePred("l1", rdfRest).Bnode("l2"),
+		BnodePred("l2", rdfFirst).StringLiteral("john"),
+		BnodePred("l2", rdfRest).Resource(rdfNil),
+		SubjPred(me, "http://www.w3.org/2000/01/rdf-schema#comment").StringLiteralWithLang("a comment", "en"),
+		SubjPred(me, foaf+"based_near").Bnode("paris"),
+		BnodePred("paris", foaf+"name").StringLiteral("Paris"),
+		SubjPred(me, foaf+"weight").Float64Literal(72.5),
+		SubjPred(me, foaf+"male").BooleanLiteral(true),
+		SubjPred(me, foaf+"title").StringLiteralWithLang("Monsieur", "fr"),
+		&triple{sub: me, pred: foaf + "birthday", obj: object{isLit: true, lit: literal{typ: XsdType("xsd:gMonthDay"), val: "09-01"}}},
+		BnodePred("friend", "http://ex.org/vocab#nickname").StringLiteral("buddy"),
+	}
+	if !isomorphicTriples(tris, expected) {
+		t.Fatalf("got %v, want %v", tris, expected)
+	}
+
+	for _, invalid := range []string{
+		`{"@context": "http://remote.org/context.jsonld", "name": "jsmith"}`,
+		`{"http://ex.org/name": {"@value": "jsmith", "@type": "xsd:string", "@language": "en"}}`,
+		`{"http://ex.org/name": `,
+	} {
+		if _, err := NewJSONLDDecoder(strings.NewReader(invalid)).Decode(); err == nil {
+			t.Fatalf("document %s: expected error, got none", invalid)
+		}
+	}
+}
+
+func TestEncodeJSONLD(t *testing.T) {
+	tris := []Triple{
+		SubjPred("http://ex.org/me", rdfType).Resource("http://ex.org/Person"),
+		SubjPred("http://ex.org/me", "http://ex.org/name").StringLiteral("jsmith"),
+		SubjPred("http://ex.org/me", "http://ex.org/age").IntegerLiteral(26),
+		SubjPred("http://ex.org/me", "http://ex.org/knows").Bnode("you"),
+		BnodePred("you", "http://ex.org/name").StringLiteralWithLang("Jean", "fr"),
+	}
+
+	t.Run("expanded", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := NewJSONLDEncoder(&buf).Encode(tris...); err != nil {
+			t.Fatal(err)
+		}
+		expected := `[
+  {
+    "@id": "_:you",
+    "http://ex.org/name": [
+      {
+        "@language": "fr",
+        "@value": "Jean"
+      }
+    ]
+  },
+  {
+    "@id": "http://ex.org/me",
+    "@type": [
+      "http://ex.org/Person"
+    ],
+    "http://ex.org/age": [
+      {
+        "@type": "http://www.w3.org/2001/XMLSchema#integer",
+        "@value": "26"
+      }
+    ],
+    "http://ex.org/knows": [
+      {
+        "@id": "_:you"
+      }
+    ],
+    "http://ex.org/name": [
+      {
+        "@value": "jsmith"
+      }
+    ]
+  }
+]
+`
+		if got, want := buf.String(), expected; got != want {
+			t.Fatalf("got\n%s\nwant\n%s", got, want)
+		}
+
+		decoded, err := NewJSONLDDecoder(&buf).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isomorphicTriples(decoded, tris) {
+			t.Fatalf("got %v, want %v", decoded, tris)
+		}
+	})
+
+	t.Run("compacted", func(t *testing.T) {
+		c := &Context{
+			Base:     "http://ex.org/",
+			Prefixes: map[string]string{"xsd": "http://www.w3.org/2001/XMLSchema#", "ex": "http://ex.org/"},
+		}
+		var buf bytes.Buffer
+		if err := NewJSONLDEncoderWithContext(&buf, c).Encode(append(tris, SubjPred("ex:you", "rel").Resource("ex:me"))...); err != nil {
+			t.Fatal(err)
+		}
+		expected := `{
+  "@context": {
+    "@base": "http://ex.org/",
+    "ex": "http://ex.org/",
+    "xsd": "http://www.w3.org/2001/XMLSchema#"
+  },
+  "@graph": [
+    {
+      "@id": "_:you",
+      "ex:name": {
+        "@language": "fr",
+        "@value": "Jean"
+      }
+    },
+    {
+      "@id": "ex:me",
+      "@type": "ex:Person",
+      "ex:age": {
+        "@type": "xsd:integer",
+        "@value": "26"
+      },
+      "ex:knows": {
+        "@id": "_:you"
+      },
+      "ex:name": "jsmith"
+    },
+    {
+      "@id": "ex:you",
+      "rel": {
+        "@id": "ex:me"
+      }
+    }
+  ]
+}
+`
+		if got, want := buf.String(), expected; got != want {
+			t.Fatalf("got\n%s\nwant\n%s", got, want)
+		}
+
+		decoded, err := NewJSONLDDecoder(&buf).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isomorphicTriples(decoded, append(tris, SubjPred("http://ex.org/you", "rel").Resource("http://ex.org/me"))) {
+			t.Fatalf("got %v", decoded)
+		}
+	})
+}
+
+func TestJSONLDBnodesAndRelativeIRIs(t *testing.T) {
+	doc := `[{"@id": "_:genid1", "http://ex.org/p": "a"}, {"http://ex.org/p": "b"}]`
+	tris, err := NewJSONLDDecoder(strings.NewReader(doc)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Triple{
+		BnodePred("x", "http://ex.org/p").StringLiteral("a"),
+		BnodePred("y", "http://ex.org/p").StringLiteral("b"),
+	}
+	if !isomorphicTriples(tris, expected) {
+		t.Fatalf("got %v, want %v", tris, expected)
+	}
+
+	c := &Context{Base: "http://example.org/data", Prefixes: map[string]string{}}
+	tris = nil
+	for _, iri := range []string{
+		"http://example.org/data/b",
+		"http://example.org/datax",
+		"http://example.org/data:c",
+		"http://example.org/b",
+	} {
+		tris = append(tris, SubjPred(iri, "http://example.org/p").Resource(iri))
+	}
+	var buf bytes.Buffer
+	if err := NewJSONLDEncoderWithContext(&buf, c).Encode(tris...); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"data/b"`, `"datax"`, `"http://example.org/data:c"`, `"b"`} {
+		if got := buf.String(); !strings.Contains(got, want) {
+			t.Fatalf("expected %s in %s", want, got)
+		}
+	}
+	decoded, err := NewJSONLDDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Triples(decoded), Triples(tris); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,379 @@
+package triplestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	logFileName      = "triples.log"
+	snapshotFileName = "triples.snapshot"
+)
+
+// Operation markers of the entries of a log record
+const (
+	addOperation    = uint8(1)
+	removeOperation = uint8(2)
+)
+
+var errClosedSource = errors.New("triplestore: log source closed")
+
+// A PersistentSource is a source whose changes are persisted on disk.
+// As Add and Remove cannot fail, the first error met while persisting
+// is kept and returned by Err. Changes are then only kept in memory.
+type PersistentSource interface {
+	Source
+	Compact() error
+	Sync() error
+	Close() error
+	Err() error
+}
+
+type logSource struct {
+	*source
+	dir string
+
+	mu  sync.Mutex // serializes the writes to the log
+	log *os.File
+	err error
+}
+
+// OpenLogSource opens the source persisted in the given directory,
+// creating it if needed. Every Add and Remove is appended to a log
+// as a record of length prefixed triples with an operation marker,
+// protected by a checksum. On open, the log is replayed on top of the
+// last snapshot, and a torn tail left by a crash is truncated. A corrupted
+// record followed by other records is an error, as truncating the log
+// there would silently drop the later changes.
+func OpenLogSource(dir string) (PersistentSource, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &logSource{source: NewSource().(*source), dir: dir}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := log.Stat()
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	valid, err := s.replay(log, info.Size())
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
+	if err := log.Truncate(valid); err != nil {
+		log.Close()
+		return nil, fmt.Errorf("triplestore: truncating log: %s", err)
+	}
+	if _, err := log.Seek(valid, io.SeekStart); err != nil {
+		log.Close()
+		return nil, err
+	}
+	s.log = log
+	return s, nil
+}
+
+func (s *logSource) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(s.dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for offset := int64(0); offset < info.Size(); {
+		payload, err := readLogRecord(r, info.Size()-offset)
+		if err != nil {
+			return fmt.Errorf("triplestore: decoding snapshot at offset %d: %s", offset, err)
+		}
+		ops, err := decodeLogRecord(payload)
+		if err != nil {
+			return fmt.Errorf("triplestore: decoding snapshot at offset %d: %s", offset, err)
+		}
+		s.source.apply(ops)
+		offset += int64(logHeaderSize + len(payload))
+	}
+	return nil
+}
+
+// replay applies the valid records of the log, returning their length
+func (s *logSource) replay(log io.Reader, size int64) (int64, error) {
+	r := bufio.NewReader(log)
+	var valid int64
+	for valid < size {
+		payload, err := readLogRecord(r, size-valid)
+		if err == io.ErrUnexpectedEOF {
+			return valid, nil
+		}
+		var ops []operation
+		if err == nil {
+			ops, err = decodeLogRecord(payload)
+		}
+		if err != nil {
+			end := valid + int64(logHeaderSize+len(payload))
+			if end == size || zeroTail(r) {
+				return valid, nil
+			}
+			return 0, fmt.Errorf("triplestore: corrupted log record at offset %d: %s", valid, err)
+		}
+		s.source.apply(ops)
+		valid += int64(logHeaderSize + len(payload))
+	}
+	return valid, nil
+}
+
+// zeroTail tells whether the rest of the log is only made of zeros,
+// as left by file systems extending files before a crash
+func zeroTail(r io.Reader) bool {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false
+			}
+		}
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+func (s *logSource) Add(ts ...Triple) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.append(operations(addOperation, ts))
+	s.source.Add(ts...)
+}
+
+func (s *logSource) Remove(ts ...Triple) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.append(operations(removeOperation, ts))
+	s.source.Remove(ts...)
+}
+
+// Begin starts a transaction, written to the log as a single record on commit
+func (s *logSource) Begin() Tx {
+	return &tx{base: s.source.Snapshot().(*graph), commit: s.commit}
+}
+
+func (s *logSource) Update(fn func(Tx) error) error {
+	return update(s.Begin(), fn)
+}
+
+// commit only applies the operations once written to the log
+func (s *logSource) commit(base *graph, ops []operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.source.mu.RLock()
+	conflict := s.source.conflicts(base, ops)
+	s.source.mu.RUnlock()
+	if conflict {
+		return ErrTxConflict
+	}
+	if err := s.append(ops); err != nil {
+		return err
+	}
+	s.source.apply(ops)
+	return nil
+}
+
+func (s *logSource) append(ops []operation) error {
+	if s.err != nil || len(ops) == 0 {
+		return s.err
+	}
+	if s.log == nil {
+		s.err = errClosedSource
+		return s.err
+	}
+	if _, err := s.log.Write(encodeLogRecord(ops)); err != nil {
+		s.err = fmt.Errorf("triplestore: writing log: %s", err)
+	}
+	return s.err
+}
+
+// Compact writes the current triples to a new snapshot and empties the log.
+// Terms that are no longer used are reclaimed from memory as well.
+func (s *logSource) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return errClosedSource
+	}
+	s.source.compact()
+
+	tmpPath := filepath.Join(s.dir, snapshotFileName+".tmp")
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := writeSnapshot(w, s.source.CopyTriples()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	// replaying the log over the new snapshot is harmless if we crash here
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+// Sync commits the log to stable storage
+func (s *logSource) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return errClosedSource
+	}
+	return s.log.Sync()
+}
+
+func (s *logSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Sync()
+	if cerr := s.log.Close(); err == nil {
+		err = cerr
+	}
+	s.log = nil
+	return err
+}
+
+func (s *logSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// snapshotRecordSize is the number of triples per record of a snapshot
+const snapshotRecordSize = 1024
+
+// writeSnapshot writes the triples as records of add operations
+func writeSnapshot(w io.Writer, tris []Triple) error {
+	for len(tris) > 0 {
+		n := snapshotRecordSize
+		if n > len(tris) {
+			n = len(tris)
+		}
+		if _, err := w.Write(encodeLogRecord(operations(addOperation, tris[:n]))); err != nil {
+			return err
+		}
+		tris = tris[n:]
+	}
+	return nil
+}
+
+// A log record is a header with the length and the checksum of its payload,
+// followed by the payload: the operations, each as an operation marker and
+// a triple in binary, with string literals unescaped as their length is given.
+const logHeaderSize = 8
+
+var errLogChecksum = errors.New("checksum mismatch")
+
+func encodeLogRecord(ops []operation) []byte {
+	record := bytes.NewBuffer(make([]byte, logHeaderSize))
+	for _, o := range ops {
+		record.WriteByte(o.op)
+		encodeBinTriple(o.triple, record, verbatim)
+	}
+	b := record.Bytes()
+	binary.BigEndian.PutUint32(b[:4], uint32(len(b)-logHeaderSize))
+	binary.BigEndian.PutUint32(b[4:logHeaderSize], crc32.ChecksumIEEE(b[logHeaderSize:]))
+	return b
+}
+
+// readLogRecord reads the payload of the next record, of at most max bytes with
+// its header. It returns io.ErrUnexpectedEOF for a record torn by the end of
+// the log, and the payload with errLogChecksum when its checksum mismatches.
+func readLogRecord(r io.Reader, max int64) ([]byte, error) {
+	var header [logHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	length, checksum := binary.BigEndian.Uint32(header[:4]), binary.BigEndian.Uint32(header[4:])
+	if int64(logHeaderSize)+int64(length) > max {
+		return nil, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return payload, errLogChecksum
+	}
+	return payload, nil
+}
+
+func decodeLogRecord(payload []byte) ([]operation, error) {
+	if len(payload) == 0 {
+		return nil, errors.New("empty log record")
+	}
+	var ops []operation
+	r := bytes.NewReader(payload)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		if op != addOperation && op != removeOperation {
+			return nil, fmt.Errorf("unknown log operation %d", op)
+		}
+		tri, done, err := decodeTriple(r, verbatim)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return nil, errors.New("truncated log record")
+		}
+		ops = append(ops, operation{op: op, triple: tri})
+	}
+	return ops, nil
+}
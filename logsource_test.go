@@ -0,0 +1,255 @@
+package triplestore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestLogSourceReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triplestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	all := []tstore.Triple{
+		tstore.SubjPred("one", "two").StringLiteral("three"),
+		tstore.SubjPred("four", "two").IntegerLiteral(42),
+		tstore.SubjPred("one", "two").Resource("four"),
+		tstore.BnodePred("five", "six").StringLiteralWithLang("seven", "en"),
+	}
+
+	s, err := tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(all...)
+	s.Remove(all[1])
+	s.Add(all[1])
+	s.Remove(all[0])
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := tstore.Triples{all[1], all[2], all[3]}
+	s, err = tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tstore.Triples(s.CopyTriples()), expected; !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "triples.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.Size(), int64(0); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	s.Add(all[0])
+	s.Close()
+
+	s.Remove(all[1])
+	if got, want := s.Err(), error(nil); got == want {
+		t.Fatal("expected error when writing to closed source")
+	}
+
+	s, err = tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got, want := tstore.Triples(s.Snapshot().Triples()), tstore.Triples(all); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestLogSourceTruncatesTornTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triplestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := tstore.SubjPred("one", "two").Resource("three")
+	second := tstore.SubjPred("four", "five").StringLiteral("six")
+	third := tstore.SubjPred("seven", "eight").BooleanLiteral(true)
+
+	s, err := tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(first)
+	s.Close()
+	logPath := filepath.Join(dir, "triples.log")
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := info.Size()
+
+	s, err = tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(second)
+	s.Close()
+
+	tcases := []struct {
+		name    string
+		corrupt func(content []byte) []byte
+	}{
+		{"torn record", func(content []byte) []byte { return content[:len(content)-3] }},
+		{"torn header", func(content []byte) []byte { return content[:valid+5] }},
+		{"bad checksum", func(content []byte) []byte {
+			content[len(content)-1] ^= 0xff
+			return content
+		}},
+		{"huge length", func(content []byte) []byte {
+			return append(content[:valid], 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0)
+		}},
+	}
+
+	for _, tc := range tcases {
+		content, err := ioutil.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		original := append([]byte{}, content...)
+		if err := ioutil.WriteFile(logPath, tc.corrupt(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := tstore.OpenLogSource(dir)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if got, want := tstore.Triples(s.CopyTriples()), (tstore.Triples{first}); !got.Equal(want) {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, want)
+		}
+		s.Add(third)
+		s.Close()
+
+		s, err = tstore.OpenLogSource(dir)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if got, want := tstore.Triples(s.CopyTriples()), (tstore.Triples{first, third}); !got.Equal(want) {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, want)
+		}
+		s.Close()
+
+		if err := ioutil.WriteFile(logPath, original, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLogSourceRoundTripsLiterals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triplestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	all := []tstore.Triple{
+		tstore.SubjPred("one", "path").StringLiteral(`C:\new\table`),
+		tstore.SubjPred("one", "quote").StringLiteral(`say "hi" \"`),
+		tstore.SubjPred("one", "lines").StringLiteral("first\nsecond\r\n\\n"),
+		tstore.SubjPred("one", "empty").StringLiteral(""),
+		tstore.SubjPred("one", "label").StringLiteralWithLang(`l'été \ "chaud"`, "fr"),
+		tstore.BnodePred(`b\1`, `p\"`).Bnode("b 2"),
+		tstore.SubjPred("été\\", "p").Resource(`http://ex.org/a\b`),
+	}
+
+	s, err := tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(all...)
+	s.Close()
+
+	check := func(step string) {
+		s, err := tstore.OpenLogSource(dir)
+		if err != nil {
+			t.Fatalf("%s: %s", step, err)
+		}
+		defer s.Close()
+		g := s.Snapshot()
+		if got, want := tstore.Triples(g.Triples()), tstore.Triples(all); !got.Equal(want) {
+			t.Fatalf("%s: got %v, want %v", step, got, want)
+		}
+		for _, tri := range all {
+			if !g.Contains(tri) {
+				t.Fatalf("%s: expected %v", step, tri)
+			}
+		}
+		lit, _ := g.WithSubjPred("one", "label")[0].Object().Literal()
+		if got, want := lit.Type(), tstore.XsdString; got != want {
+			t.Fatalf("%s: got %s, want %s", step, got, want)
+		}
+		if step == "log" {
+			if err := s.Compact(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	check("log")
+	check("snapshot")
+}
+
+func TestLogSourceRejectsCorruptedRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triplestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(tstore.SubjPred("one", "two").Resource("three"))
+	s.Add(tstore.SubjPred("four", "five").StringLiteral("six"))
+	s.Add(tstore.SubjPred("seven", "eight").StringLiteral("nine"))
+	s.Close()
+
+	logPath := filepath.Join(dir, "triples.log")
+	content, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	middle := append([]byte{}, content...)
+	middle[len(middle)/2] ^= 0xff
+	if err := ioutil.WriteFile(logPath, middle, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tstore.OpenLogSource(dir); err == nil {
+		t.Fatal("expected error on corrupted record in the middle of the log")
+	}
+
+	zeros := append(append([]byte{}, content...), make([]byte, 64)...)
+	if err := ioutil.WriteFile(logPath, zeros, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err = tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatalf("expected zeros tail to be truncated: %s", err)
+	}
+	defer s.Close()
+	if got, want := s.Snapshot().Count(), 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
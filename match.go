@@ -0,0 +1,95 @@
+package triplestore
+
+import "sort"
+
+// Match returns the triples of the graph matching the given subject, predicate
+// and object, a nil component matching anything. It routes to the most specific
+// index of the graph. As with WithSubject, a subject matches both resources and
+// blank nodes.
+func Match(g RDFGraph, s, p *string, o Object) []Triple {
+	switch {
+	case s != nil && p != nil && o != nil:
+		var out []Triple
+		for _, isBnode := range []bool{false, true} {
+			tri := &triple{sub: *s, isSubBnode: isBnode, pred: *p, obj: o.(object)}
+			if g.Contains(tri) {
+				out = append(out, tri)
+			}
+		}
+		return out
+	case s != nil && p != nil:
+		return g.WithSubjPred(*s, *p)
+	case s != nil && o != nil:
+		return g.WithSubjObj(*s, o)
+	case p != nil && o != nil:
+		return g.WithPredObj(*p, o)
+	case s != nil:
+		return g.WithSubject(*s)
+	case p != nil:
+		return g.WithPredicate(*p)
+	case o != nil:
+		return g.WithObject(o)
+	default:
+		return g.Triples()
+	}
+}
+
+// Subjects returns the distinct subjects of the graph, sorted
+func Subjects(g RDFGraph) []string {
+	set := make(map[string]bool)
+	if gph, ok := g.(*graph); ok {
+		gph.s.each(func(k idKey, _ pset) {
+			set[gph.dict.name(k.a)] = true
+		})
+	} else {
+		for _, t := range g.Triples() {
+			set[t.Subject()] = true
+		}
+	}
+	return sortedStrings(set)
+}
+
+// Predicates returns the distinct predicates of the graph, sorted
+func Predicates(g RDFGraph) []string {
+	set := make(map[string]bool)
+	if gph, ok := g.(*graph); ok {
+		gph.p.each(func(k idKey, _ pset) {
+			set[gph.dict.name(k.b)] = true
+		})
+	} else {
+		for _, t := range g.Triples() {
+			set[t.Predicate()] = true
+		}
+	}
+	return sortedStrings(set)
+}
+
+// ObjectsOf returns the distinct objects of the triples of the graph
+// with the given predicate, sorted by their NTriples representation
+func ObjectsOf(g RDFGraph, pred string) []Object {
+	set := make(map[string]object)
+	for _, t := range g.WithPredicate(pred) {
+		obj := t.(*triple).obj
+		set[obj.key()] = obj
+	}
+
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]Object, len(keys))
+	for i, k := range keys {
+		out[i] = set[k]
+	}
+	return out
+}
+
+func sortedStrings(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,62 @@
+package triplestore_test
+
+import (
+	"reflect"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestMatchAndDistinct(t *testing.T) {
+	one := tstore.SubjPred("me", "age").IntegerLiteral(26)
+	two := tstore.SubjPred("me", "name").StringLiteral("jsmith")
+	three := tstore.SubjPred("you", "name").StringLiteral("jdoe")
+	four := tstore.SubjPred("me", "knows").Resource("you")
+	five := tstore.BnodePred("me", "name").StringLiteral("jsmith")
+
+	src := tstore.NewSource()
+	src.Add(one, two, three, four, five)
+	snap := src.Snapshot()
+
+	str := func(s string) *string { return &s }
+
+	tcases := []struct {
+		s, p     *string
+		o        tstore.Object
+		expected tstore.Triples
+	}{
+		{nil, nil, nil, tstore.Triples{one, two, three, four, five}},
+		{str("me"), nil, nil, tstore.Triples{one, two, four, five}},
+		{nil, str("name"), nil, tstore.Triples{two, three, five}},
+		{nil, nil, tstore.Resource("you"), tstore.Triples{four}},
+		{str("me"), str("name"), nil, tstore.Triples{two, five}},
+		{str("you"), nil, tstore.StringLiteral("jdoe"), tstore.Triples{three}},
+		{nil, str("name"), tstore.StringLiteral("jsmith"), tstore.Triples{two, five}},
+		{str("me"), str("name"), tstore.StringLiteral("jsmith"), tstore.Triples{two, five}},
+		{str("me"), str("age"), tstore.IntegerLiteral(26), tstore.Triples{one}},
+		{str("me"), str("age"), tstore.IntegerLiteral(27), nil},
+		{str("unknown"), nil, nil, nil},
+	}
+
+	for _, g := range []tstore.RDFGraph{snap, tstore.Filter(snap, func(tstore.Triple) bool { return true })} {
+		for i, tc := range tcases {
+			if got, want := tstore.Triples(tstore.Match(g, tc.s, tc.p, tc.o)), tc.expected; !got.Equal(want) {
+				t.Fatalf("case %d: got %v, want %v", i, got, want)
+			}
+		}
+
+		if got, want := tstore.Subjects(g), []string{"me", "you"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if got, want := tstore.Predicates(g), []string{"age", "knows", "name"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		objs := tstore.ObjectsOf(g, "name")
+		if got, want := len(objs), 2; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		if !objs[0].Equal(tstore.StringLiteral("jdoe")) || !objs[1].Equal(tstore.StringLiteral("jsmith")) {
+			t.Fatalf("unexpected objects %v", objs)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package triplestore
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseNQuads(t *testing.T) {
+	tcases := []struct {
+		input    string
+		expected Triple
+		graph    string
+	}{
+		{
+			input:    `<sub> <pred> <obj> <graph> .`,
+			expected: SubjPred("sub", "pred").Resource("obj"), graph: "graph",
+		},
+		{
+			input:    `<sub> <pred> <obj> .`,
+			expected: SubjPred("sub", "pred").Resource("obj"), graph: DefaultGraph,
+		},
+		{
+			input:    `_:s <pred> _:o <graph> .`,
+			expected: BnodePred("s", "pred").Bnode("o"), graph: "graph",
+		},
+		{
+			input:    `<sub> <pred> _:o _:g .`,
+			expected: SubjPred("sub", "pred").Bnode("o"), graph: "_:g",
+		},
+		{
+			input:    `<sub> <pred> "a "quoted" literal" <graph> . # comment`,
+			expected: SubjPred("sub", "pred").StringLiteral(`a "quoted" literal`), graph: "graph",
+		},
+		{
+			input:    `<sub> <pred> "bonjour"@fr <graph> .`,
+			expected: SubjPred("sub", "pred").StringLiteralWithLang("bonjour", "fr"), graph: "graph",
+		},
+		{
+			input:    `<sub> <pred> "2"^^<xsd:integer> <graph> .`,
+			expected: SubjPred("sub", "pred").IntegerLiteral(2), graph: "graph",
+		},
+		{
+			input:    `<sub> <pred> "2"^^<xsd:integer> .`,
+			expected: SubjPred("sub", "pred").IntegerLiteral(2), graph: DefaultGraph,
+		},
+	}
+
+	for _, tcase := range tcases {
+		quads, err := newLenientNTParser(strings.NewReader(tcase.input)).ParseQuads()
+		if err != nil {
+			t.Fatalf("input %s: %s", tcase.input, err)
+		}
+		if got, want := len(quads), 1; got != want {
+			t.Fatalf("input %s: got %d, want %d", tcase.input, got, want)
+		}
+		if got, want := quads[0].Triple(), tcase.expected; !got.Equal(want) {
+			t.Fatalf("input %s: got %v, want %v", tcase.input, got, want)
+		}
+		if got, want := quads[0].Graph(), tcase.graph; got != want {
+			t.Fatalf("input %s: got %s, want %s", tcase.input, got, want)
+		}
+	}
+
+	if _, err := newLenientNTParser(strings.NewReader(`<sub> <pred> <obj> "graph" .`)).ParseQuads(); err == nil {
+		t.Fatal("expected error on literal graph term")
+	}
+}
+
+func TestEncodeDecodeNQuads(t *testing.T) {
+	quads := []Quad{
+		NewQuad(SubjPred("one", "two").Resource("three"), "g1"),
+		NewQuad(SubjPred("one", "two").StringLiteralWithLang("three", "en"), "g1"),
+		NewQuad(BnodePred("one", "two").IntegerLiteral(3), "g2"),
+		NewQuad(SubjPred("one", "two").StringLiteral("three"), "_:g3"),
+		NewQuad(SubjPred("one", "two").Bnode("three"), DefaultGraph),
+	}
+
+	var buf bytes.Buffer
+	if err := NewNQuadsEncoder(&buf).EncodeQuads(quads...); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := strings.SplitN(buf.String(), "\n", 2)[0], "<one> <two> <three> <g1> ."; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	ds, err := NewNQuadsDecoder(bytes.NewReader(buf.Bytes())).DecodeDataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range quads {
+		if !ds.Snapshot(q.Graph()).Contains(q.Triple()) {
+			t.Fatalf("graph '%s' should contain %v", q.Graph(), q.Triple())
+		}
+	}
+	if got, want := ds.UnionSnapshot().Count(), len(quads); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	tris, err := NewNQuadsDecoder(bytes.NewReader(buf.Bytes())).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(tris), len(quads); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestStreamNQuads(t *testing.T) {
+	quads := []Quad{
+		NewQuad(SubjPred("one", "two").Resource("three"), "g1"),
+		NewQuad(SubjPred("four", "five").IntegerLiteral(6), "g2"),
+		NewQuad(SubjPred("seven", "eight").Resource("nine"), DefaultGraph),
+	}
+
+	var buf bytes.Buffer
+	quadC := make(chan Quad)
+	go func() {
+		defer close(quadC)
+		for _, q := range quads {
+			quadC <- q
+		}
+	}()
+	if err := NewNQuadsStreamEncoder(&buf).StreamEncodeQuads(context.Background(), quadC); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []DecodeResult
+	for r := range NewNQuadsStreamDecoder(&buf).StreamDecode(context.Background()) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		decoded = append(decoded, r)
+	}
+
+	if got, want := len(decoded), len(quads); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	for i, r := range decoded {
+		if got, want := r.Tri, quads[i].Triple(); !got.Equal(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if got, want := r.Graph, quads[i].Graph(); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+
+	t.Run("triples in default graph", func(t *testing.T) {
+		var buf bytes.Buffer
+		triC := make(chan Triple)
+		go tripleChan([]Triple{SubjPred("one", "two").Resource("three")}, triC)
+		if err := NewNQuadsStreamEncoder(&buf).StreamEncode(context.Background(), triC); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), "<one> <two> <three> .\n"; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	})
+}
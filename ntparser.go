@@ -18,6 +18,21 @@ func newLenientNTParser(r io.Reader) *lenientNTParser {
 }
 
 func (p *lenientNTParser) Parse() (out []Triple, err error) {
+	err = p.parse(false, func(t Triple, _ string) {
+		out = append(out, t)
+	})
+	return
+}
+
+// ParseQuads parses N-Quads statements, i.e. triples optionally followed by a graph term
+func (p *lenientNTParser) ParseQuads() (out []Quad, err error) {
+	err = p.parse(true, func(t Triple, graph string) {
+		out = append(out, NewQuad(t, graph))
+	})
+	return
+}
+
+func (p *lenientNTParser) parse(quads bool, each func(Triple, string)) error {
 	var count int
 	scanner := bufio.NewScanner(p.r)
 	for scanner.Scan() {
@@ -29,54 +44,65 @@ func (p *lenientNTParser) Parse() (out []Triple, err error) {
 		if line[0] == '#' {
 			continue
 		}
-		t, terr := parseTriple(line)
+		t, graph, terr := parseStatement(line, quads)
 		if terr != nil {
-			return out, fmt.Errorf("lenient parsing: line %d: %s", count, terr)
+			return fmt.Errorf("lenient parsing: line %d: %s", count, terr)
 		}
-		out = append(out, t)
+		each(t, graph)
 	}
 
-	err = scanner.Err()
-	return
+	return scanner.Err()
 }
 
 func parseTriple(b []byte) (Triple, error) {
+	t, _, err := parseStatement(b, false)
+	return t, err
+}
+
+// parseStatement parses a triple, and also a graph term when parsing quads.
+// Graph terms are returned as IRIs, or prefixed with '_:' for blank nodes.
+func parseStatement(b []byte, quad bool) (Triple, string, error) {
 	tBuilder := new(tripleBuilder)
 	var err error
 	if bytes.HasPrefix(b, []byte("_:")) {
 		if tBuilder.sub, b, err = parseBNodeSubject(b[2:]); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		tBuilder.isSubBnode = true
 	} else if bytes.HasPrefix(b, []byte("<")) {
 		if tBuilder.sub, b, err = parseIRISubject(b[1:]); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	} else {
-		return nil, fmt.Errorf("invalid subject in %s", b)
+		return nil, "", fmt.Errorf("invalid subject in %s", b)
 	}
 
 	if bytes.HasPrefix(b, []byte{'<'}) {
 		if tBuilder.pred, b, err = parsePredicate(b[1:]); err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	} else {
-		return nil, fmt.Errorf("invalid predicate in %s", b)
+		return nil, "", fmt.Errorf("invalid predicate in %s", b)
 	}
 
+	var tri Triple
 	if bytes.HasPrefix(b, []byte{'<'}) {
-		obj, _, err := parseIRIObject(b[1:])
-		return tBuilder.Resource(obj), err
+		var obj string
+		obj, b, err = parseIRIObject(b[1:], quad)
+		tri = tBuilder.Resource(obj)
 	} else if bytes.HasPrefix(b, []byte("_:")) {
-		obj, _, err := parseBNodeObject(b[2:])
-		return tBuilder.Bnode(obj), err
+		var obj string
+		obj, b, err = parseBNodeObject(b[2:], quad)
+		tri = tBuilder.Bnode(obj)
 	} else if bytes.HasPrefix(b, []byte{'"'}) {
-		lit, b, err := parseLiteralObject(b[1:])
+		var lit string
+		lit, b, err = parseLiteralObject(b[1:], quad)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		if bytes.HasPrefix(b, []byte("^^<")) {
-			dtype, _, err := parseIRIObject(b[3:])
+			var dtype string
+			dtype, b, err = parseIRIObject(b[3:], quad)
 			obj := object{
 				isLit: true,
 				lit: literal{
@@ -84,19 +110,43 @@ func parseTriple(b []byte) (Triple, error) {
 					val: lit,
 				},
 			}
-			return tBuilder.Object(obj), err
+			tri = tBuilder.Object(obj)
 		} else if bytes.HasPrefix(b, []byte{'@'}) {
-			lang, _, err := parseLangtag(b[1:])
-			return tBuilder.StringLiteralWithLang(unescapeStringLiteral(lit), lang), err
+			var lang string
+			lang, b, err = parseLangtag(b[1:], quad)
+			tri = tBuilder.StringLiteralWithLang(unescapeStringLiteral(lit), lang)
 		} else {
-			return tBuilder.StringLiteral(unescapeStringLiteral(lit)), err
+			tri = tBuilder.StringLiteral(unescapeStringLiteral(lit))
 		}
 	} else {
-		return nil, errors.New("invalid object")
+		return nil, "", errors.New("invalid object")
+	}
+	if err != nil || !quad {
+		return tri, "", err
 	}
+
+	graph, err := parseGraphTerm(b)
+	return tri, graph, err
 }
 
-func parseLangtag(b []byte) (string, []byte, error) {
+func parseGraphTerm(b []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte{'.'}):
+		return DefaultGraph, nil
+	case bytes.HasPrefix(b, []byte{'<'}):
+		graph, _, err := parseIRIObject(b[1:], false)
+		return graph, err
+	case bytes.HasPrefix(b, []byte("_:")):
+		graph, _, err := parseBNodeObject(b[2:], false)
+		return "_:" + graph, err
+	default:
+		return "", fmt.Errorf("invalid graph in %s", b)
+	}
+}
+
+// The object parsers are told whether a graph term may follow the object, as in
+// quads: objects then end either with the final dot or with a graph term
+func parseLangtag(b []byte, graphFollows bool) (string, []byte, error) {
 	var index int
 	for {
 		r, size, err, eol := decode(b[index:])
@@ -115,14 +165,14 @@ func parseLangtag(b []byte) (string, []byte, error) {
 		}
 
 		if r == ' ' {
-			if found, advance := peekNext(b[index:]); found == '.' {
+			if found, advance := peekNext(b[index:]); found == '.' || (graphFollows && isGraphStart(found)) {
 				return string(b[:index-1]), b[index+advance:], nil
 			}
 		}
 	}
 }
 
-func parseLiteralObject(b []byte) (string, []byte, error) {
+func parseLiteralObject(b []byte, graphFollows bool) (string, []byte, error) {
 	var index int
 	for {
 		r, size, err, eol := decode(b[index:])
@@ -135,7 +185,7 @@ func parseLiteralObject(b []byte) (string, []byte, error) {
 		index += size
 
 		if r == '"' {
-			if found, advance, other := doublePeekNext(b[index:]); (found == '.' && other == '#') || (found == '.' && other == 0) || (found == '^' && other == '^') || found == '@' {
+			if found, advance, other := doublePeekNext(b[index:]); (found == '.' && other == '#') || (found == '.' && other == 0) || (found == '^' && other == '^') || found == '@' || (graphFollows && (found == '<' || (found == '_' && other == ':'))) {
 				return string(b[:index-1]), b[index+advance:], nil
 			}
 		}
@@ -162,7 +212,7 @@ func parsePredicate(b []byte) (string, []byte, error) {
 	}
 }
 
-func parseIRIObject(b []byte) (string, []byte, error) {
+func parseIRIObject(b []byte, graphFollows bool) (string, []byte, error) {
 	var index int
 	for {
 		r, size, err, eol := decode(b[index:])
@@ -175,7 +225,7 @@ func parseIRIObject(b []byte) (string, []byte, error) {
 		index += size
 
 		if r == '>' {
-			if found, advance := peekNext(b[index:]); found == '.' {
+			if found, advance := peekNext(b[index:]); found == '.' || (graphFollows && isGraphStart(found)) {
 				return string(b[:index-1]), b[index+advance:], nil
 			}
 		}
@@ -202,7 +252,7 @@ func parseIRISubject(b []byte) (string, []byte, error) {
 	}
 }
 
-func parseBNodeObject(b []byte) (string, []byte, error) {
+func parseBNodeObject(b []byte, graphFollows bool) (string, []byte, error) {
 	var index int
 	for {
 		r, size, err, eol := decode(b[index:])
@@ -221,7 +271,7 @@ func parseBNodeObject(b []byte) (string, []byte, error) {
 		}
 
 		if r == ' ' || r == '\t' {
-			if found, advance := peekNext(b[index:]); found == '.' {
+			if found, advance := peekNext(b[index:]); found == '.' || (graphFollows && isGraphStart(found)) {
 				return string(b[:index-1]), b[index+advance:], nil
 			}
 		}
@@ -251,6 +301,10 @@ func parseBNodeSubject(b []byte) (string, []byte, error) {
 	}
 }
 
+func isGraphStart(r rune) bool {
+	return r == '<' || r == '_'
+}
+
 func decode(b []byte) (rune, int, error, bool) {
 	r, size := utf8.DecodeRune(b)
 	if r == utf8.RuneError && size == 1 {
@@ -152,7 +152,7 @@ func TestParsingComponents(t *testing.T) {
 			{input: `stuff" @   `, exp: "stuff", left: "@   "},
 		}
 		for _, tcase := range tcases {
-			s, left, _ := parseLiteralObject([]byte(tcase.input))
+			s, left, _ := parseLiteralObject([]byte(tcase.input), false)
 			if got, want := s, tcase.exp; got != want {
 				t.Fatalf("case [%s]: got '%s', want '%s'", tcase.input, got, want)
 			}
@@ -175,7 +175,7 @@ func TestParsingComponents(t *testing.T) {
 			{input: " .", exp: "", left: "."},
 		}
 		for _, tcase := range tcases {
-			s, left, _ := parseBNodeObject([]byte(tcase.input))
+			s, left, _ := parseBNodeObject([]byte(tcase.input), false)
 			if got, want := s, tcase.exp; got != want {
 				t.Fatalf("case [%s]: got '%s', want '%s'", tcase.input, got, want)
 			}
@@ -199,7 +199,7 @@ func TestParsingComponents(t *testing.T) {
 			{input: "> .", left: "."},
 		}
 		for _, tcase := range tcases {
-			s, left, _ := parseIRIObject([]byte(tcase.input))
+			s, left, _ := parseIRIObject([]byte(tcase.input), false)
 			if got, want := s, tcase.exp; got != want {
 				t.Fatalf("case [%s]: got '%s', want '%s'", tcase.input, got, want)
 			}
@@ -0,0 +1,144 @@
+package triplestore
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// A Page holds sorted triples. Next is the cursor to pass
+// to get the following page, empty on the last page.
+type Page struct {
+	Triples []Triple
+	Next    string
+}
+
+var errInvalidCursor = errors.New("invalid page cursor")
+
+// SortedMatch is like Match but returns triples sorted by their key made of
+// their subject, predicate and object, so that triples with the same subject,
+// then the same predicate, are contiguous. Blank node subjects come after resources.
+func SortedMatch(g RDFGraph, s, p *string, o Object) []Triple {
+	return append([]Triple(nil), sortedMatch(g, s, p, o)...)
+}
+
+// MatchPage returns at most limit triples among the sorted triples matching
+// the given subject, predicate and object, starting after the given cursor.
+// An empty cursor starts from the beginning, a zero limit returns all triples.
+// As graphs are immutable, paginating over the same snapshot is consistent.
+//
+// Indexes are not ordered: the first page of a pattern on a snapshot matches
+// and sorts all the triples, in O(n log n). A source keeps the sorted triples
+// of its latest paginated patterns and snapshots, up to sortedPatterns of them,
+// so that following pages of a snapshot are found by binary search.
+func MatchPage(g RDFGraph, s, p *string, o Object, after string, limit int) (Page, error) {
+	tris := sortedMatch(g, s, p, o)
+
+	var start int
+	if after != "" {
+		key, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil || len(key) == 0 {
+			return Page{}, errInvalidCursor
+		}
+		start = sort.Search(len(tris), func(i int) bool { return tris[i].(*triple).key() > string(key) })
+	}
+
+	var page Page
+	end := len(tris)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+		page.Next = base64.RawURLEncoding.EncodeToString([]byte(tris[end-1].(*triple).key()))
+	}
+	page.Triples = append([]Triple(nil), tris[start:end]...)
+	return page, nil
+}
+
+// sortedPatterns is the number of sorted matches a source keeps,
+// whatever the number of its snapshots
+const sortedPatterns = 32
+
+// sortedMatch returns the sorted matching triples. For snapshots,
+// the returned slice is cached and shared.
+func sortedMatch(g RDFGraph, s, p *string, o Object) []Triple {
+	if gph, ok := g.(*graph); ok {
+		return gph.sortedMatch(s, p, o)
+	}
+	tris := Match(g, s, p, o)
+	sortTriples(tris)
+	return tris
+}
+
+// sortedPages keeps the sorted triples matching the latest patterns
+// paginated over on the snapshots of a source, the oldest being evicted first
+type sortedPages struct {
+	mu        sync.Mutex
+	snapshots uint64
+	keys      []sortedKey
+	byKey     map[sortedKey][]Triple
+}
+
+type sortedKey struct {
+	snapshotID uint64
+	pattern    string
+}
+
+// nextID identifies a new snapshot of the source
+func (c *sortedPages) nextID() uint64 {
+	return atomic.AddUint64(&c.snapshots, 1)
+}
+
+func (c *sortedPages) get(key sortedKey) ([]Triple, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tris, ok := c.byKey[key]
+	return tris, ok
+}
+
+func (c *sortedPages) put(key sortedKey, tris []Triple) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.byKey[key]; ok {
+		return
+	}
+	if c.byKey == nil {
+		c.byKey = make(map[sortedKey][]Triple)
+	}
+	if len(c.keys) == sortedPatterns {
+		delete(c.byKey, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.keys = append(c.keys, key)
+	c.byKey[key] = tris
+}
+
+// sortedMatch returns the sorted triples matching the pattern,
+// kept by the source for the latest patterns and snapshots
+func (g *graph) sortedMatch(s, p *string, o Object) []Triple {
+	key := sortedKey{snapshotID: g.snapshotID, pattern: patternKey(s, p, o)}
+	if tris, ok := g.pages.get(key); ok {
+		return tris
+	}
+	tris := Match(g, s, p, o)
+	sortTriples(tris)
+	g.pages.put(key, tris)
+	return tris
+}
+
+// patternKey identifies the pattern, nil terms being written as '?'
+func patternKey(s, p *string, o Object) string {
+	key := ""
+	for _, term := range []*string{s, p} {
+		if term == nil {
+			key += "? "
+		} else {
+			key += strconv.Quote(*term) + " "
+		}
+	}
+	if o == nil {
+		return key + "?"
+	}
+	return key + o.(object).key()
+}
@@ -0,0 +1,77 @@
+package triplestore_test
+
+import (
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestMatchPage(t *testing.T) {
+	src := tstore.NewSource()
+	var expected tstore.Triples
+	for _, sub := range []string{"a", "b", "c"} {
+		for i := 0; i < 3; i++ {
+			tri := tstore.SubjPred(sub, "digit").IntegerLiteral(i)
+			expected = append(expected, tri)
+		}
+	}
+	expected = append(expected, tstore.BnodePred("a", "digit").IntegerLiteral(0))
+	for i := len(expected) - 1; i >= 0; i-- {
+		src.Add(expected[i])
+	}
+	snap := src.Snapshot()
+
+	for _, g := range []tstore.RDFGraph{snap, tstore.Union(snap)} {
+		sorted := tstore.SortedMatch(g, nil, nil, nil)
+		for i := range expected {
+			if got, want := sorted[i], expected[i]; !got.Equal(want) {
+				t.Fatalf("%d: got %v, want %v", i, got, want)
+			}
+		}
+
+		var all []tstore.Triple
+		var cursor string
+		for pages := 1; ; pages++ {
+			page, err := tstore.MatchPage(g, nil, nil, nil, cursor, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			all = append(all, page.Triples...)
+			if page.Next == "" {
+				if got, want := pages, 3; got != want {
+					t.Fatalf("got %d, want %d", got, want)
+				}
+				break
+			}
+			cursor = page.Next
+		}
+		for i := range expected {
+			if got, want := all[i], expected[i]; !got.Equal(want) {
+				t.Fatalf("%d: got %v, want %v", i, got, want)
+			}
+		}
+
+		b := "b"
+		page, err := tstore.MatchPage(g, &b, nil, nil, "", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := tstore.Triples(page.Triples), expected[3:5]; !got.Equal(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		page, err = tstore.MatchPage(g, &b, nil, nil, page.Next, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := tstore.Triples(page.Triples), expected[5:6]; !got.Equal(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if page.Next != "" {
+			t.Fatalf("expected last page, got cursor %q", page.Next)
+		}
+	}
+
+	if _, err := tstore.MatchPage(snap, nil, nil, nil, "!invalid", 2); err == nil {
+		t.Fatal("expected error on invalid cursor")
+	}
+}
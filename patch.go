@@ -0,0 +1,136 @@
+package triplestore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PatchEncoder encodes graph diffs
+type PatchEncoder interface {
+	EncodePatch(GraphDiff) error
+}
+
+// PatchDecoder decodes graph diffs
+type PatchDecoder interface {
+	DecodePatch() (GraphDiff, error)
+}
+
+type patchEncoder struct {
+	w io.Writer
+}
+
+// NewPatchEncoder encodes a diff in the RDF Patch format: a transaction
+// deleting the removed triples with 'D' rows then adding the added ones
+// with 'A' rows, whose terms are written as in canonical NTriples.
+func NewPatchEncoder(w io.Writer) PatchEncoder {
+	return &patchEncoder{w: w}
+}
+
+func (enc *patchEncoder) EncodePatch(d GraphDiff) error {
+	var buf bytes.Buffer
+	buf.WriteString("TX .\n")
+	for _, t := range d.Removed {
+		buf.WriteString("D " + canonicalNQuad(t.(*triple), DefaultGraph, verbatim))
+	}
+	for _, t := range d.Added {
+		buf.WriteString("A " + canonicalNQuad(t.(*triple), DefaultGraph, verbatim))
+	}
+	buf.WriteString("TC .\n")
+	_, err := enc.w.Write(buf.Bytes())
+	return err
+}
+
+type patchDecoder struct {
+	r io.Reader
+}
+
+// NewPatchDecoder decodes a diff in the RDF Patch format. Headers are
+// ignored and rows of aborted transactions are discarded, while a
+// transaction left open is an error. As rows apply in order, only the
+// last one for a given triple is kept in the diff. Prefixed names are
+// not supported.
+func NewPatchDecoder(r io.Reader) PatchDecoder {
+	return &patchDecoder{r: r}
+}
+
+func (dec *patchDecoder) DecodePatch() (GraphDiff, error) {
+	var committed, pending []operation
+	var inTx bool
+
+	scanner := bufio.NewScanner(dec.r)
+	for line := 1; scanner.Scan(); line++ {
+		row := strings.TrimSpace(scanner.Text())
+		if row == "" || strings.HasPrefix(row, "#") {
+			continue
+		}
+		code := row
+		if i := strings.IndexAny(row, " \t"); i > 0 {
+			code = row[:i]
+		}
+		switch code {
+		case "H":
+		case "TX", "TC":
+			committed, pending = append(committed, pending...), nil
+			inTx = code == "TX"
+		case "TA":
+			pending, inTx = nil, false
+		case "A", "D":
+			t, err := parsePatchTriple(row[len(code):])
+			if err != nil {
+				return GraphDiff{}, fmt.Errorf("patch: line %d: %s", line, err)
+			}
+			op := addOperation
+			if code == "D" {
+				op = removeOperation
+			}
+			pending = append(pending, operation{op: op, triple: t})
+		default:
+			return GraphDiff{}, fmt.Errorf("patch: line %d: unsupported row '%s'", line, code)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return GraphDiff{}, err
+	}
+	if inTx {
+		return GraphDiff{}, errors.New("patch: transaction neither committed nor aborted")
+	}
+	committed = append(committed, pending...)
+
+	last := make(map[string]operation)
+	var order []string
+	for _, o := range committed {
+		k := o.triple.(*triple).key()
+		if _, ok := last[k]; !ok {
+			order = append(order, k)
+		}
+		last[k] = o
+	}
+	var d GraphDiff
+	for _, k := range order {
+		if o := last[k]; o.op == addOperation {
+			d.Added = append(d.Added, o.triple)
+		} else {
+			d.Removed = append(d.Removed, o.triple)
+		}
+	}
+	return d, nil
+}
+
+// parsePatchTriple parses the triple of a row as Turtle, which unescapes
+// literals written in canonical NTriples, keeping blank node labels as is
+func parsePatchTriple(row string) (Triple, error) {
+	p := newTurtleParser(strings.NewReader(row), nil)
+	p.label = verbatim
+	tris, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if len(tris) != 1 {
+		return nil, errors.New("expected a single triple")
+	}
+	return tris[0], nil
+}
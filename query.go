@@ -0,0 +1,255 @@
+package triplestore
+
+import "sort"
+
+// A Binding maps variable names to the RDF terms they are bound to.
+// Resources and blank nodes matched in subject or predicate position
+// are given as Object, the same way as in object position.
+type Binding map[string]Object
+
+func (b Binding) clone() Binding {
+	cloned := make(Binding, len(b)+1)
+	for k, v := range b {
+		cloned[k] = v
+	}
+	return cloned
+}
+
+// A Term is a component of a triple pattern: either a named variable
+// or a concrete value
+type Term struct {
+	variable string
+	value    object
+}
+
+// Var returns a variable term. Variables are written ?name in text queries
+func Var(name string) Term {
+	return Term{variable: name}
+}
+
+// Val returns a term matching exactly the given object
+func Val(o Object) Term {
+	return Term{value: o.(object)}
+}
+
+// Res returns a term matching exactly the given resource
+func Res(s string) Term {
+	return Term{value: object{resource: s}}
+}
+
+func (t Term) IsVar() bool {
+	return t.variable != ""
+}
+
+func (t Term) Name() string {
+	return t.variable
+}
+
+func (t Term) Value() Object {
+	return t.value
+}
+
+// A TriplePattern is a triple whose subject, predicate or object may be variables
+type TriplePattern struct {
+	Subject, Predicate, Object Term
+}
+
+func NewPattern(s, p, o Term) TriplePattern {
+	return TriplePattern{Subject: s, Predicate: p, Object: o}
+}
+
+func (tp TriplePattern) terms() [3]Term {
+	return [3]Term{tp.Subject, tp.Predicate, tp.Object}
+}
+
+// Query evaluates the basic graph pattern made of the given triple patterns
+// against the graph and returns one binding per solution.
+//
+// Patterns are evaluated most selective first: at each step the pattern with the
+// fewest unbound positions is picked, ties being broken by the size of the index
+// entry its constants point to. Partial solutions are then joined by substituting
+// bound variables before looking up the next pattern.
+func Query(g RDFGraph, patterns ...TriplePattern) []Binding {
+	return evalBGP(g, patterns, []Binding{{}})
+}
+
+func evalBGP(g RDFGraph, patterns []TriplePattern, seeds []Binding) []Binding {
+	if len(seeds) == 0 {
+		return nil
+	}
+
+	bound := make(map[string]bool)
+	for v := range seeds[0] {
+		bound[v] = true
+	}
+
+	solutions := seeds
+	for _, tp := range planPatterns(g, patterns, bound) {
+		var next []Binding
+		for _, b := range solutions {
+			next = append(next, extendBinding(g, tp, b)...)
+		}
+		solutions = next
+		if len(solutions) == 0 {
+			return nil
+		}
+	}
+
+	return solutions
+}
+
+func planPatterns(g RDFGraph, patterns []TriplePattern, bound map[string]bool) []TriplePattern {
+	type candidate struct {
+		tp          TriplePattern
+		cardinality int
+	}
+
+	var remaining []candidate
+	for _, tp := range patterns {
+		remaining = append(remaining, candidate{tp: tp, cardinality: estimatePattern(g, tp)})
+	}
+
+	unboundCount := func(tp TriplePattern) (count int) {
+		for _, t := range tp.terms() {
+			if t.IsVar() && !bound[t.variable] {
+				count++
+			}
+		}
+		return
+	}
+
+	var plan []TriplePattern
+	for len(remaining) > 0 {
+		sort.SliceStable(remaining, func(i, j int) bool {
+			ui, uj := unboundCount(remaining[i].tp), unboundCount(remaining[j].tp)
+			if ui != uj {
+				return ui < uj
+			}
+			return remaining[i].cardinality < remaining[j].cardinality
+		})
+		next := remaining[0].tp
+		plan = append(plan, next)
+		for _, t := range next.terms() {
+			if t.IsVar() {
+				bound[t.variable] = true
+			}
+		}
+		remaining = remaining[1:]
+	}
+
+	return plan
+}
+
+// estimatePattern returns the number of triples matching the constant
+// positions of the pattern, variables being considered unbound
+func estimatePattern(g RDFGraph, tp TriplePattern) int {
+	var s, p *string
+	var o Object
+	if !tp.Subject.IsVar() {
+		if tp.Subject.value.isLit {
+			return 0
+		}
+		sub := subjectString(tp.Subject.value)
+		s = &sub
+	}
+	if !tp.Predicate.IsVar() {
+		if tp.Predicate.value.isLit || tp.Predicate.value.isBnode {
+			return 0
+		}
+		p = &tp.Predicate.value.resource
+	}
+	if !tp.Object.IsVar() {
+		o = tp.Object.value
+	}
+	return Cardinality(g, s, p, o)
+}
+
+func extendBinding(g RDFGraph, tp TriplePattern, b Binding) (out []Binding) {
+	resolve := func(t Term) *object {
+		if !t.IsVar() {
+			return &t.value
+		}
+		if val, ok := b[t.variable]; ok {
+			obj := val.(object)
+			return &obj
+		}
+		return nil
+	}
+
+	for _, tri := range matchTerms(g, resolve(tp.Subject), resolve(tp.Predicate), resolve(tp.Object)) {
+		tr := tri.(*triple)
+		values := [3]object{subjectObject(tr), {resource: tr.pred}, tr.obj}
+
+		extended := b.clone()
+		consistent := true
+		for i, t := range tp.terms() {
+			if !t.IsVar() {
+				continue
+			}
+			if already, ok := extended[t.variable]; ok {
+				if already.(object).key() != values[i].key() {
+					consistent = false
+					break
+				}
+				continue
+			}
+			extended[t.variable] = values[i]
+		}
+		if consistent {
+			out = append(out, extended)
+		}
+	}
+	return
+}
+
+// matchTerms returns the triples matching the given subject, predicate and object,
+// a nil component matching anything. Unlike Match, resource subjects and blank node
+// subjects are distinguished.
+func matchTerms(g RDFGraph, s, p, o *object) []Triple {
+	if s != nil && s.isLit {
+		return nil
+	}
+	if p != nil && (p.isLit || p.isBnode) {
+		return nil
+	}
+
+	var sub, pred *string
+	if s != nil {
+		str := subjectString(*s)
+		sub = &str
+	}
+	if p != nil {
+		pred = &p.resource
+	}
+	var obj Object
+	if o != nil {
+		obj = *o
+	}
+	candidates := Match(g, sub, pred, obj)
+	if s == nil {
+		return candidates
+	}
+
+	// subject indexes do not distinguish resources from blank nodes
+	var out []Triple
+	for _, tri := range candidates {
+		if tri.(*triple).isSubBnode == s.isBnode {
+			out = append(out, tri)
+		}
+	}
+	return out
+}
+
+func subjectObject(t *triple) object {
+	if t.isSubBnode {
+		return object{bnode: t.sub, isBnode: true}
+	}
+	return object{resource: t.sub}
+}
+
+func subjectString(o object) string {
+	if o.isBnode {
+		return o.bnode
+	}
+	return o.resource
+}
@@ -0,0 +1,106 @@
+package triplestore_test
+
+import (
+	"sort"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestQueryBasicGraphPattern(t *testing.T) {
+	s := tstore.NewSource()
+	s.Add(
+		tstore.SubjPred("alice", "worksAt").Resource("acme"),
+		tstore.SubjPred("bob", "worksAt").Resource("acme"),
+		tstore.SubjPred("carol", "worksAt").Resource("initech"),
+		tstore.SubjPred("acme", "city").StringLiteral("Paris"),
+		tstore.SubjPred("initech", "city").StringLiteral("London"),
+		tstore.SubjPred("alice", "age").IntegerLiteral(31),
+		tstore.SubjPred("bob", "knows").Resource("bob"),
+		tstore.BnodePred("acme", "city").StringLiteral("Paris"),
+	)
+	g := s.Snapshot()
+
+	t.Run("join on shared variable", func(t *testing.T) {
+		results := tstore.Query(g,
+			tstore.NewPattern(tstore.Var("person"), tstore.Res("worksAt"), tstore.Var("org")),
+			tstore.NewPattern(tstore.Var("org"), tstore.Res("city"), tstore.Val(tstore.StringLiteral("Paris"))),
+		)
+		if got, want := boundResources(results, "person"), []string{"alice", "bob"}; !equalStrings(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		for _, b := range results {
+			if got, want := b["org"], tstore.Resource("acme"); !got.Equal(want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		}
+	})
+
+	t.Run("variable predicate", func(t *testing.T) {
+		results := tstore.Query(g, tstore.NewPattern(tstore.Res("alice"), tstore.Var("p"), tstore.Var("o")))
+		if got, want := boundResources(results, "p"), []string{"age", "worksAt"}; !equalStrings(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("repeated variable in pattern", func(t *testing.T) {
+		results := tstore.Query(g, tstore.NewPattern(tstore.Var("x"), tstore.Var("p"), tstore.Var("x")))
+		if got, want := boundResources(results, "x"), []string{"bob"}; !equalStrings(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("blank node subject", func(t *testing.T) {
+		results := tstore.Query(g, tstore.NewPattern(tstore.Val(tstore.Bnode("acme")), tstore.Res("city"), tstore.Var("city")))
+		if got, want := len(results), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		results = tstore.Query(g, tstore.NewPattern(tstore.Res("acme"), tstore.Res("city"), tstore.Var("city")))
+		if got, want := len(results), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("fully bound pattern", func(t *testing.T) {
+		results := tstore.Query(g, tstore.NewPattern(tstore.Res("alice"), tstore.Res("age"), tstore.Val(tstore.IntegerLiteral(31))))
+		if got, want := len(results), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		results = tstore.Query(g, tstore.NewPattern(tstore.Res("alice"), tstore.Res("age"), tstore.Val(tstore.IntegerLiteral(32))))
+		if got, want := len(results), 0; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("no solution", func(t *testing.T) {
+		results := tstore.Query(g,
+			tstore.NewPattern(tstore.Var("person"), tstore.Res("worksAt"), tstore.Var("org")),
+			tstore.NewPattern(tstore.Var("org"), tstore.Res("city"), tstore.Val(tstore.StringLiteral("Berlin"))),
+		)
+		if got, want := len(results), 0; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	})
+}
+
+func boundResources(bindings []tstore.Binding, name string) (out []string) {
+	for _, b := range bindings {
+		if res, ok := b[name].Resource(); ok {
+			out = append(out, res)
+		}
+	}
+	sort.Strings(out)
+	return
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -59,10 +59,11 @@ func (t *triple) key() string {
 
 func (t *triple) clone() *triple {
 	return &triple{
-		sub:    t.sub,
-		pred:   t.pred,
-		obj:    t.obj,
-		triKey: t.triKey,
+		sub:        t.sub,
+		isSubBnode: t.isSubBnode,
+		pred:       t.pred,
+		obj:        t.obj,
+		triKey:     t.triKey,
 	}
 }
 
@@ -99,6 +100,11 @@ func (o object) Bnode() (string, bool) {
 	return o.bnode, o.isBnode
 }
 
+// iri returns the resource of the object, false for literals and blank nodes
+func (o object) iri() (string, bool) {
+	return o.resource, !o.isLit && !o.isBnode
+}
+
 func (o object) key() string {
 	if o.isLit {
 		if o.lit.langtag != "" {
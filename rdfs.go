@@ -0,0 +1,356 @@
+package triplestore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const rdfsNamespace = "http://www.w3.org/2000/01/rdf-schema#"
+
+// A RDFSReasoner materialises the RDFS entailments of a graph by forward
+// chaining the rules rdfs2 (domain), rdfs3 (range), rdfs5 (subPropertyOf
+// transitivity), rdfs7 (subPropertyOf), rdfs9 (subClassOf) and rdfs11
+// (subClassOf transitivity).
+//
+// RDF and RDFS terms are recognised both as full IRIs and as compact IRIs
+// with the prefixes of the context (ex: rdfs:subClassOf). Inferred triples
+// use the form of the triples they are inferred from. When the two premises
+// of a subClassOf or subPropertyOf transitivity differ in form, the inferred
+// triple uses the full IRI, whatever the order the premises were added in.
+type RDFSReasoner struct {
+	types, subClasses, subProperties, domains, ranges []string
+	// compact form of rdf:type, full IRI when the context has no rdf prefix
+	compactType string
+}
+
+// NewRDFSReasoner returns a reasoner recognising the prefixes of the given context,
+// RDFContext when nil
+func NewRDFSReasoner(c *Context) *RDFSReasoner {
+	if c == nil {
+		c = RDFContext
+	}
+	r := &RDFSReasoner{
+		types:         vocabularyForms(c, rdfNamespace, "type"),
+		subClasses:    vocabularyForms(c, rdfsNamespace, "subClassOf"),
+		subProperties: vocabularyForms(c, rdfsNamespace, "subPropertyOf"),
+		domains:       vocabularyForms(c, rdfsNamespace, "domain"),
+		ranges:        vocabularyForms(c, rdfsNamespace, "range"),
+	}
+	r.compactType = r.types[len(r.types)-1]
+	return r
+}
+
+// Materialize returns a graph with the triples of the given graph
+// and all the triples entailed by them
+func (r *RDFSReasoner) Materialize(g RDFGraph) RDFGraph {
+	m := NewSource().(*source)
+	m.Add(g.Triples()...)
+	r.saturate(m, g.Triples())
+	return m.Snapshot()
+}
+
+// saturate adds to the source the consequences of the given triples,
+// already in the source, until no new triple is inferred
+func (r *RDFSReasoner) saturate(m *source, delta []Triple) {
+	for len(delta) > 0 {
+		snap := m.Snapshot()
+		seen := make(map[string]bool)
+		var next []Triple
+		for _, t := range delta {
+			for _, inferred := range r.derive(t.(*triple), snap) {
+				if !seen[inferred.key()] && !snap.Contains(inferred) {
+					seen[inferred.key()] = true
+					next = append(next, inferred)
+				}
+			}
+		}
+		m.Add(next...)
+		delta = next
+	}
+}
+
+// derive returns the triples inferred in one step from the given triple
+// and the triples of the graph
+func (r *RDFSReasoner) derive(t *triple, g RDFGraph) (out []*triple) {
+	// t as any triple, with its predicate described in the graph
+	for _, domain := range r.domains {
+		for _, d := range withSubjPred(g, t.pred, false, domain) {
+			out = append(out, &triple{sub: t.sub, isSubBnode: t.isSubBnode, pred: r.typeOf(domain), obj: d.obj})
+		}
+	}
+	for _, rng := range r.ranges {
+		if t.obj.isLit {
+			break
+		}
+		for _, d := range withSubjPred(g, t.pred, false, rng) {
+			sub, isBnode := objectSubject(t.obj)
+			out = append(out, &triple{sub: sub, isSubBnode: isBnode, pred: r.typeOf(rng), obj: d.obj})
+		}
+	}
+	for _, subProp := range r.subProperties {
+		for _, d := range withSubjPred(g, t.pred, false, subProp) {
+			if super, ok := d.obj.iri(); ok {
+				out = append(out, &triple{sub: t.sub, isSubBnode: t.isSubBnode, pred: super, obj: t.obj})
+			}
+		}
+	}
+
+	// t as a description of properties or classes
+	switch {
+	case isOneOf(r.domains, t.pred) && !t.isSubBnode:
+		for _, d := range g.WithPredicate(t.sub) {
+			dt := d.(*triple)
+			out = append(out, &triple{sub: dt.sub, isSubBnode: dt.isSubBnode, pred: r.typeOf(t.pred), obj: t.obj})
+		}
+	case isOneOf(r.ranges, t.pred) && !t.isSubBnode:
+		for _, d := range g.WithPredicate(t.sub) {
+			if dt := d.(*triple); !dt.obj.isLit {
+				sub, isBnode := objectSubject(dt.obj)
+				out = append(out, &triple{sub: sub, isSubBnode: isBnode, pred: r.typeOf(t.pred), obj: t.obj})
+			}
+		}
+	case isOneOf(r.subProperties, t.pred) && !t.isSubBnode:
+		super, ok := t.obj.iri()
+		if !ok {
+			break
+		}
+		for _, d := range g.WithPredicate(t.sub) {
+			dt := d.(*triple)
+			out = append(out, &triple{sub: dt.sub, isSubBnode: dt.isSubBnode, pred: super, obj: dt.obj})
+		}
+		out = append(out, r.transitive(t, g, r.subProperties)...)
+	case isOneOf(r.types, t.pred):
+		if t.obj.isLit {
+			break
+		}
+		class, isBnode := objectSubject(t.obj)
+		for _, subClass := range r.subClasses {
+			for _, d := range withSubjPred(g, class, isBnode, subClass) {
+				out = append(out, &triple{sub: t.sub, isSubBnode: t.isSubBnode, pred: t.pred, obj: d.obj})
+			}
+		}
+	case isOneOf(r.subClasses, t.pred):
+		for _, typ := range r.types {
+			for _, d := range g.WithPredObj(typ, subjectObject(t)) {
+				dt := d.(*triple)
+				out = append(out, &triple{sub: dt.sub, isSubBnode: dt.isSubBnode, pred: typ, obj: t.obj})
+			}
+		}
+		out = append(out, r.transitive(t, g, r.subClasses)...)
+	}
+	return
+}
+
+// transitive returns the triples inferred from the given triple whose
+// predicate is transitive, with any of the given forms
+func (r *RDFSReasoner) transitive(t *triple, g RDFGraph, forms []string) (out []*triple) {
+	for _, form := range forms {
+		pred := transitiveForm(forms, t.pred, form)
+		if !t.obj.isLit {
+			sub, isBnode := objectSubject(t.obj)
+			for _, d := range withSubjPred(g, sub, isBnode, form) {
+				out = append(out, &triple{sub: t.sub, isSubBnode: t.isSubBnode, pred: pred, obj: d.obj})
+			}
+		}
+		for _, d := range g.WithPredObj(form, subjectObject(t)) {
+			dt := d.(*triple)
+			out = append(out, &triple{sub: dt.sub, isSubBnode: dt.isSubBnode, pred: pred, obj: t.obj})
+		}
+	}
+	return
+}
+
+// transitiveForm returns the form of a predicate inferred by transitivity
+// from premises with the given forms: theirs when equal, the full IRI otherwise
+func transitiveForm(forms []string, one, other string) string {
+	if one == other {
+		return one
+	}
+	return forms[0]
+}
+
+// typeOf returns the form of rdf:type matching the form of the given rdfs predicate
+func (r *RDFSReasoner) typeOf(pred string) string {
+	if strings.HasPrefix(pred, rdfsNamespace) {
+		return rdfType
+	}
+	return r.compactType
+}
+
+// derivable returns true when the triple is inferred in one step from the
+// triples of the graph. All rules have a premise with the subject of the
+// inferred triple as subject or object.
+func (r *RDFSReasoner) derivable(t *triple, g RDFGraph) bool {
+	premises := withSubject(g, t.sub, t.isSubBnode)
+	premises = append(premises, g.WithObject(subjectObject(t))...)
+	for _, p := range premises {
+		for _, inferred := range r.derive(p.(*triple), g) {
+			if inferred.key() == t.key() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// A Materialization keeps the RDFS materialisation of a source up to date,
+// re-materialising incrementally as the source changes: added triples are
+// forward chained, removed triples are handled by deleting their consequences
+// then rederiving the ones still entailed by other triples.
+type Materialization struct {
+	reasoner *RDFSReasoner
+	src      Source
+	events   <-chan ChangeEvent
+
+	mu                     sync.Mutex
+	version                uint64
+	explicit, materialized *source
+}
+
+// MaterializeSource materialises the source and follows its changes until the
+// context is done
+func (r *RDFSReasoner) MaterializeSource(ctx context.Context, src Source) *Materialization {
+	m := &Materialization{
+		reasoner:     r,
+		src:          src,
+		events:       src.Watch(ctx),
+		explicit:     NewSource().(*source),
+		materialized: NewSource().(*source),
+	}
+	m.version = src.Version()
+	tris := src.Snapshot().Triples()
+	m.explicit.Add(tris...)
+	m.materialized.Add(tris...)
+	r.saturate(m.materialized, tris)
+	return m
+}
+
+// Snapshot returns the materialisation of the source, including
+// all the changes committed before the call
+func (m *Materialization) Snapshot() RDFGraph {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target := m.src.Version()
+	for m.version < target {
+		evt, ok := <-m.events
+		if !ok {
+			break
+		}
+		// changes committed before the initial snapshot are reapplied
+		// harmlessly, in order, as their final state is the one of the snapshot
+		m.remove(evt.Removed)
+		m.add(evt.Added)
+		if evt.Version > m.version {
+			m.version = evt.Version
+		}
+	}
+	return m.materialized.Snapshot()
+}
+
+func (m *Materialization) add(tris []Triple) {
+	m.explicit.Add(tris...)
+	snap := m.materialized.Snapshot()
+	var delta []Triple
+	for _, t := range tris {
+		if !snap.Contains(t) {
+			delta = append(delta, t)
+		}
+	}
+	m.materialized.Add(delta...)
+	m.reasoner.saturate(m.materialized, delta)
+}
+
+func (m *Materialization) remove(tris []Triple) {
+	if len(tris) == 0 {
+		return
+	}
+	m.explicit.Remove(tris...)
+	explicit := m.explicit.Snapshot()
+	before := m.materialized.Snapshot()
+
+	// overdelete all the triples inferred from the removed ones
+	deleted := make(map[string]bool)
+	var overdeleted, delta []Triple
+	for _, t := range tris {
+		if before.Contains(t) && !deleted[t.(*triple).key()] {
+			deleted[t.(*triple).key()] = true
+			delta = append(delta, t)
+		}
+	}
+	for len(delta) > 0 {
+		overdeleted = append(overdeleted, delta...)
+		var next []Triple
+		for _, t := range delta {
+			for _, inferred := range m.reasoner.derive(t.(*triple), before) {
+				if !deleted[inferred.key()] && before.Contains(inferred) {
+					deleted[inferred.key()] = true
+					next = append(next, inferred)
+				}
+			}
+		}
+		delta = next
+	}
+	m.materialized.Remove(overdeleted...)
+
+	// rederive the ones still explicit or entailed
+	after := m.materialized.Snapshot()
+	var rederived []Triple
+	for _, t := range overdeleted {
+		if explicit.Contains(t) || m.reasoner.derivable(t.(*triple), after) {
+			rederived = append(rederived, t)
+		}
+	}
+	m.materialized.Add(rederived...)
+	m.reasoner.saturate(m.materialized, rederived)
+}
+
+// vocabularyForms returns the full IRI of the term, followed
+// by its compact forms with the prefixes of the context
+func vocabularyForms(c *Context, ns, local string) []string {
+	out := []string{ns + local}
+	for prefix, iri := range c.Prefixes {
+		if iri == ns {
+			out = append(out, prefix+":"+local)
+		}
+	}
+	sort.Strings(out[1:])
+	return out
+}
+
+func withSubjPred(g RDFGraph, sub string, isBnode bool, pred string) (out []*triple) {
+	for _, t := range g.WithSubjPred(sub, pred) {
+		if tt := t.(*triple); tt.isSubBnode == isBnode {
+			out = append(out, tt)
+		}
+	}
+	return
+}
+
+func withSubject(g RDFGraph, sub string, isBnode bool) (out []Triple) {
+	for _, t := range g.WithSubject(sub) {
+		if t.(*triple).isSubBnode == isBnode {
+			out = append(out, t)
+		}
+	}
+	return
+}
+
+// objectSubject returns the resource or blank node of the object as subject
+func objectSubject(o object) (string, bool) {
+	if o.isBnode {
+		return o.bnode, true
+	}
+	return o.resource, false
+}
+
+func isOneOf(forms []string, s string) bool {
+	for _, f := range forms {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
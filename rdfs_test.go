@@ -0,0 +1,141 @@
+package triplestore_test
+
+import (
+	"context"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestRDFSMaterialize(t *testing.T) {
+	src := tstore.NewSource()
+	src.Add(
+		tstore.SubjPredRes("Cat", "rdfs:subClassOf", "Mammal"),
+		tstore.SubjPredRes("Mammal", "http://www.w3.org/2000/01/rdf-schema#subClassOf", "Animal"),
+		tstore.SubjPredRes("owns", "rdfs:domain", "Person"),
+		tstore.SubjPredRes("owns", "rdfs:range", "Animal"),
+		tstore.SubjPredRes("owns", "rdfs:subPropertyOf", "knows"),
+		tstore.SubjPredRes("knows", "rdfs:subPropertyOf", "meets"),
+		tstore.SubjPredRes("felix", "rdf:type", "Cat"),
+		tstore.SubjPredRes("me", "owns", "felix"),
+		tstore.SubjPredBnode("me", "owns", "pet"),
+	)
+
+	g := tstore.NewRDFSReasoner(nil).Materialize(src.Snapshot())
+
+	expected := []tstore.Triple{
+		tstore.SubjPredRes("Cat", "http://www.w3.org/2000/01/rdf-schema#subClassOf", "Animal"), // rdfs11, full IRI as forms differ
+		tstore.SubjPredRes("owns", "rdfs:subPropertyOf", "meets"),                              // rdfs5
+		tstore.SubjPredRes("felix", "rdf:type", "Mammal"),                                      // rdfs9
+		tstore.SubjPredRes("felix", "rdf:type", "Animal"),                                      // rdfs9
+		tstore.SubjPredRes("me", "rdf:type", "Person"),                                         // rdfs2
+		tstore.BnodePredRes("pet", "rdf:type", "Animal"),                                       // rdfs3
+		tstore.SubjPredRes("me", "knows", "felix"),                                             // rdfs7
+		tstore.SubjPredRes("me", "meets", "felix"),                                             // rdfs7
+		tstore.SubjPredBnode("me", "knows", "pet"),                                             // rdfs7
+		tstore.SubjPredBnode("me", "meets", "pet"),                                             // rdfs7
+	}
+	for _, tri := range expected {
+		if !g.Contains(tri) {
+			t.Fatalf("expected %v to be inferred", tri)
+		}
+	}
+	if got, want := g.Count(), 9+len(expected); got != want {
+		t.Fatalf("got %d, want %d:\n%v", got, want, tstore.Triples(g.Triples()))
+	}
+}
+
+func TestRDFSMaterializeSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := tstore.NewSource()
+	src.Add(
+		tstore.SubjPredRes("Cat", "rdfs:subClassOf", "Mammal"),
+		tstore.SubjPredRes("Mammal", "rdfs:subClassOf", "Animal"),
+		tstore.SubjPredRes("felix", "rdf:type", "Cat"),
+	)
+	m := tstore.NewRDFSReasoner(nil).MaterializeSource(ctx, src)
+
+	isAnimal := tstore.SubjPredRes("felix", "rdf:type", "Animal")
+	if !m.Snapshot().Contains(isAnimal) {
+		t.Fatalf("expected %v to be inferred", isAnimal)
+	}
+
+	// felix remains an animal as a dog
+	src.Add(
+		tstore.SubjPredRes("Dog", "rdfs:subClassOf", "Animal"),
+		tstore.SubjPredRes("felix", "rdf:type", "Dog"),
+	)
+	src.Remove(tstore.SubjPredRes("felix", "rdf:type", "Cat"))
+	g := m.Snapshot()
+	if !g.Contains(isAnimal) {
+		t.Fatalf("expected %v to be inferred", isAnimal)
+	}
+	if isMammal := tstore.SubjPredRes("felix", "rdf:type", "Mammal"); g.Contains(isMammal) {
+		t.Fatalf("expected %v to be retracted", isMammal)
+	}
+
+	src.Remove(tstore.SubjPredRes("Dog", "rdfs:subClassOf", "Animal"))
+	g = m.Snapshot()
+	if g.Contains(isAnimal) {
+		t.Fatalf("expected %v to be retracted", isAnimal)
+	}
+	if got, want := tstore.Triples(g.Triples()), tstore.Triples(tstore.NewRDFSReasoner(nil).Materialize(src.Snapshot()).Triples()); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestRDFSMaterializeSourceMixedForms(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	full := "http://www.w3.org/2000/01/rdf-schema#subClassOf"
+	src := tstore.NewSource()
+	src.Add(
+		tstore.SubjPredRes("A", "rdfs:subClassOf", "B"),
+		tstore.SubjPredRes("x", "rdf:type", "A"),
+	)
+	m := tstore.NewRDFSReasoner(nil).MaterializeSource(ctx, src)
+
+	steps := []struct {
+		add, remove []tstore.Triple
+	}{
+		{add: []tstore.Triple{tstore.SubjPredRes("B", full, "C")}},
+		{add: []tstore.Triple{tstore.SubjPredRes("C", "rdfs:subClassOf", "D")}},
+		{add: []tstore.Triple{tstore.SubjPredRes("A", full, "B"), tstore.SubjPredRes("B", full, "D")}},
+		{remove: []tstore.Triple{tstore.SubjPredRes("C", "rdfs:subClassOf", "D"), tstore.SubjPredRes("B", full, "D")}},
+	}
+	for i, step := range steps {
+		src.Add(step.add...)
+		src.Remove(step.remove...)
+		got := tstore.Triples(m.Snapshot().Triples())
+		want := tstore.Triples(tstore.NewRDFSReasoner(nil).Materialize(src.Snapshot()).Triples())
+		if !got.Equal(want) {
+			t.Fatalf("step %d: got %v, want %v", i, got, want)
+		}
+	}
+	for _, pred := range []string{full, "rdfs:subClassOf"} {
+		if tris := m.Snapshot().WithPredObj(pred, tstore.Resource("D")); len(tris) != 0 {
+			t.Fatalf("expected no subclass of D, got %v", tris)
+		}
+	}
+}
+
+func TestRDFSBnodeSuperProperty(t *testing.T) {
+	src := tstore.NewSource()
+	src.Add(
+		tstore.SubjPredBnode("owns", "rdfs:subPropertyOf", "b"),
+		tstore.SubjPredRes("me", "owns", "felix"),
+	)
+
+	g := tstore.NewRDFSReasoner(nil).Materialize(src.Snapshot())
+	for _, tri := range g.Triples() {
+		if tri.Predicate() == "" {
+			t.Fatalf("unexpected triple with empty predicate %v", tri)
+		}
+	}
+	if got, want := g.Count(), 2; got != want {
+		t.Fatalf("got %d, want %d:\n%v", got, want, tstore.Triples(g.Triples()))
+	}
+}
@@ -0,0 +1,418 @@
+package triplestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	xmlNamespace   = "http://www.w3.org/XML/1998/namespace"
+	rdfXMLLiteral  = rdfNamespace + "XMLLiteral"
+	xmlnsAttribute = "xmlns"
+)
+
+// NewRDFXMLDecoder decodes the W3C RDF/XML format. Reification
+// (i.e. rdf:ID on property elements) is not supported and ignored.
+func NewRDFXMLDecoder(r io.Reader) Decoder {
+	return &rdfxmlDecoder{r: r}
+}
+
+// NewRDFXMLDecoderWithContext decodes the W3C RDF/XML format,
+// resolving relative IRIs against the base of the context
+func NewRDFXMLDecoderWithContext(r io.Reader, c *Context) Decoder {
+	return &rdfxmlDecoder{r: r, c: c}
+}
+
+type rdfxmlDecoder struct {
+	r io.Reader
+	c *Context
+}
+
+func (dec *rdfxmlDecoder) Decode() ([]Triple, error) {
+	// the whole document is kept to extract XML literals
+	raw, err := ioutil.ReadAll(dec.r)
+	if err != nil {
+		return nil, err
+	}
+	p := &rdfxmlParser{raw: raw, d: xml.NewDecoder(bytes.NewReader(raw))}
+	p.d.Entity = make(map[string]string)
+	var base string
+	if dec.c != nil {
+		base = dec.c.Base
+	}
+	if err := p.parse(rdfxmlScope{base: base}); err != nil {
+		line, _ := p.position()
+		return nil, fmt.Errorf("rdfxml: line %d: %s", line, err)
+	}
+	return p.triples, nil
+}
+
+// Loosely detect if a XML format, contrary to ntriples whose IRIs
+// contain no spaces and to binary format. The returned reader must be used.
+func IsRDFXMLFormat(r io.Reader) (bool, io.Reader) {
+	buffered := bufio.NewReader(r)
+	b := peekText(buffered)
+	if bytes.HasPrefix(b, []byte("<?")) || bytes.HasPrefix(b, []byte("<!")) {
+		return true, buffered
+	}
+	if !bytes.HasPrefix(b, []byte("<")) {
+		return false, buffered
+	}
+	end := bytes.IndexByte(b, '>')
+	if end < 0 {
+		end = len(b)
+	}
+	return bytes.ContainsAny(b[:end], " \t\r\n"), buffered
+}
+
+type rdfxmlScope struct {
+	base, lang string
+}
+
+type rdfxmlParser struct {
+	d       *xml.Decoder
+	raw     []byte
+	genid   int
+	triples []Triple
+}
+
+var entityDeclaration = regexp.MustCompile(`<!ENTITY\s+([^\s%]+)\s+(?:"([^"]*)"|'([^']*)')\s*>`)
+
+func (p *rdfxmlParser) position() (int, int) {
+	offset := int(p.d.InputOffset())
+	if offset > len(p.raw) {
+		offset = len(p.raw)
+	}
+	return bytes.Count(p.raw[:offset], []byte("\n")) + 1, offset
+}
+
+func (p *rdfxmlParser) parse(scope rdfxmlScope) error {
+	for {
+		tok, err := p.d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.Directive:
+			// entities declared in the DTD are commonly used for namespaces
+			for _, m := range entityDeclaration.FindAllStringSubmatch(string(t), -1) {
+				p.d.Entity[m[1]] = m[2] + m[3]
+			}
+		case xml.StartElement:
+			if isRDFName(t.Name, "RDF") {
+				return p.nodeElementList(p.scope(scope, t))
+			}
+			_, err := p.nodeElement(t, scope)
+			return err
+		}
+	}
+}
+
+func (p *rdfxmlParser) scope(parent rdfxmlScope, start xml.StartElement) rdfxmlScope {
+	scope := parent
+	for _, attr := range start.Attr {
+		if attr.Name.Space == xmlNamespace {
+			switch attr.Name.Local {
+			case "base":
+				scope.base = resolveIRIAgainst(parent.base, attr.Value)
+				if i := strings.IndexByte(scope.base, '#'); i >= 0 {
+					scope.base = scope.base[:i]
+				}
+			case "lang":
+				scope.lang = attr.Value
+			}
+		}
+	}
+	return scope
+}
+
+func (p *rdfxmlParser) nodeElementList(scope rdfxmlScope) error {
+	for {
+		tok, err := p.d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if _, err := p.nodeElement(t, scope); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return fmt.Errorf("unexpected text '%s'", bytes.TrimSpace(t))
+			}
+		}
+	}
+}
+
+// nodeElement emits the triples of a node element, returning its subject
+func (p *rdfxmlParser) nodeElement(start xml.StartElement, parent rdfxmlScope) (object, error) {
+	scope := p.scope(parent, start)
+	sub, err := p.subject(start, scope)
+	if err != nil {
+		return object{}, err
+	}
+
+	if !isRDFName(start.Name, "Description") {
+		p.emit(sub, rdfType, object{resource: elementIRI(start.Name)})
+	}
+	p.propertyAttributes(sub, start, scope)
+
+	return sub, p.propertyElements(sub, scope)
+}
+
+func (p *rdfxmlParser) subject(start xml.StartElement, scope rdfxmlScope) (object, error) {
+	var sub object
+	found := 0
+	for _, attr := range start.Attr {
+		switch {
+		case isRDFName(attr.Name, "about"):
+			sub, found = object{resource: resolveIRIAgainst(scope.base, attr.Value)}, found+1
+		case isRDFName(attr.Name, "ID"):
+			sub, found = object{resource: resolveIRIAgainst(scope.base, "#"+attr.Value)}, found+1
+		case isRDFName(attr.Name, "nodeID"):
+			sub, found = object{bnode: documentBnode(attr.Value), isBnode: true}, found+1
+		}
+	}
+	if found > 1 {
+		return object{}, errors.New("node element with several of rdf:about, rdf:ID and rdf:nodeID")
+	}
+	if found == 0 {
+		sub = p.freshBnode()
+	}
+	return sub, nil
+}
+
+func (p *rdfxmlParser) propertyAttributes(sub object, start xml.StartElement, scope rdfxmlScope) {
+	for _, attr := range start.Attr {
+		if !isPropertyAttribute(attr.Name) {
+			continue
+		}
+		if isRDFName(attr.Name, "type") {
+			p.emit(sub, rdfType, object{resource: resolveIRIAgainst(scope.base, attr.Value)})
+			continue
+		}
+		p.emit(sub, elementIRI(attr.Name), object{isLit: true, lit: literal{typ: XsdString, val: attr.Value, langtag: scope.lang}})
+	}
+}
+
+func (p *rdfxmlParser) propertyElements(sub object, scope rdfxmlScope) error {
+	li := 0
+	for {
+		tok, err := p.d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := p.propertyElement(sub, t, scope, &li); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return fmt.Errorf("unexpected text '%s' in node element", bytes.TrimSpace(t))
+			}
+		}
+	}
+}
+
+func (p *rdfxmlParser) propertyElement(sub object, start xml.StartElement, parent rdfxmlScope, li *int) error {
+	scope := p.scope(parent, start)
+	pred := elementIRI(start.Name)
+	if isRDFName(start.Name, "li") {
+		*li++
+		pred = rdfNamespace + "_" + strconv.Itoa(*li)
+	}
+
+	var parseType, datatype string
+	var obj *object
+	hasPropertyAttributes := false
+	for _, attr := range start.Attr {
+		switch {
+		case isRDFName(attr.Name, "parseType"):
+			parseType = attr.Value
+		case isRDFName(attr.Name, "datatype"):
+			datatype = resolveIRIAgainst(scope.base, attr.Value)
+		case isRDFName(attr.Name, "resource"):
+			obj = &object{resource: resolveIRIAgainst(scope.base, attr.Value)}
+		case isRDFName(attr.Name, "nodeID"):
+			obj = &object{bnode: documentBnode(attr.Value), isBnode: true}
+		case isPropertyAttribute(attr.Name):
+			hasPropertyAttributes = true
+		}
+	}
+
+	switch parseType {
+	case "":
+	case "Resource":
+		node := p.freshBnode()
+		p.emit(sub, pred, node)
+		return p.propertyElements(node, scope)
+	case "Collection":
+		return p.collection(sub, pred, scope)
+	default: // "Literal" and unknown parse types
+		xmlLiteral, err := p.innerXML()
+		if err != nil {
+			return err
+		}
+		p.emit(sub, pred, object{isLit: true, lit: literal{typ: XsdType(rdfXMLLiteral), val: xmlLiteral}})
+		return nil
+	}
+
+	var text bytes.Buffer
+	var child *object
+	for {
+		tok, err := p.d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			if child != nil || obj != nil || hasPropertyAttributes {
+				return fmt.Errorf("property element '%s' with several objects", pred)
+			}
+			node, err := p.nodeElement(t, scope)
+			if err != nil {
+				return err
+			}
+			child = &node
+		case xml.EndElement:
+			switch {
+			case child != nil:
+				if len(bytes.TrimSpace(text.Bytes())) > 0 {
+					return fmt.Errorf("property element '%s' with both text and node", pred)
+				}
+				p.emit(sub, pred, *child)
+			case obj != nil || hasPropertyAttributes:
+				if text.Len() > 0 {
+					return fmt.Errorf("empty property element '%s' with text", pred)
+				}
+				if obj == nil {
+					node := p.freshBnode()
+					obj = &node
+				}
+				p.emit(sub, pred, *obj)
+				p.propertyAttributes(*obj, start, scope)
+			case datatype != "":
+				p.emit(sub, pred, object{isLit: true, lit: literal{typ: xsdTypeFromIRI(datatype), val: text.String()}})
+			default:
+				p.emit(sub, pred, object{isLit: true, lit: literal{typ: XsdString, val: text.String(), langtag: scope.lang}})
+			}
+			return nil
+		}
+	}
+}
+
+func (p *rdfxmlParser) collection(sub object, pred string, scope rdfxmlScope) error {
+	var items []object
+	for {
+		tok, err := p.d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			item, err := p.nodeElement(t, scope)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				return fmt.Errorf("unexpected text '%s' in collection", bytes.TrimSpace(t))
+			}
+		case xml.EndElement:
+			if len(items) == 0 {
+				p.emit(sub, pred, object{resource: rdfNil})
+				return nil
+			}
+			head := p.freshBnode()
+			p.emit(sub, pred, head)
+			node := head
+			for i, item := range items {
+				p.emit(node, rdfFirst, item)
+				next := object{resource: rdfNil}
+				if i < len(items)-1 {
+					next = p.freshBnode()
+				}
+				p.emit(node, rdfRest, next)
+				node = next
+			}
+			return nil
+		}
+	}
+}
+
+// innerXML returns the raw content of the current element, as written in the document
+func (p *rdfxmlParser) innerXML() (string, error) {
+	startOffset := p.d.InputOffset()
+	depth := 0
+	for {
+		offset := p.d.InputOffset()
+		tok, err := p.d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return string(p.raw[startOffset:offset]), nil
+			}
+			depth--
+		}
+	}
+}
+
+func (p *rdfxmlParser) freshBnode() object {
+	p.genid++
+	return object{bnode: generatedBnode(p.genid), isBnode: true}
+}
+
+func (p *rdfxmlParser) emit(sub object, pred string, obj object) {
+	p.triples = append(p.triples, &triple{
+		sub:        subjectString(sub),
+		isSubBnode: sub.isBnode,
+		pred:       pred,
+		obj:        obj,
+	})
+}
+
+func isRDFName(name xml.Name, local string) bool {
+	return name.Space == rdfNamespace && name.Local == local
+}
+
+func elementIRI(name xml.Name) string {
+	return name.Space + name.Local
+}
+
+func isPropertyAttribute(name xml.Name) bool {
+	if name.Space == "" || name.Space == xmlNamespace || name.Space == xmlnsAttribute {
+		return false
+	}
+	if name.Space == rdfNamespace {
+		switch name.Local {
+		case "about", "ID", "nodeID", "resource", "parseType", "datatype", "li", "aboutEach", "aboutEachPrefix", "bagID":
+			return false
+		}
+	}
+	return true
+}
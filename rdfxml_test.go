@@ -0,0 +1,151 @@
+package triplestore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeRDFXML(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE rdf:RDF [<!ENTITY xsd "http://www.w3.org/2001/XMLSchema#">]>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+         xmlns:foaf="http://xmlns.com/foaf/0.1/"
+         xmlns:dc="http://purl.org/dc/elements/1.1/"
+         xml:base="http://ex.org/people/">
+  <foaf:Person rdf:about="me" foaf:nick="js">
+    <foaf:name>jsmith</foaf:name>
+    <foaf:age rdf:datatype="&xsd;integer">26</foaf:age>
+    <foaf:knows rdf:resource="you"/>
+    <foaf:knows rdf:nodeID="friend"/>
+    <foaf:based_near rdf:parseType="Resource">
+      <foaf:name xml:lang="fr">Paris</foaf:name>
+    </foaf:based_near>
+    <foaf:interest>
+      <rdf:Description rdf:about="http://ex.org/topics/rdf" dc:title="RDF"/>
+    </foaf:interest>
+    <foaf:publications rdf:parseType="Collection">
+      <rdf:Description rdf:about="http://ex.org/pub1"/>
+      <rdf:Description rdf:about="http://ex.org/pub2"/>
+    </foaf:publications>
+    <dc:description rdf:parseType="Literal"><b>bold</b> text</dc:description>
+  </foaf:Person>
+  <rdf:Description rdf:nodeID="friend" xml:lang="en">
+    <foaf:title>Mister</foaf:title>
+    <foaf:account dc:title="main"/>
+  </rdf:Description>
+  <rdf:Seq rdf:ID="list" xml:base="http://ex.org/other/doc">
+    <rdf:li rdf:resource="a"/>
+    <rdf:li rdf:resource="b"/>
+  </rdf:Seq>
+</rdf:RDF>`
+
+	tris, err := NewRDFXMLDecoder(strings.NewReader(doc)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foaf, dc := "http://xmlns.com/foaf/0.1/", "http://purl.org/dc/elements/1.1/"
+	me := "http://ex.org/people/me"
+	expected := []Triple{
+		SubjPred(me, rdfType).Resource(foaf + "Person"),
+		SubjPred(me, foaf+"nick").StringLiteral("js"),
+		SubjPred(me, foaf+"name").StringLiteral("jsmith"),
+		SubjPred(me, foaf+"age").IntegerLiteral(26),
+		SubjPred(me, foaf+"knows").Resource("http://ex.org/people/you"),
+		SubjPred(me, foaf+"knows").Bnode("friend"),
+		SubjPred(me, foaf+"based_near").Bnode("paris"),
+		BnodePred("paris", foaf+"name").StringLiteralWithLang("Paris", "fr"),
+		SubjPred(me, foaf+"interest").Resource("http://ex.org/topics/rdf"),
+		SubjPred("http://ex.org/topics/rdf", dc+"title").StringLiteral("RDF"),
+		SubjPred(me, foaf+"publications").Bnode("l1"),
+		BnodePred("l1", rdfFirst).Resource("http://ex.org/pub1"),
+		BnodePred("l1", rdfRest).Bnode("l2"),
+		BnodePred("l2", rdfFirst).Resource("http://ex.org/pub2"),
+		BnodePred("l2", rdfRest).Resource(rdfNil),
+		&triple{sub: me, pred: dc + "description", obj: object{isLit: true, lit: literal{typ: XsdType(rdfXMLLiteral), val: "<b>bold</b> text"}}},
+		BnodePred("friend", foaf+"title").StringLiteralWithLang("Mister", "en"),
+		BnodePred("friend", foaf+"account").Bnode("account"),
+		BnodePred("account", dc+"title").StringLiteralWithLang("main", "en"),
+		SubjPred("http://ex.org/other/doc#list", rdfType).Resource(rdfNamespace + "Seq"),
+		SubjPred("http://ex.org/other/doc#list", rdfNamespace+"_1").Resource("http://ex.org/other/a"),
+		SubjPred("http://ex.org/other/doc#list", rdfNamespace+"_2").Resource("http://ex.org/other/b"),
+	}
+	if !isomorphicTriples(tris, expected) {
+		t.Fatalf("got %v, want %v", tris, expected)
+	}
+
+	for _, invalid := range []string{
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description rdf:about="a" rdf:nodeID="b"/></rdf:RDF>`,
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://ex.org/"><rdf:Description>text<ex:p>o</ex:p></rdf:Description></rdf:RDF>`,
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description>`,
+	} {
+		if _, err := NewRDFXMLDecoder(strings.NewReader(invalid)).Decode(); err == nil {
+			t.Fatalf("document %s: expected error, got none", invalid)
+		}
+	}
+}
+
+func TestDecodeRDFXMLWithContext(t *testing.T) {
+	doc := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://ex.org/">
+  <rdf:Description rdf:about="me"><ex:knows rdf:resource="#you"/></rdf:Description>
+</rdf:RDF>`
+	tris, err := NewRDFXMLDecoderWithContext(strings.NewReader(doc), &Context{Base: "http://ex.org/people"}).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Triple{SubjPred("http://ex.org/me", "http://ex.org/knows").Resource("http://ex.org/people#you")}
+	if got, want := Triples(tris), Triples(expected); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeRDFXMLNodeIDs(t *testing.T) {
+	doc := `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://ex.org/">
+  <rdf:Description rdf:nodeID="genid1" ex:name="a"/>
+  <rdf:Description ex:name="b"/>
+  <rdf:Description rdf:about="http://ex.org/me"><ex:knows rdf:nodeID="genid1"/></rdf:Description>
+</rdf:RDF>`
+	tris, err := NewRDFXMLDecoder(strings.NewReader(doc)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Triple{
+		BnodePred("x", "http://ex.org/name").StringLiteral("a"),
+		BnodePred("y", "http://ex.org/name").StringLiteral("b"),
+		SubjPred("http://ex.org/me", "http://ex.org/knows").Bnode("x"),
+	}
+	if !isomorphicTriples(tris, expected) {
+		t.Fatalf("got %v, want %v", tris, expected)
+	}
+}
+
+func TestAutoDecodeRDFXML(t *testing.T) {
+	tcases := []struct {
+		in       string
+		expected []Triple
+	}{
+		{
+			in:       `<?xml version="1.0"?><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://ex.org/"><rdf:Description rdf:about="http://ex.org/a" ex:b="c"/></rdf:RDF>`,
+			expected: []Triple{SubjPred("http://ex.org/a", "http://ex.org/b").StringLiteral("c")},
+		},
+		{
+			in:       `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://ex.org/"><ex:T rdf:about="http://ex.org/a"/></rdf:RDF>`,
+			expected: []Triple{SubjPred("http://ex.org/a", rdfType).Resource("http://ex.org/T")},
+		},
+		{
+			in:       "<http://ex.org/a> <http://ex.org/b> <http://ex.org/c> .\n",
+			expected: []Triple{SubjPred("http://ex.org/a", "http://ex.org/b").Resource("http://ex.org/c")},
+		},
+	}
+
+	for i, tc := range tcases {
+		tris, err := NewAutoDecoder(bytes.NewBufferString(tc.in)).Decode()
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		if got, want := Triples(tris), Triples(tc.expected); !got.Equal(want) {
+			t.Fatalf("case %d: got %v, want %v", i, got, want)
+		}
+	}
+}
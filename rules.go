@@ -0,0 +1,196 @@
+package triplestore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Rule derives the triples of its head patterns for each solution
+// of its body patterns. Variables of the head must appear in the body.
+type Rule struct {
+	Name       string
+	Body, Head []TriplePattern
+}
+
+// A Derivation is a triple derived by a rule, with the name of the
+// rule and the triples matching its body that produced it
+type Derivation struct {
+	Triple   Triple
+	Rule     string
+	Premises []Triple
+}
+
+type Derivations []Derivation
+
+// ParseRules parses rules written as triple patterns, in the SPARQL syntax,
+// separated by dots, the body and head being separated by an arrow:
+//
+//	PREFIX ex: <http://example.org/>
+//	[inheritRole: ?x ex:memberOf ?g . ?g ex:hasRole ?r -> ?x ex:hasRole ?r]
+//
+// Prefixes and base of the optional context are available to the rules, as with ParseSparql.
+// Blank nodes in the body behave as variables and are not allowed in the head.
+func ParseRules(text string, c *Context) ([]Rule, error) {
+	toks, err := lexSparql(text)
+	if err != nil {
+		return nil, fmt.Errorf("rules: %s", err)
+	}
+	p := newSparqlParser(toks, c)
+	if err := p.parsePrologue(); err != nil {
+		return nil, fmt.Errorf("rules: %s", err)
+	}
+
+	var rules []Rule
+	for p.peek().kind != tokEOF {
+		r, err := p.parseRule()
+		if err != nil {
+			return nil, fmt.Errorf("rules: %s", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (p *sparqlParser) parseRule() (Rule, error) {
+	var r Rule
+	if err := p.expectPunct("["); err != nil {
+		return r, err
+	}
+	name := p.next()
+	if name.kind != tokPName || !strings.HasSuffix(name.val, ":") || len(name.val) == 1 {
+		return r, fmt.Errorf("expected rule name, got %s", name)
+	}
+	r.Name = strings.TrimSuffix(name.val, ":")
+
+	isArrow := func() bool {
+		return p.isPunct("-") && p.toks[p.pos+1].kind == tokPunct && p.toks[p.pos+1].val == ">"
+	}
+	parsePatterns := func(end func() bool) (patterns []TriplePattern, err error) {
+		for !end() {
+			if err = p.parseTriplesSameSubject(&patterns); err != nil {
+				return
+			}
+			if !p.acceptPunct(".") && !end() {
+				return nil, fmt.Errorf("expected '.', got %s", p.peek())
+			}
+		}
+		return
+	}
+
+	var err error
+	if r.Body, err = parsePatterns(isArrow); err != nil {
+		return r, err
+	}
+	for i, tp := range r.Body {
+		r.Body[i] = bnodesAsVariables(tp)
+	}
+	p.pos += 2
+	if r.Head, err = parsePatterns(func() bool { return p.isPunct("]") || p.peek().kind == tokEOF }); err != nil {
+		return r, err
+	}
+	if err := p.expectPunct("]"); err != nil {
+		return r, err
+	}
+	return r, r.validate()
+}
+
+func (r Rule) validate() error {
+	if len(r.Body) == 0 || len(r.Head) == 0 {
+		return fmt.Errorf("rule %s: empty body or head", r.Name)
+	}
+	vars := make(map[string]bool)
+	for _, tp := range r.Body {
+		for _, t := range tp.terms() {
+			if t.IsVar() {
+				vars[t.variable] = true
+			}
+		}
+	}
+	for _, tp := range r.Head {
+		for _, t := range tp.terms() {
+			if t.IsVar() && !vars[t.variable] {
+				return fmt.Errorf("rule %s: variable ?%s of head not in body", r.Name, t.variable)
+			}
+			if !t.IsVar() && t.value.isBnode {
+				return fmt.Errorf("rule %s: blank node in head", r.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyRules evaluates the rules over the graph until no new triple is
+// derived, and returns the derived triples not already in the graph. Each
+// triple is given with the first derivation found. Evaluation is semi-naive:
+// at each round, rules are only evaluated for solutions involving at least
+// one triple derived in the previous round.
+func ApplyRules(g RDFGraph, rules ...Rule) Derivations {
+	m := NewSource()
+	m.Add(g.Triples()...)
+
+	var derivations Derivations
+	delta := g.Triples()
+	for len(delta) > 0 {
+		full := m.Snapshot()
+		deltaSrc := NewSource()
+		deltaSrc.Add(delta...)
+		deltaGraph := deltaSrc.Snapshot()
+
+		seen := make(map[string]bool)
+		var next []Triple
+		for _, r := range rules {
+			for i := range r.Body {
+				seeds := evalBGP(deltaGraph, r.Body[i:i+1], []Binding{{}})
+				others := append(append([]TriplePattern(nil), r.Body[:i]...), r.Body[i+1:]...)
+				for _, b := range evalBGP(full, others, seeds) {
+					for _, tp := range r.Head {
+						t, ok := instantiate(tp, b)
+						if !ok || seen[t.key()] || full.Contains(t) {
+							continue
+						}
+						seen[t.key()] = true
+						next = append(next, t)
+						derivations = append(derivations, Derivation{Triple: t, Rule: r.Name, Premises: premises(r.Body, b)})
+					}
+				}
+			}
+		}
+		m.Add(next...)
+		delta = next
+	}
+	return derivations
+}
+
+// Triples returns the derived triples
+func (ds Derivations) Triples() []Triple {
+	out := make([]Triple, len(ds))
+	for i, d := range ds {
+		out[i] = d.Triple
+	}
+	return out
+}
+
+// instantiate returns the triple of the pattern with the variables bound,
+// false when the bound terms cannot form a triple
+func instantiate(tp TriplePattern, b Binding) (*triple, bool) {
+	value := func(t Term) object {
+		if t.IsVar() {
+			return b[t.variable].(object)
+		}
+		return t.value
+	}
+	s, p, o := value(tp.Subject), value(tp.Predicate), value(tp.Object)
+	if s.isLit || p.isLit || p.isBnode {
+		return nil, false
+	}
+	return &triple{sub: subjectString(s), isSubBnode: s.isBnode, pred: p.resource, obj: o}, true
+}
+
+func premises(body []TriplePattern, b Binding) (out []Triple) {
+	for _, tp := range body {
+		if t, ok := instantiate(tp, b); ok {
+			out = append(out, t)
+		}
+	}
+	return
+}
@@ -0,0 +1,100 @@
+package triplestore_test
+
+import (
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestParseAndApplyRules(t *testing.T) {
+	parsed, err := tstore.ParseRules(`
+		PREFIX ex: <http://example.org/>
+		# roles are inherited from groups, recursively
+		[inheritRole: ?x ex:memberOf ?g . ?g ex:hasRole ?r -> ?x ex:hasRole ?r]
+		[nestedGroup: ?x ex:memberOf ?g . ?g ex:memberOf ?h -> ?x ex:memberOf ?h]
+		[admin: ?x ex:hasRole "admin" -> ?x a ex:Admin ; ex:level 1]
+	`, tstore.RDFContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(parsed), 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	ex := func(s string) string { return "http://example.org/" + s }
+	src := tstore.NewSource()
+	src.Add(
+		tstore.SubjPredRes("me", ex("memberOf"), "devs"),
+		tstore.SubjPredRes("devs", ex("memberOf"), "staff"),
+		tstore.SubjPred("staff", ex("hasRole")).StringLiteral("admin"),
+	)
+
+	derivations := tstore.ApplyRules(src.Snapshot(), parsed...)
+
+	if got, want := len(derivations), 9; got != want {
+		t.Fatalf("got %d, want %d: %v", got, want, tstore.Triples(derivations.Triples()))
+	}
+	ruleOf := func(tri tstore.Triple) string {
+		for _, d := range derivations {
+			if d.Triple.Equal(tri) {
+				return d.Rule
+			}
+		}
+		return ""
+	}
+	expected := map[string]tstore.Triple{
+		"nestedGroup": tstore.SubjPredRes("me", ex("memberOf"), "staff"),
+		"admin":       tstore.SubjPredRes("me", "http://www.w3.org/1999/02/22-rdf-syntax-ns#type", ex("Admin")),
+	}
+	for rule, tri := range expected {
+		if got, want := ruleOf(tri), rule; got != want {
+			t.Fatalf("%v: got %s, want %s", tri, got, want)
+		}
+	}
+
+	all := tstore.NewSource()
+	all.Add(derivations.Triples()...)
+	g := all.Snapshot()
+	for _, tri := range []tstore.Triple{
+		tstore.SubjPredRes("me", ex("memberOf"), "staff"),
+		tstore.SubjPred("devs", ex("hasRole")).StringLiteral("admin"),
+		tstore.SubjPred("me", ex("hasRole")).StringLiteral("admin"),
+		tstore.SubjPredRes("staff", "http://www.w3.org/1999/02/22-rdf-syntax-ns#type", ex("Admin")),
+		tstore.SubjPredRes("devs", "http://www.w3.org/1999/02/22-rdf-syntax-ns#type", ex("Admin")),
+		tstore.SubjPredRes("me", "http://www.w3.org/1999/02/22-rdf-syntax-ns#type", ex("Admin")),
+		tstore.SubjPred("me", ex("level")).IntegerLiteral(1),
+	} {
+		if !g.Contains(tri) {
+			t.Fatalf("expected %v to be derived", tri)
+		}
+	}
+
+	for _, d := range derivations {
+		if d.Triple.Equal(tstore.SubjPred("devs", ex("hasRole")).StringLiteral("admin")) {
+			if got, want := d.Rule, "inheritRole"; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+			premises := tstore.Triples{
+				tstore.SubjPredRes("devs", ex("memberOf"), "staff"),
+				tstore.SubjPred("staff", ex("hasRole")).StringLiteral("admin"),
+			}
+			if got, want := tstore.Triples(d.Premises), premises; !got.Equal(want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, text := range []string{
+		`[noArrow: ?x <p> ?y]`,
+		`[unbound: ?x <p> ?y -> ?x <q> ?z]`,
+		`[bnode: ?x <p> ?y -> _:b <q> ?y]`,
+		`[?x <p> ?y -> ?x <q> ?y]`,
+		`[unclosed: ?x <p> ?y -> ?x <q> ?y`,
+	} {
+		if _, err := tstore.ParseRules(text, nil); err == nil {
+			t.Fatalf("%s: expected error", text)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package triplestore
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A Schema describes the structure of the instances of each class of a graph
+type Schema struct {
+	Classes []ClassSchema
+}
+
+// A ClassSchema describes the predicates used by the instances of a class
+type ClassSchema struct {
+	Class      string
+	Instances  int
+	Properties []PropertySchema
+}
+
+// A PropertySchema describes the objects of a predicate for the instances of a class.
+// MinCount and MaxCount are the minimum and maximum number of objects per instance,
+// and Resources, Bnodes and Literals the number of objects of each kind.
+type PropertySchema struct {
+	Predicate                   string
+	MinCount, MaxCount          int
+	Resources, Bnodes, Literals int
+	// number of literals per datatype, rdf:langString for literals with a language tag
+	Datatypes map[XsdType]int
+}
+
+// InferSchema scans the instances of each class of the graph, that is the
+// subjects of rdf:type triples, and describes the predicates they use.
+// Both rdf:type and its full IRI are recognised. Classes and predicates are sorted.
+func InferSchema(g RDFGraph) Schema {
+	types := vocabularyForms(RDFContext, rdfNamespace, "type")
+
+	instances := make(map[string]map[string]*triple)
+	for _, typ := range types {
+		for _, t := range g.WithPredicate(typ) {
+			tt := t.(*triple)
+			class, ok := tt.obj.iri()
+			if !ok {
+				continue
+			}
+			if instances[class] == nil {
+				instances[class] = make(map[string]*triple)
+			}
+			instances[class][subjectObject(tt).key()] = tt
+		}
+	}
+
+	var schema Schema
+	for class, subjects := range instances {
+		cs := ClassSchema{Class: class, Instances: len(subjects)}
+		props := make(map[string]*PropertySchema)
+		counts := make(map[string][]int)
+		for _, instance := range subjects {
+			perPred := make(map[string]int)
+			for _, t := range withSubject(g, instance.sub, instance.isSubBnode) {
+				tt := t.(*triple)
+				if isOneOf(types, tt.pred) {
+					continue
+				}
+				perPred[tt.pred]++
+				ps, ok := props[tt.pred]
+				if !ok {
+					ps = &PropertySchema{Predicate: tt.pred, Datatypes: make(map[XsdType]int)}
+					props[tt.pred] = ps
+				}
+				switch {
+				case tt.obj.isLit:
+					ps.Literals++
+					ps.Datatypes[literalDatatype(tt.obj.lit)]++
+				case tt.obj.isBnode:
+					ps.Bnodes++
+				default:
+					ps.Resources++
+				}
+			}
+			for pred, n := range perPred {
+				counts[pred] = append(counts[pred], n)
+			}
+		}
+
+		for pred, ps := range props {
+			ps.MinCount, ps.MaxCount = counts[pred][0], counts[pred][0]
+			for _, n := range counts[pred] {
+				if n < ps.MinCount {
+					ps.MinCount = n
+				}
+				if n > ps.MaxCount {
+					ps.MaxCount = n
+				}
+			}
+			if len(counts[pred]) < cs.Instances {
+				ps.MinCount = 0
+			}
+			cs.Properties = append(cs.Properties, *ps)
+		}
+		sort.Slice(cs.Properties, func(i, j int) bool { return cs.Properties[i].Predicate < cs.Properties[j].Predicate })
+		schema.Classes = append(schema.Classes, cs)
+	}
+	sort.Slice(schema.Classes, func(i, j int) bool { return schema.Classes[i].Class < schema.Classes[j].Class })
+	return schema
+}
+
+// Shapes describes the schema as SHACL shapes: a node shape targeting each
+// class, named after the class with a Shape suffix, and a property shape per
+// predicate with the observed cardinalities, node kinds and datatype when unique.
+func (s Schema) Shapes() []Triple {
+	var tris []Triple
+	for c, cs := range s.Classes {
+		node := cs.Class + "Shape"
+		tris = append(tris,
+			SubjPred(node, rdfType).Resource(shaclNamespace+"NodeShape"),
+			SubjPred(node, shaclNamespace+"targetClass").Resource(cs.Class),
+		)
+		for i, ps := range cs.Properties {
+			prop := fmt.Sprintf("property%d_%d", c, i)
+			tris = append(tris,
+				SubjPred(node, shaclNamespace+"property").Bnode(prop),
+				BnodePred(prop, shaclNamespace+"path").Resource(ps.Predicate),
+				BnodePred(prop, shaclNamespace+"maxCount").IntegerLiteral(ps.MaxCount),
+			)
+			if kind := ps.nodeKind(); kind != "" {
+				tris = append(tris, BnodePred(prop, shaclNamespace+"nodeKind").Resource(shaclNamespace+kind))
+			}
+			if ps.MinCount > 0 {
+				tris = append(tris, BnodePred(prop, shaclNamespace+"minCount").IntegerLiteral(ps.MinCount))
+			}
+			if dt, ok := ps.datatype(); ok && ps.Resources == 0 && ps.Bnodes == 0 {
+				tris = append(tris, BnodePred(prop, shaclNamespace+"datatype").Resource(datatypeIRI(dt)))
+			}
+		}
+	}
+	return tris
+}
+
+// nodeKind returns the SHACL node kind of the objects, empty when of all kinds
+func (ps PropertySchema) nodeKind() string {
+	switch {
+	case ps.Literals == 0 && ps.Bnodes == 0:
+		return "IRI"
+	case ps.Literals == 0 && ps.Resources == 0:
+		return "BlankNode"
+	case ps.Resources == 0 && ps.Bnodes == 0:
+		return "Literal"
+	case ps.Literals == 0:
+		return "BlankNodeOrIRI"
+	case ps.Resources == 0:
+		return "BlankNodeOrLiteral"
+	case ps.Bnodes == 0:
+		return "IRIOrLiteral"
+	}
+	return ""
+}
+
+func (ps PropertySchema) objectKinds() string {
+	var kinds []string
+	if ps.Resources > 0 {
+		kinds = append(kinds, "resources")
+	}
+	if ps.Bnodes > 0 {
+		kinds = append(kinds, "blank nodes")
+	}
+	if ps.Literals > 0 {
+		kinds = append(kinds, "literals")
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// datatype returns the datatype of the literals, false when there are several
+func (ps PropertySchema) datatype() (XsdType, bool) {
+	if len(ps.Datatypes) != 1 {
+		return "", false
+	}
+	for dt := range ps.Datatypes {
+		return dt, true
+	}
+	return "", false
+}
+
+func datatypeIRI(dt XsdType) string {
+	switch {
+	case strings.HasPrefix(string(dt), "xsd:"):
+		return dt.NTriplesNamespaced()
+	case strings.HasPrefix(string(dt), "rdf:"):
+		return rdfNamespace + strings.TrimPrefix(string(dt), "rdf:")
+	}
+	return string(dt)
+}
+
+// GoStructs returns the source of Go struct definitions, one per class, with
+// predicate tags to be used with TriplesFromStruct. Predicates with several
+// objects per instance are slices, predicates with several datatypes are
+// interface{}. As TriplesFromStruct only creates literals, predicates with
+// resources or blank nodes as objects are given as comments, after the fields.
+func (s Schema) GoStructs(pkg string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+
+	imports := false
+	for _, cs := range s.Classes {
+		for _, ps := range cs.Properties {
+			if dt, ok := ps.datatype(); ok && dt == XsdDateTime && ps.Resources == 0 && ps.Bnodes == 0 {
+				imports = true
+			}
+		}
+	}
+	if imports {
+		buf.WriteString("import \"time\"\n\n")
+	}
+
+	structs := make(map[string]int)
+	for _, cs := range s.Classes {
+		name := uniqueIdentifier(localName(cs.Class), structs)
+		fmt.Fprintf(&buf, "// %s is an instance of <%s>\ntype %s struct {\n", name, cs.Class, name)
+		fields := make(map[string]int)
+		var unsupported []string
+		for _, ps := range cs.Properties {
+			field := uniqueIdentifier(localName(ps.Predicate), fields)
+			typ := "interface{}"
+			if dt, ok := ps.datatype(); ok {
+				typ = goTypeOf(dt)
+			}
+			if ps.MaxCount > 1 {
+				typ = "[]" + typ
+			}
+			line := fmt.Sprintf("%s %s `predicate:%q`", field, typ, ps.Predicate)
+			if ps.Resources > 0 || ps.Bnodes > 0 {
+				unsupported = append(unsupported, fmt.Sprintf("// %s (objects are %s)\n", line, ps.objectKinds()))
+				continue
+			}
+			buf.WriteString(line + "\n")
+		}
+		if len(unsupported) > 0 {
+			buf.WriteString("\n" + strings.Join(unsupported, ""))
+		}
+		buf.WriteString("}\n\n")
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return buf.String()
+	}
+	return string(src)
+}
+
+// goTypeOf returns the Go type converted to the given datatype by ObjectLiteral
+func goTypeOf(dt XsdType) string {
+	switch dt {
+	case XsdBoolean:
+		return "bool"
+	case XsdDateTime:
+		return "time.Time"
+	case XsdDouble, XsdDecimal:
+		return "float64"
+	case XsdFloat:
+		return "float32"
+	case XsdInteger:
+		return "int"
+	case XsdByte:
+		return "int8"
+	case XsdShort:
+		return "int16"
+	case XsdUinteger:
+		return "uint"
+	case XsdUnsignedByte:
+		return "uint8"
+	case XsdUnsignedShort:
+		return "uint16"
+	case XsdString, rdfLangString:
+		return "string"
+	}
+	return "interface{}"
+}
+
+// localName returns the last segment of the IRI
+func localName(iri string) string {
+	if i := strings.LastIndexAny(iri, "#/:"); i >= 0 && i < len(iri)-1 {
+		return iri[i+1:]
+	}
+	return iri
+}
+
+// uniqueIdentifier returns an exported Go identifier from the name,
+// suffixed with a number when already used
+func uniqueIdentifier(name string, used map[string]int) string {
+	var ident []rune
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		ident = append(ident, r)
+	}
+	if len(ident) == 0 || !unicode.IsLetter(ident[0]) {
+		ident = append([]rune("X"), ident...)
+	}
+
+	id := string(ident)
+	used[id]++
+	if n := used[id]; n > 1 {
+		id = fmt.Sprintf("%s%d", id, n)
+	}
+	return id
+}
@@ -0,0 +1,97 @@
+package triplestore_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestInferSchema(t *testing.T) {
+	src := tstore.NewSource()
+	src.Add(
+		tstore.SubjPredRes("alice", "rdf:type", "http://example.org/Person"),
+		tstore.SubjPred("alice", "http://example.org/name").StringLiteral("Alice"),
+		tstore.SubjPred("alice", "http://example.org/age").IntegerLiteral(30),
+		tstore.SubjPred("alice", "http://example.org/nick").StringLiteral("al"),
+		tstore.SubjPred("alice", "http://example.org/nick").StringLiteralWithLang("ali", "fr"),
+		tstore.SubjPredRes("alice", "http://example.org/knows", "bob"),
+		tstore.SubjPredRes("bob", "http://www.w3.org/1999/02/22-rdf-syntax-ns#type", "http://example.org/Person"),
+		tstore.SubjPred("bob", "http://example.org/name").StringLiteral("Bob"),
+		tstore.SubjPred("bob", "http://example.org/birth").DateTimeLiteral(time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)),
+		tstore.SubjPredBnode("bob", "http://example.org/knows", "someone"),
+		tstore.BnodePredRes("someone", "rdf:type", "http://example.org/Thing"),
+	)
+	g := src.Snapshot()
+
+	schema := tstore.InferSchema(g)
+	if got, want := len(schema.Classes), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	person := schema.Classes[0]
+	if got, want := person.Class, "http://example.org/Person"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := person.Instances, 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	var preds []string
+	props := make(map[string]tstore.PropertySchema)
+	for _, ps := range person.Properties {
+		preds = append(preds, strings.TrimPrefix(ps.Predicate, "http://example.org/"))
+		props[ps.Predicate] = ps
+	}
+	if got, want := strings.Join(preds, ","), "age,birth,knows,name,nick"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	name := props["http://example.org/name"]
+	if name.MinCount != 1 || name.MaxCount != 1 || name.Literals != 2 || name.Datatypes[tstore.XsdString] != 2 {
+		t.Fatalf("unexpected name property %+v", name)
+	}
+	nick := props["http://example.org/nick"]
+	if nick.MinCount != 0 || nick.MaxCount != 2 || len(nick.Datatypes) != 2 {
+		t.Fatalf("unexpected nick property %+v", nick)
+	}
+	knows := props["http://example.org/knows"]
+	if knows.MinCount != 1 || knows.MaxCount != 1 || knows.Resources != 1 || knows.Bnodes != 1 {
+		t.Fatalf("unexpected knows property %+v", knows)
+	}
+
+	shapesSrc := tstore.NewSource()
+	shapesSrc.Add(schema.Shapes()...)
+	shapes, err := tstore.NewShapes(shapesSrc.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := shapes.Validate(g); !report.Conforms {
+		t.Fatalf("expected graph to conform to its inferred shapes, got %+v", report.Results)
+	}
+	src.Add(tstore.SubjPred("bob", "http://example.org/age").StringLiteral("unknown"))
+	if report := shapes.Validate(src.Snapshot()); report.Conforms {
+		t.Fatal("expected graph not to conform to its inferred shapes")
+	}
+
+	expected := `package model
+
+import "time"
+
+// Person is an instance of <http://example.org/Person>
+type Person struct {
+	Age   int           ` + "`" + `predicate:"http://example.org/age"` + "`" + `
+	Birth time.Time     ` + "`" + `predicate:"http://example.org/birth"` + "`" + `
+	Name  string        ` + "`" + `predicate:"http://example.org/name"` + "`" + `
+	Nick  []interface{} ` + "`" + `predicate:"http://example.org/nick"` + "`" + `
+
+	// Knows interface{} ` + "`" + `predicate:"http://example.org/knows"` + "`" + ` (objects are resources, blank nodes)
+}
+
+// Thing is an instance of <http://example.org/Thing>
+type Thing struct {
+}
+`
+	if got, want := schema.GoStructs("model"), expected; got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,29 @@
+package triplestore
+
+// A TripleSeq iterates over triples, calling yield on each of them until it
+// returns false. It has the shape of iter.Seq[Triple], so that it can also be
+// ranged over with Go 1.23 and later.
+type TripleSeq func(yield func(Triple) bool)
+
+// Collect returns the triples of the sequence
+func (seq TripleSeq) Collect() (out []Triple) {
+	seq(func(t Triple) bool {
+		out = append(out, t)
+		return true
+	})
+	return
+}
+
+// Count returns the number of triples of the sequence
+func (seq TripleSeq) Count() (count int) {
+	seq(func(Triple) bool {
+		count++
+		return true
+	})
+	return
+}
+
+// All iterates over all the triples of the graph
+func All(g RDFGraph) TripleSeq {
+	return g.Scan(nil, nil, nil)
+}
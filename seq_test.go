@@ -0,0 +1,91 @@
+package triplestore_test
+
+import (
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestScanGraph(t *testing.T) {
+	one := tstore.SubjPred("me", "age").IntegerLiteral(26)
+	two := tstore.SubjPred("me", "name").StringLiteral("jsmith")
+	three := tstore.SubjPred("you", "name").StringLiteral("jdoe")
+	four := tstore.BnodePred("me", "name").StringLiteral("jsmith")
+
+	src := tstore.NewSource()
+	src.Add(one, two, three, four)
+	snap := src.Snapshot()
+	other := tstore.NewSource()
+	other.Add(three)
+
+	str := func(s string) *string { return &s }
+	graphs := []tstore.RDFGraph{
+		snap,
+		tstore.Union(other.Snapshot(), snap),
+		tstore.Filter(snap, func(tstore.Triple) bool { return true }),
+	}
+	for i, g := range graphs {
+		if got, want := tstore.Triples(tstore.All(g).Collect()), tstore.Triples(g.Triples()); !got.Equal(want) {
+			t.Fatalf("graph %d: got %v, want %v", i, got, want)
+		}
+		if got, want := tstore.All(g).Count(), 4; got != want {
+			t.Fatalf("graph %d: got %d, want %d", i, got, want)
+		}
+		if got, want := tstore.Triples(g.Scan(str("me"), str("name"), nil).Collect()), (tstore.Triples{two, four}); !got.Equal(want) {
+			t.Fatalf("graph %d: got %v, want %v", i, got, want)
+		}
+		if got, want := tstore.Triples(g.Scan(str("me"), str("name"), tstore.StringLiteral("jsmith")).Collect()), (tstore.Triples{two, four}); !got.Equal(want) {
+			t.Fatalf("graph %d: got %v, want %v", i, got, want)
+		}
+		if got, want := tstore.Triples(g.Scan(nil, nil, tstore.StringLiteral("jdoe")).Collect()), (tstore.Triples{three}); !got.Equal(want) {
+			t.Fatalf("graph %d: got %v, want %v", i, got, want)
+		}
+		if got := g.Scan(str("unknown"), nil, nil).Collect(); len(got) != 0 {
+			t.Fatalf("graph %d: expected no triple, got %v", i, got)
+		}
+
+		var count int
+		tstore.All(g)(func(tstore.Triple) bool {
+			count++
+			return count < 2
+		})
+		if got, want := count, 2; got != want {
+			t.Fatalf("graph %d: early termination: got %d, want %d", i, got, want)
+		}
+
+		tris := g.Triples()
+		tris[0] = nil
+		for _, tri := range g.Triples() {
+			if tri == nil {
+				t.Fatalf("graph %d: triples of the graph modified by caller", i)
+			}
+		}
+	}
+}
+
+func TestScanKeepsTriples(t *testing.T) {
+	src := tstore.NewSource()
+	for i := 0; i < 1000; i++ {
+		src.Add(tstore.SubjPred("me", "digit").IntegerLiteral(i))
+	}
+	snap := src.Snapshot()
+
+	var kept []tstore.Triple
+	tstore.All(snap)(func(t tstore.Triple) bool {
+		kept = append(kept, t)
+		return true
+	})
+	if got, want := tstore.Triples(kept), tstore.Triples(snap.Triples()); !got.Equal(want) {
+		t.Fatalf("expected kept triples to be left unchanged by the scan, got %v", got)
+	}
+
+	// one triple each, and a slice per chunk of 64
+	allocs := testing.AllocsPerRun(10, func() {
+		tstore.All(snap)(func(t tstore.Triple) bool {
+			return t.Subject() == "me"
+		})
+	})
+	if allocs > 1000+20 {
+		t.Fatalf("got %v allocations to scan 1000 triples, want at most %d", allocs, 1000+20)
+	}
+}
@@ -0,0 +1,581 @@
+package triplestore
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const shaclNamespace = "http://www.w3.org/ns/shacl#"
+
+// Shapes are SHACL shapes loaded from a shapes graph, to validate data graphs.
+//
+// The following SHACL Core features are supported: the sh:targetClass,
+// sh:targetNode, sh:targetSubjectsOf and sh:targetObjectsOf targets, property
+// shapes (sh:property) with predicate or inverse paths, and the sh:minCount,
+// sh:maxCount, sh:datatype, sh:class, sh:nodeKind, sh:pattern, sh:in and
+// sh:hasValue constraints. Shapes can be deactivated and given a severity
+// and a message.
+//
+// RDF and RDFS terms are recognised both as full IRIs and as compact IRIs
+// with the prefixes of RDFContext (ex: rdf:type), in shapes and data graphs.
+type Shapes struct {
+	shapes              []*shape
+	types, subClasses   []string
+	firsts, rests, nils []string
+	langString          []string
+}
+
+type shapePath struct {
+	pred    string
+	inverse bool
+}
+
+type shape struct {
+	node       object
+	path       *shapePath
+	properties []*shape
+
+	targetClasses, targetNodes        []object
+	targetSubjectsOf, targetObjectsOf []string
+
+	minCount, maxCount int
+	datatypes          []string
+	classes            []object
+	nodeKinds          []string
+	patterns           []*regexp.Regexp
+	in                 [][]object
+	hasValues          []object
+
+	severity, message string
+}
+
+// A ValidationReport gives the results of the validation of a data graph.
+// The data graph conforms to the shapes when there is no result.
+type ValidationReport struct {
+	Conforms bool
+	Results  []ValidationResult
+}
+
+// A ValidationResult describes a value not satisfying a constraint of a shape.
+// Path is empty for node shapes, and Value is nil when the constraint is
+// not about a specific value (ex: sh:minCount).
+type ValidationResult struct {
+	FocusNode   Object
+	Path        string
+	InversePath bool
+	Value       Object
+	SourceShape Object
+	// IRI of the constraint component (ex: http://www.w3.org/ns/shacl#MinCountConstraintComponent)
+	Constraint string
+	// IRI of the severity (ex: http://www.w3.org/ns/shacl#Violation)
+	Severity string
+	Message  string
+}
+
+// NewShapes loads the shapes of the given shapes graph. Shapes are the
+// instances of sh:NodeShape, the subjects of targets and the values of sh:property.
+// It returns an error on malformed or unsupported constraints.
+func NewShapes(g RDFGraph) (*Shapes, error) {
+	s := &Shapes{
+		types:      vocabularyForms(RDFContext, rdfNamespace, "type"),
+		subClasses: vocabularyForms(RDFContext, rdfsNamespace, "subClassOf"),
+		firsts:     vocabularyForms(RDFContext, rdfNamespace, "first"),
+		rests:      vocabularyForms(RDFContext, rdfNamespace, "rest"),
+		nils:       vocabularyForms(RDFContext, rdfNamespace, "nil"),
+		langString: vocabularyForms(RDFContext, rdfNamespace, "langString"),
+	}
+
+	roots := make(map[string]object)
+	for _, typ := range s.types {
+		for _, t := range g.WithPredObj(typ, Resource(shaclNamespace+"NodeShape")) {
+			node := subjectObject(t.(*triple))
+			roots[node.key()] = node
+		}
+	}
+	for _, target := range []string{"targetClass", "targetNode", "targetSubjectsOf", "targetObjectsOf"} {
+		for _, t := range g.WithPredicate(shaclNamespace + target) {
+			node := subjectObject(t.(*triple))
+			roots[node.key()] = node
+		}
+	}
+
+	var keys []string
+	for k := range roots {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	loaded := make(map[string]*shape)
+	for _, k := range keys {
+		sh, err := s.loadShape(g, roots[k], loaded)
+		if err != nil {
+			return nil, err
+		}
+		if sh != nil {
+			s.shapes = append(s.shapes, sh)
+		}
+	}
+	return s, nil
+}
+
+// loadShape loads the shape described by the node, nil when deactivated.
+// Shapes already loaded are reused, so that shapes may refer to each other.
+func (s *Shapes) loadShape(g RDFGraph, node object, loaded map[string]*shape) (*shape, error) {
+	if sh, ok := loaded[node.key()]; ok {
+		return sh, nil
+	}
+	values := func(local string) (out []object) {
+		sub, isBnode := objectSubject(node)
+		for _, t := range withSubjPred(g, sub, isBnode, shaclNamespace+local) {
+			out = append(out, t.obj)
+		}
+		return
+	}
+	resources := func(local string) (out []string, err error) {
+		for _, o := range values(local) {
+			res, ok := o.iri()
+			if !ok {
+				return nil, fmt.Errorf("shacl: shape %s: sh:%s expects an IRI", node.key(), local)
+			}
+			out = append(out, res)
+		}
+		return
+	}
+	integer := func(local string) (int, error) {
+		vals := values(local)
+		if len(vals) == 0 {
+			return -1, nil
+		}
+		n, err := strconv.Atoi(vals[0].lit.val)
+		if !vals[0].isLit || err != nil || n < 0 {
+			return 0, fmt.Errorf("shacl: shape %s: sh:%s expects a non negative integer", node.key(), local)
+		}
+		return n, nil
+	}
+
+	for _, deactivated := range values("deactivated") {
+		if deactivated.isLit && deactivated.lit.val == "true" {
+			loaded[node.key()] = nil
+			return nil, nil
+		}
+	}
+
+	sh := &shape{
+		node:          node,
+		targetClasses: values("targetClass"),
+		targetNodes:   values("targetNode"),
+		classes:       values("class"),
+		hasValues:     values("hasValue"),
+		severity:      shaclNamespace + "Violation",
+		minCount:      -1,
+		maxCount:      -1,
+	}
+	loaded[node.key()] = sh
+	var err error
+	if sh.targetSubjectsOf, err = resources("targetSubjectsOf"); err != nil {
+		return nil, err
+	}
+	if sh.targetObjectsOf, err = resources("targetObjectsOf"); err != nil {
+		return nil, err
+	}
+	if sh.datatypes, err = resources("datatype"); err != nil {
+		return nil, err
+	}
+	if sh.nodeKinds, err = resources("nodeKind"); err != nil {
+		return nil, err
+	}
+	if sh.minCount, err = integer("minCount"); err != nil {
+		return nil, err
+	}
+	if sh.maxCount, err = integer("maxCount"); err != nil {
+		return nil, err
+	}
+	if severities, err := resources("severity"); err != nil {
+		return nil, err
+	} else if len(severities) > 0 {
+		sh.severity = severities[0]
+	}
+	if messages := values("message"); len(messages) > 0 {
+		sh.message = messages[0].lit.val
+	}
+
+	// only the flags also supported by Go regular expressions are kept
+	var flags string
+	if f := values("flags"); len(f) > 0 {
+		for _, c := range f[0].lit.val {
+			if strings.ContainsRune("ims", c) {
+				flags += string(c)
+			}
+		}
+	}
+	for _, p := range values("pattern") {
+		if !p.isLit {
+			return nil, fmt.Errorf("shacl: shape %s: sh:pattern expects a literal", node.key())
+		}
+		expr := p.lit.val
+		if flags != "" {
+			expr = "(?" + flags + ")" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("shacl: shape %s: %s", node.key(), err)
+		}
+		sh.patterns = append(sh.patterns, re)
+	}
+
+	for _, list := range values("in") {
+		members, err := s.list(g, list)
+		if err != nil {
+			return nil, fmt.Errorf("shacl: shape %s: sh:in: %s", node.key(), err)
+		}
+		sh.in = append(sh.in, members)
+	}
+
+	if paths := values("path"); len(paths) > 0 {
+		if sh.path, err = s.loadPath(g, paths[0]); err != nil {
+			return nil, fmt.Errorf("shacl: shape %s: %s", node.key(), err)
+		}
+	}
+
+	for _, prop := range values("property") {
+		if prop.isLit {
+			return nil, fmt.Errorf("shacl: shape %s: sh:property expects a shape", node.key())
+		}
+		propShape, err := s.loadShape(g, prop, loaded)
+		if err != nil {
+			return nil, err
+		}
+		if propShape == nil {
+			continue
+		}
+		if propShape.path == nil {
+			return nil, fmt.Errorf("shacl: property shape %s: missing sh:path", prop.key())
+		}
+		sh.properties = append(sh.properties, propShape)
+	}
+	return sh, nil
+}
+
+func (s *Shapes) loadPath(g RDFGraph, path object) (*shapePath, error) {
+	if res, ok := path.iri(); ok {
+		return &shapePath{pred: res}, nil
+	}
+	if path.isBnode {
+		for _, t := range withSubjPred(g, path.bnode, true, shaclNamespace+"inversePath") {
+			if res, ok := t.obj.iri(); ok {
+				return &shapePath{pred: res, inverse: true}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unsupported sh:path %s: only predicate and inverse paths are supported", path.key())
+}
+
+// list returns the members of the RDF list starting at the given node
+func (s *Shapes) list(g RDFGraph, node object) (members []object, err error) {
+	visited := make(map[string]bool)
+	for {
+		if res, ok := node.iri(); ok && isOneOf(s.nils, res) {
+			return
+		}
+		if node.isLit || visited[node.key()] {
+			return nil, fmt.Errorf("malformed list")
+		}
+		visited[node.key()] = true
+
+		sub, isBnode := objectSubject(node)
+		var first, rest []*triple
+		for _, f := range s.firsts {
+			first = append(first, withSubjPred(g, sub, isBnode, f)...)
+		}
+		for _, r := range s.rests {
+			rest = append(rest, withSubjPred(g, sub, isBnode, r)...)
+		}
+		if len(first) != 1 || len(rest) != 1 {
+			return nil, fmt.Errorf("malformed list")
+		}
+		members = append(members, first[0].obj)
+		node = rest[0].obj
+	}
+}
+
+// Validate validates the data graph against the shapes
+func (s *Shapes) Validate(data RDFGraph) ValidationReport {
+	v := &validation{shapes: s, data: data, active: make(map[shapeFocus]bool)}
+	for _, sh := range s.shapes {
+		for _, focus := range v.focusNodes(sh) {
+			v.validate(sh, focus)
+		}
+	}
+	return ValidationReport{Conforms: len(v.results) == 0, Results: v.results}
+}
+
+type validation struct {
+	shapes  *Shapes
+	data    RDFGraph
+	results []ValidationResult
+	// shapes being validated for a focus node, not to validate them again
+	// when shapes refer to each other
+	active map[shapeFocus]bool
+}
+
+type shapeFocus struct {
+	shape *shape
+	focus string
+}
+
+func (v *validation) focusNodes(sh *shape) []object {
+	nodes := make(map[string]object)
+	for _, n := range sh.targetNodes {
+		nodes[n.key()] = n
+	}
+	for _, class := range sh.targetClasses {
+		for _, c := range v.subClassesOf(class) {
+			for _, typ := range v.shapes.types {
+				for _, t := range v.data.WithPredObj(typ, c) {
+					n := subjectObject(t.(*triple))
+					nodes[n.key()] = n
+				}
+			}
+		}
+	}
+	for _, pred := range sh.targetSubjectsOf {
+		for _, t := range v.data.WithPredicate(pred) {
+			n := subjectObject(t.(*triple))
+			nodes[n.key()] = n
+		}
+	}
+	for _, pred := range sh.targetObjectsOf {
+		for _, t := range v.data.WithPredicate(pred) {
+			n := t.(*triple).obj
+			nodes[n.key()] = n
+		}
+	}
+
+	keys := make([]string, 0, len(nodes))
+	for k := range nodes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]object, len(keys))
+	for i, k := range keys {
+		out[i] = nodes[k]
+	}
+	return out
+}
+
+// subClassesOf returns the class and all its subclasses in the data graph
+func (v *validation) subClassesOf(class object) (out []object) {
+	visited := map[string]bool{class.key(): true}
+	queue := []object{class}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		out = append(out, c)
+		for _, sc := range v.shapes.subClasses {
+			for _, t := range v.data.WithPredObj(sc, c) {
+				sub := subjectObject(t.(*triple))
+				if !visited[sub.key()] {
+					visited[sub.key()] = true
+					queue = append(queue, sub)
+				}
+			}
+		}
+	}
+	return
+}
+
+func (v *validation) isInstanceOf(node object, class object) bool {
+	if node.isLit {
+		return false
+	}
+	sub, isBnode := objectSubject(node)
+	for _, c := range v.subClassesOf(class) {
+		for _, typ := range v.shapes.types {
+			for _, t := range withSubjPred(v.data, sub, isBnode, typ) {
+				if t.obj.key() == c.key() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (v *validation) valueNodes(focus object, path *shapePath) (out []object) {
+	if path.inverse {
+		for _, t := range v.data.WithPredObj(path.pred, focus) {
+			out = append(out, subjectObject(t.(*triple)))
+		}
+		return
+	}
+	if focus.isLit {
+		return
+	}
+	sub, isBnode := objectSubject(focus)
+	for _, t := range withSubjPred(v.data, sub, isBnode, path.pred) {
+		out = append(out, t.obj)
+	}
+	return
+}
+
+func (v *validation) validate(sh *shape, focus object) {
+	active := shapeFocus{shape: sh, focus: focus.key()}
+	if v.active[active] {
+		return
+	}
+	v.active[active] = true
+	defer delete(v.active, active)
+
+	values := []object{focus}
+	if sh.path != nil {
+		values = v.valueNodes(focus, sh.path)
+	}
+
+	report := func(component string, value *object) {
+		res := ValidationResult{
+			FocusNode:   focus,
+			SourceShape: sh.node,
+			Constraint:  shaclNamespace + component + "ConstraintComponent",
+			Severity:    sh.severity,
+			Message:     sh.message,
+		}
+		if sh.path != nil {
+			res.Path, res.InversePath = sh.path.pred, sh.path.inverse
+		}
+		if value != nil {
+			res.Value = *value
+		}
+		v.results = append(v.results, res)
+	}
+
+	if sh.minCount >= 0 && len(values) < sh.minCount {
+		report("MinCount", nil)
+	}
+	if sh.maxCount >= 0 && len(values) > sh.maxCount {
+		report("MaxCount", nil)
+	}
+	for _, hasValue := range sh.hasValues {
+		if !containsObject(values, hasValue) {
+			report("HasValue", nil)
+		}
+	}
+
+	for i := range values {
+		value := values[i]
+		for _, dt := range sh.datatypes {
+			if !v.hasDatatype(value, dt) {
+				report("Datatype", &value)
+			}
+		}
+		for _, class := range sh.classes {
+			if !v.isInstanceOf(value, class) {
+				report("Class", &value)
+			}
+		}
+		for _, kind := range sh.nodeKinds {
+			if !hasNodeKind(value, kind) {
+				report("NodeKind", &value)
+			}
+		}
+		for _, re := range sh.patterns {
+			if value.isBnode || !re.MatchString(lexicalForm(value)) {
+				report("Pattern", &value)
+			}
+		}
+		for _, members := range sh.in {
+			if !containsObject(members, value) {
+				report("In", &value)
+			}
+		}
+	}
+
+	for _, prop := range sh.properties {
+		v.validate(prop, focus)
+	}
+}
+
+func (v *validation) hasDatatype(value object, datatype string) bool {
+	if !value.isLit {
+		return false
+	}
+	if value.lit.langtag != "" {
+		return isOneOf(v.shapes.langString, datatype)
+	}
+	if value.lit.typ != xsdTypeFromIRI(datatype) && string(value.lit.typ) != datatype {
+		return false
+	}
+	return validLexicalForm(value.lit.typ, value.lit.val)
+}
+
+func hasNodeKind(value object, kind string) bool {
+	switch strings.TrimPrefix(kind, shaclNamespace) {
+	case "IRI":
+		return !value.isLit && !value.isBnode
+	case "BlankNode":
+		return value.isBnode
+	case "Literal":
+		return value.isLit
+	case "BlankNodeOrIRI":
+		return !value.isLit
+	case "BlankNodeOrLiteral":
+		return value.isBnode || value.isLit
+	case "IRIOrLiteral":
+		return !value.isBnode
+	}
+	return false
+}
+
+func lexicalForm(o object) string {
+	if o.isLit {
+		return o.lit.val
+	}
+	return o.resource
+}
+
+func containsObject(objs []object, o object) bool {
+	for _, obj := range objs {
+		if obj.key() == o.key() {
+			return true
+		}
+	}
+	return false
+}
+
+// Triples describes the report as triples of the SHACL vocabulary, the
+// report and its results being generated blank nodes not used by other reports
+func (r ValidationReport) Triples() []Triple {
+	report := newGeneratedBnode()
+	tris := []Triple{
+		BnodePred(report, rdfType).Resource(shaclNamespace + "ValidationReport"),
+		BnodePred(report, shaclNamespace+"conforms").BooleanLiteral(r.Conforms),
+	}
+	for _, res := range r.Results {
+		result := newGeneratedBnode()
+		tris = append(tris,
+			BnodePred(report, shaclNamespace+"result").Bnode(result),
+			BnodePred(result, rdfType).Resource(shaclNamespace+"ValidationResult"),
+			BnodePred(result, shaclNamespace+"focusNode").Object(res.FocusNode),
+			BnodePred(result, shaclNamespace+"sourceShape").Object(res.SourceShape),
+			BnodePred(result, shaclNamespace+"sourceConstraintComponent").Resource(res.Constraint),
+			BnodePred(result, shaclNamespace+"resultSeverity").Resource(res.Severity),
+		)
+		if res.Path != "" && res.InversePath {
+			path := newGeneratedBnode()
+			tris = append(tris,
+				BnodePred(result, shaclNamespace+"resultPath").Bnode(path),
+				BnodePred(path, shaclNamespace+"inversePath").Resource(res.Path),
+			)
+		} else if res.Path != "" {
+			tris = append(tris, BnodePred(result, shaclNamespace+"resultPath").Resource(res.Path))
+		}
+		if res.Value != nil {
+			tris = append(tris, BnodePred(result, shaclNamespace+"value").Object(res.Value))
+		}
+		if res.Message != "" {
+			tris = append(tris, BnodePred(result, shaclNamespace+"resultMessage").StringLiteral(res.Message))
+		}
+	}
+	return tris
+}
@@ -0,0 +1,239 @@
+package triplestore_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+const personShapes = `
+@prefix sh: <http://www.w3.org/ns/shacl#> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+@prefix ex: <http://example.org/> .
+
+ex:PersonShape a sh:NodeShape ;
+	sh:targetClass ex:Person ;
+	sh:nodeKind sh:IRI ;
+	sh:property [
+		sh:path ex:name ;
+		sh:minCount 1 ;
+		sh:maxCount 1 ;
+		sh:datatype xsd:string ;
+		sh:pattern "^[a-z]+$" ;
+		sh:flags "i" ;
+		sh:message "invalid name"
+	] ;
+	sh:property [
+		sh:path ex:knows ;
+		sh:class ex:Person ;
+		sh:severity sh:Warning
+	] ;
+	sh:property [
+		sh:path ex:status ;
+		sh:in ( "active" "inactive" )
+	] ;
+	sh:property [
+		sh:path [ sh:inversePath ex:employs ] ;
+		sh:minCount 1
+	] .
+
+ex:AdminShape sh:targetNode ex:root ;
+	sh:property [
+		sh:path ex:role ;
+		sh:hasValue "admin"
+	] .
+
+ex:DisabledShape sh:targetClass ex:Person ;
+	sh:deactivated true ;
+	sh:property [ sh:path ex:name ; sh:maxCount 0 ] .
+`
+
+func TestShaclValidation(t *testing.T) {
+	tris, err := tstore.NewTurtleDecoder(strings.NewReader(personShapes)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	shapesSrc := tstore.NewSource()
+	shapesSrc.Add(tris...)
+	shapes, err := tstore.NewShapes(shapesSrc.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ex := func(s string) string { return "http://example.org/" + s }
+	data := tstore.NewSource()
+	data.Add(
+		tstore.SubjPredRes(ex("Employee"), "rdfs:subClassOf", ex("Person")),
+		tstore.SubjPredRes("acme", ex("employs"), ex("alice")),
+		tstore.SubjPredRes("acme", ex("employs"), ex("bob")),
+		tstore.SubjPredRes(ex("alice"), "rdf:type", ex("Person")),
+		tstore.SubjPred(ex("alice"), ex("name")).StringLiteral("Alice"),
+		tstore.SubjPredRes(ex("alice"), ex("knows"), ex("bob")),
+		tstore.SubjPred(ex("alice"), ex("status")).StringLiteral("active"),
+		tstore.SubjPredRes(ex("bob"), "http://www.w3.org/1999/02/22-rdf-syntax-ns#type", ex("Employee")),
+		tstore.SubjPred(ex("bob"), ex("name")).StringLiteral("Bob"),
+		tstore.SubjPred(ex("root"), ex("role")).StringLiteral("admin"),
+	)
+
+	report := shapes.Validate(data.Snapshot())
+	if !report.Conforms {
+		t.Fatalf("expected conforming data, got %+v", report.Results)
+	}
+
+	data.Add(
+		tstore.BnodePredRes("anonymous", "rdf:type", ex("Person")),
+		tstore.SubjPred(ex("bob"), ex("name")).IntegerLiteral(42),
+		tstore.SubjPredRes(ex("bob"), ex("knows"), ex("root")),
+		tstore.SubjPred(ex("bob"), ex("status")).StringLiteral("unknown"),
+	)
+	data.Remove(tstore.SubjPred(ex("root"), ex("role")).StringLiteral("admin"))
+
+	report = shapes.Validate(data.Snapshot())
+	if report.Conforms {
+		t.Fatal("expected non conforming data")
+	}
+	count := make(map[string]int)
+	for _, res := range report.Results {
+		count[strings.TrimPrefix(res.Constraint, "http://www.w3.org/ns/shacl#")]++
+		if res.Constraint == "http://www.w3.org/ns/shacl#ClassConstraintComponent" {
+			if got, want := res.Severity, "http://www.w3.org/ns/shacl#Warning"; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+			if got, want := res.Value, tstore.Resource(ex("root")); !got.Equal(want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		}
+		if res.Constraint == "http://www.w3.org/ns/shacl#PatternConstraintComponent" {
+			if got, want := res.Message, "invalid name"; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		}
+	}
+	expected := map[string]int{
+		"NodeKindConstraintComponent": 1, // anonymous
+		"MinCountConstraintComponent": 2, // anonymous name and employer
+		"MaxCountConstraintComponent": 1, // bob names
+		"DatatypeConstraintComponent": 1, // bob integer name
+		"PatternConstraintComponent":  1, // bob integer name
+		"ClassConstraintComponent":    1, // bob knows root
+		"InConstraintComponent":       1, // bob status
+		"HasValueConstraintComponent": 1, // root role
+	}
+	for component, want := range expected {
+		if got := count[component]; got != want {
+			t.Fatalf("%s: got %d, want %d (%+v)", component, got, want, report.Results)
+		}
+	}
+
+	reportSrc := tstore.NewSource()
+	reportSrc.Add(report.Triples()...)
+	g := reportSrc.Snapshot()
+	if got, want := len(g.WithPredicate("http://www.w3.org/ns/shacl#result")), len(report.Results); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	conforms := g.WithPredicate("http://www.w3.org/ns/shacl#conforms")
+	if len(conforms) != 1 || !conforms[0].Object().Equal(tstore.BooleanLiteral(false)) {
+		t.Fatalf("expected report not to conform, got %v", conforms)
+	}
+
+	// results of reports merged together stay apart
+	reportSrc.Add(report.Triples()...)
+	if got, want := len(reportSrc.Snapshot().WithPredicate("http://www.w3.org/ns/shacl#result")), 2*len(report.Results); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestShaclUnsupportedShapes(t *testing.T) {
+	tris, err := tstore.NewTurtleDecoder(strings.NewReader(`
+		@prefix sh: <http://www.w3.org/ns/shacl#> .
+		<shape> sh:targetNode <node> ; sh:property [ sh:path ( <a> <b> ) ] .
+	`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := tstore.NewSource()
+	src.Add(tris...)
+	if _, err := tstore.NewShapes(src.Snapshot()); err == nil {
+		t.Fatal("expected error on sequence path")
+	}
+}
+
+func TestShaclDatatypeLexicalForms(t *testing.T) {
+	tris, err := tstore.NewTurtleDecoder(strings.NewReader(`
+		@prefix sh: <http://www.w3.org/ns/shacl#> .
+		@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+		<shape> sh:targetSubjectsOf <age>, <born>, <ok>, <level> ;
+			sh:property [ sh:path <age> ; sh:datatype xsd:integer ] ;
+			sh:property [ sh:path <born> ; sh:datatype xsd:dateTime ] ;
+			sh:property [ sh:path <ok> ; sh:datatype xsd:boolean ] ;
+			sh:property [ sh:path <level> ; sh:datatype xsd:unsignedByte ] .
+	`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	shapesSrc := tstore.NewSource()
+	shapesSrc.Add(tris...)
+	shapes, err := tstore.NewShapes(shapesSrc.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := tstore.NewTurtleDecoder(strings.NewReader(`
+		@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+		<alice> <age> 42 ; <born> "1976-03-02T10:00:00Z"^^xsd:dateTime ; <ok> true ; <level> "255"^^xsd:unsignedByte .
+		<bob> <age> "-7"^^xsd:integer ; <born> "1976-03-02T10:00:00"^^xsd:dateTime ; <ok> "0"^^xsd:boolean .
+		<carol> <age> "abc"^^xsd:integer ; <born> "1976-13-02T10:00:00Z"^^xsd:dateTime ; <ok> "yes"^^xsd:boolean ; <level> "256"^^xsd:unsignedByte .
+	`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := tstore.NewSource()
+	src.Add(data...)
+
+	report := shapes.Validate(src.Snapshot())
+	if got, want := len(report.Results), 4; got != want {
+		t.Fatalf("got %d, want %d: %+v", got, want, report.Results)
+	}
+	for _, res := range report.Results {
+		if got, want := res.FocusNode, tstore.Resource("carol"); !got.Equal(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if got, want := res.Constraint, "http://www.w3.org/ns/shacl#DatatypeConstraintComponent"; got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestShaclRecursiveShapes(t *testing.T) {
+	tris, err := tstore.NewTurtleDecoder(strings.NewReader(`
+		@prefix sh: <http://www.w3.org/ns/shacl#> .
+		<PersonShape> sh:targetNode <alice> ; sh:property <knows> .
+		<knows> sh:path <knows> ; sh:minCount 1 ; sh:property <knows>, <name> .
+		<name> sh:path <name> ; sh:maxCount 1 ; sh:property <knows> .
+	`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := tstore.NewSource()
+	src.Add(tris...)
+	shapes, err := tstore.NewShapes(src.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := tstore.NewSource()
+	data.Add(
+		tstore.SubjPred("alice", "name").StringLiteral("Alice"),
+		tstore.SubjPred("alice", "name").StringLiteral("Al"),
+	)
+	report := shapes.Validate(data.Snapshot())
+	count := make(map[string]int)
+	for _, res := range report.Results {
+		count[strings.TrimPrefix(res.Constraint, "http://www.w3.org/ns/shacl#")]++
+	}
+	if got, want := count, map[string]int{"MinCountConstraintComponent": 1, "MaxCountConstraintComponent": 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+package triplestore
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkSnapshotAfterSmallWrite shows that the cost of a snapshot
+// depends on the number of changes, not on the size of the source
+//
+// BenchmarkSnapshotAfterSmallWrite/1000         	   38414	     29867 ns/op	   15016 B/op	     141 allocs/op
+// BenchmarkSnapshotAfterSmallWrite/10000        	   30238	     41011 ns/op	   18880 B/op	     184 allocs/op
+// BenchmarkSnapshotAfterSmallWrite/100000       	   22010	     57571 ns/op	   22712 B/op	     220 allocs/op
+func BenchmarkSnapshotAfterSmallWrite(b *testing.B) {
+	for _, size := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			s := NewSource()
+			for i := 0; i < size; i++ {
+				num := fmt.Sprint(i)
+				s.Add(SubjPred(num, "digit").IntegerLiteral(i))
+			}
+			s.Snapshot()
+			tri := SubjPred("new", "digit").IntegerLiteral(-1)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Add(tri)
+				s.Snapshot()
+				s.Remove(tri)
+				s.Snapshot()
+			}
+		})
+	}
+}
+
+// BenchmarkSnapshotBulkLoad shows that adding many triples at once to an
+// empty source builds its indexes in one pass
+//
+// BenchmarkSnapshotBulkLoad     	      30	  37214917 ns/op	 9910840 B/op	   65356 allocs/op
+func BenchmarkSnapshotBulkLoad(b *testing.B) {
+	var triples []Triple
+	for i := 0; i < 10000; i++ {
+		num := fmt.Sprint(i)
+		triples = append(triples, SubjPred(num, "digit").IntegerLiteral(i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := NewSource()
+		s.Add(triples...)
+		s.Snapshot()
+	}
+}
+
+// BenchmarkQuerySnapshot        	  659761	      1823 ns/op	     319 B/op	       4 allocs/op
+func BenchmarkQuerySnapshot(b *testing.B) {
+	s := NewSource()
+	for i := 0; i < 10000; i++ {
+		num := fmt.Sprint(i)
+		s.Add(SubjPred(num, "digit").IntegerLiteral(i))
+	}
+	g := s.Snapshot()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.WithSubject(fmt.Sprint(i % 10000))
+		g.Contains(SubjPred("1", "digit").IntegerLiteral(1))
+	}
+}
+
+// BenchmarkScanSnapshot compares collecting the triples of an index entry with
+// copying the cached triples of the graph and with iterating over them: scans
+// rebuild a triple each without collecting them, while Triples copies a slice
+// of the graph size
+//
+// BenchmarkScanSnapshot/with_predicate         	     349	   3398617 ns/op	 1726720 B/op	   10002 allocs/op
+// BenchmarkScanSnapshot/triples                	    6684	    204627 ns/op	  163864 B/op	       1 allocs/op
+// BenchmarkScanSnapshot/scan                   	     440	   3231950 ns/op	 1620800 B/op	   10160 allocs/op
+func BenchmarkScanSnapshot(b *testing.B) {
+	s := NewSource()
+	for i := 0; i < 10000; i++ {
+		s.Add(SubjPred(fmt.Sprint(i), "digit").IntegerLiteral(i))
+	}
+
+	b.Run("with predicate", func(b *testing.B) {
+		snap := s.Snapshot()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, t := range snap.WithPredicate("digit") {
+				if t.Subject() == "" {
+					b.Fatal("unexpected triple")
+				}
+			}
+		}
+	})
+	b.Run("triples", func(b *testing.B) {
+		snap := s.Snapshot()
+		snap.Triples()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, t := range snap.Triples() {
+				if t.Subject() == "" {
+					b.Fatal("unexpected triple")
+				}
+			}
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		snap := s.Snapshot()
+		pred := "digit"
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			snap.Scan(nil, &pred, nil)(func(t Triple) bool {
+				if t.Subject() == "" {
+					b.Fatal("unexpected triple")
+				}
+				return true
+			})
+		}
+	})
+}
+
+// TestSnapshotHeap checks the memory retained by a snapshot of 200k triples
+// with shared subjects and predicates. Indexes keyed by concatenated terms
+// took 968 bytes per triple, interned terms and compact sets take 456.
+func TestSnapshotHeap(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	s := NewSource()
+	for i := 0; i < 200000; i++ {
+		s.Add(SubjPred(fmt.Sprint("http://ex.org/s", i%20000), fmt.Sprint("http://ex.org/p", i%50)).StringLiteral(fmt.Sprint("value ", i)))
+	}
+	g := s.Snapshot()
+	s = nil
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	if got, max := (after.HeapAlloc-before.HeapAlloc)/uint64(g.Count()), uint64(500); got > max {
+		t.Fatalf("got %d bytes per triple, want at most %d", got, max)
+	}
+	runtime.KeepAlive(g)
+}
+
+// BenchmarkMatchPage paginates over the 10k triples of a predicate by pages of 100.
+// Matching and sorting again for each page took 1.3 s and 219 MB.
+//
+// BenchmarkMatchPage 	    3076	    382486 ns/op	  196504 B/op	     603 allocs/op
+func BenchmarkMatchPage(b *testing.B) {
+	s := NewSource()
+	for i := 0; i < 10000; i++ {
+		s.Add(SubjPred(fmt.Sprint(i), "digit").IntegerLiteral(i))
+	}
+	snap := s.Snapshot()
+	pred := "digit"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var cursor string
+		for {
+			page, err := MatchPage(snap, nil, &pred, nil, cursor, 100)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if cursor = page.Next; cursor == "" {
+				break
+			}
+		}
+	}
+}
+
+// TestSortedPagesPerSource checks that the sorted triples kept for pagination
+// are bounded by source, however many snapshots are paginated over
+func TestSortedPagesPerSource(t *testing.T) {
+	s := NewSource()
+	pred := "digit"
+	var pages *sortedPages
+	for i := 0; i < 3*sortedPatterns; i++ {
+		s.Add(SubjPred(fmt.Sprint(i), pred).IntegerLiteral(i))
+		snap := s.Snapshot()
+		for _, p := range []*string{nil, &pred} {
+			if _, err := MatchPage(snap, nil, p, nil, "", 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+		pages = snap.(*graph).pages
+	}
+	if got, want := len(pages.byKey), sortedPatterns; got != want {
+		t.Fatalf("got %d sorted matches, want %d", got, want)
+	}
+
+	snap := s.Snapshot()
+	first, _ := MatchPage(snap, nil, &pred, nil, "", 10)
+	s.Add(SubjPred("190", pred).IntegerLiteral(-1))
+	if next, _ := MatchPage(snap, nil, &pred, nil, first.Next, 10); next.Triples[0].Subject() != "19" {
+		t.Fatalf("expected the snapshot pages to be unchanged, got %v", next.Triples[0])
+	}
+	if next, _ := MatchPage(s.Snapshot(), nil, &pred, nil, first.Next, 10); next.Triples[0].Subject() != "190" {
+		t.Fatalf("expected a new snapshot to be sorted again, got %v", next.Triples[0])
+	}
+}
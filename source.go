@@ -1,6 +1,7 @@
 package triplestore
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sort"
@@ -15,6 +16,10 @@ type Source interface {
 	Remove(...Triple)
 	Snapshot() RDFGraph
 	CopyTriples() []Triple
+	Begin() Tx
+	Update(func(Tx) error) error
+	Watch(context.Context) <-chan ChangeEvent
+	Version() uint64
 }
 
 // A RDFGraph is an immutable set of triples. It is a snapshot of a source and it is queryable.
@@ -28,6 +33,7 @@ type RDFGraph interface {
 	WithSubjObj(s string, o Object) []Triple
 	WithSubjPred(s, p string) []Triple
 	WithPredObj(p string, o Object) []Triple
+	Scan(s, p *string, o Object) TripleSeq
 }
 
 type Triples []Triple
@@ -50,6 +56,8 @@ func (ts Triples) Equal(others Triples) bool {
 	return reflect.DeepEqual(this, other)
 }
 
+// Sort sorts the triples in descending key order.
+// Use SortedMatch to get the triples of a graph in ascending order.
 func (ts Triples) Sort() {
 	sort.Slice(ts, func(i, j int) bool { return ts[i].(*triple).key() > ts[j].(*triple).key() })
 }
@@ -71,16 +79,21 @@ func (ts Triples) String() string {
 type source struct {
 	latestSnap atomic.Value
 	updated    uint32 // atomic
+	version    uint64 // atomic
 	mu         sync.RWMutex
-	triples    map[string]Triple
+	// last operation on each triple since the latest snapshot
+	changes  map[string]operation
+	watchers map[*watcher]struct{}
 }
 
-// A source is a persistent yet mutable source or container of triples
+// NewSource returns an in-memory source of triples.
+// Use OpenLogSource for a source persisted on disk.
 func NewSource() Source {
 	s := &source{
-		triples: make(map[string]Triple),
+		changes:  make(map[string]operation),
+		watchers: make(map[*watcher]struct{}),
 	}
-	s.latestSnap.Store(newGraph(0))
+	s.latestSnap.Store(newGraph())
 	return s
 }
 
@@ -97,124 +110,443 @@ func (s *source) reset() {
 }
 
 func (s *source) Add(ts ...Triple) {
+	s.apply(operations(addOperation, ts))
+}
+
+func (s *source) Remove(ts ...Triple) {
+	s.apply(operations(removeOperation, ts))
+}
+
+// apply changes the source with the operations as a single batch,
+// notifying watchers of the triples actually added or removed
+func (s *source) apply(ops []operation) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	defer s.update()
+	s.change(ops)
+}
 
-	for _, t := range ts {
-		tr := t.(*triple)
-		s.triples[tr.key()] = t
+// bulkSize is the number of operations from which a batch is applied
+// directly to a new snapshot rather than recorded as changes
+const bulkSize = 1024
+
+// change must be called with the lock held
+func (s *source) change(ops []operation) {
+	var evt ChangeEvent
+	if len(ops) >= bulkSize {
+		evt = s.load(ops)
+	} else {
+		evt = s.record(ops)
+	}
+	if len(evt.Added) == 0 && len(evt.Removed) == 0 {
+		return
+	}
+	evt.Version = atomic.AddUint64(&s.version, 1)
+	for w := range s.watchers {
+		w.push(evt)
 	}
 }
 
-func (s *source) Remove(ts ...Triple) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// record keeps the last operation on each triple until the next snapshot
+func (s *source) record(ops []operation) (evt ChangeEvent) {
 	defer s.update()
 
-	for _, t := range ts {
-		tr := t.(*triple)
-		delete(s.triples, tr.key())
+	initial := make(map[string]bool, len(ops))
+	var order []string
+	for _, o := range ops {
+		k := o.triple.(*triple).key()
+		if _, seen := initial[k]; !seen {
+			initial[k] = s.contains(k, o.triple)
+			order = append(order, k)
+		}
+		s.changes[k] = o
 	}
-}
 
-func (s *source) CopyTriples() (out []Triple) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, t := range s.triples {
-		out = append(out, t.(*triple).clone())
+	for _, k := range order {
+		final := s.changes[k]
+		switch {
+		case final.op == addOperation && !initial[k]:
+			evt.Added = append(evt.Added, final.triple)
+		case final.op == removeOperation && initial[k]:
+			evt.Removed = append(evt.Removed, final.triple)
+		}
 	}
 	return
 }
 
-func (s *source) Snapshot() RDFGraph {
-	if !s.isUpdated() {
-		return s.latestSnap.Load().(RDFGraph)
-	}
+// load applies the operations to a new snapshot built in place with a
+// single edit token, after the changes recorded since the latest snapshot
+func (s *source) load(ops []operation) ChangeEvent {
+	gph, evt := s.snapshot().changed(ops)
+	s.store(gph)
+	return evt
+}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// reclaimSize is the number of terms from which a dictionary is reclaimed
+// when at least half of them are not used by the latest snapshot anymore
+const reclaimSize = 4096
 
-	gph := newGraph(len(s.triples))
+// store makes the graph the latest snapshot. As triples have at most three
+// terms, a dictionary with more than twice as many terms is reclaimed by
+// moving the graph to a new one. It must be called with the lock held.
+func (s *source) store(gph *graph) *graph {
+	if size := gph.dict.size(); size >= reclaimSize && size > 6*gph.Count() {
+		gph = gph.compacted()
+	}
+	s.latestSnap.Store(gph)
+	return gph
+}
 
-	for k, t := range s.triples {
-		objKey := t.Object().(object).key()
-		sub, pred := t.Subject(), t.Predicate()
+// compact moves the latest snapshot to a new dictionary holding its terms only
+func (s *source) compact() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latestSnap.Store(s.snapshot().compacted())
+}
 
-		gph.s[sub] = append(gph.s[sub], t)
-		gph.p[pred] = append(gph.p[pred], t)
-		gph.o[objKey] = append(gph.o[objKey], t)
+// contains must be called with the lock held
+func (s *source) contains(k string, t Triple) bool {
+	if o, ok := s.changes[k]; ok {
+		return o.op == addOperation
+	}
+	return s.latestSnap.Load().(*graph).Contains(t)
+}
 
-		sp := sub + pred
-		gph.sp[sp] = append(gph.sp[sp], t)
+func (s *source) CopyTriples() []Triple {
+	gph := s.Snapshot().(*graph)
+	return gph.rebuild(gph.spo)
+}
 
-		so := sub + objKey
-		gph.so[so] = append(gph.so[so], t)
+// Snapshot applies the changes since the latest snapshot to a new version
+// of its indexes, leaving the latest snapshot unmodified. Its cost is thus
+// proportional to the number of changes rather than to the size of the source.
+func (s *source) Snapshot() RDFGraph {
+	if !s.isUpdated() {
+		return s.latestSnap.Load().(RDFGraph)
+	}
 
-		po := pred + objKey
-		gph.po[po] = append(gph.po[po], t)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
 
-		gph.spo[k] = t
+// snapshot must be called with the lock held
+func (s *source) snapshot() *graph {
+	gph := s.latestSnap.Load().(*graph)
+	if len(s.changes) == 0 {
+		s.reset()
+		return gph
 	}
 
-	s.latestSnap.Store(gph)
+	ops := make([]operation, 0, len(s.changes))
+	for _, o := range s.changes {
+		ops = append(ops, o)
+	}
+	gph = s.store(gph.with(ops))
+	s.changes = make(map[string]operation)
 	s.reset()
 
 	return gph
 }
 
+// A graph indexes the IDs of its triples in persistent maps, shared between
+// successive snapshots. Indexes map the ID of a subject, a predicate, an object
+// or a pair of them to the set of IDs of matching triples. Triples are rebuilt
+// from the dictionary of terms when queried.
 type graph struct {
+	dict       *dictionary
 	once       sync.Once
 	unique     []Triple
-	s, p, o    map[string][]Triple
-	sp, so, po map[string][]Triple
-	spo        map[string]Triple
+	s, p, o    pmap
+	sp, so, po pmap
+	spo        pset
+
+	// sorted triples paginated over, shared by the snapshots of a source
+	pages      *sortedPages
+	snapshotID uint64
+}
+
+func newGraph() *graph {
+	return &graph{dict: newDictionary(), pages: new(sortedPages)}
 }
 
-func newGraph(cap int) *graph {
-	return &graph{
-		s:   make(map[string][]Triple, cap),
-		p:   make(map[string][]Triple, cap),
-		o:   make(map[string][]Triple, cap),
-		sp:  make(map[string][]Triple, cap),
-		so:  make(map[string][]Triple, cap),
-		po:  make(map[string][]Triple, cap),
-		spo: make(map[string]Triple, cap),
+// with returns a new graph with the given operations applied
+func (g *graph) with(ops []operation) *graph {
+	gph, _ := g.apply(ops, false)
+	return gph
+}
+
+// compacted returns a new graph with the same triples, interned in a new
+// dictionary so that the terms no longer used are reclaimed with older snapshots
+func (g *graph) compacted() *graph {
+	gph := newGraph()
+	gph.pages = g.pages
+	return gph.with(operations(addOperation, g.rebuild(g.spo)))
+}
+
+// changed returns a new graph with the given operations applied,
+// along with the triples it actually adds or removes
+func (g *graph) changed(ops []operation) (*graph, ChangeEvent) {
+	return g.apply(ops, true)
+}
+
+func (g *graph) apply(ops []operation, track bool) (*graph, ChangeEvent) {
+	edit := new(int)
+	gph := &graph{dict: g.dict, s: g.s, p: g.p, o: g.o, sp: g.sp, so: g.so, po: g.po, spo: g.spo, pages: g.pages}
+	gph.snapshotID = g.pages.nextID()
+
+	// the indexes of a graph with no triples are built once its triples are known
+	empty := g.spo.len() == 0
+
+	keys, known := gph.dict.internAdded(ops)
+	var initial map[idKey]bool
+	var last map[idKey]int
+	if track {
+		initial, last = make(map[idKey]bool, len(ops)), make(map[idKey]int, len(ops))
 	}
+	for i, o := range ops {
+		if !known[i] {
+			continue
+		}
+		k := keys[i]
+		count := gph.spo.len()
+		var exists bool
+		if o.op == addOperation {
+			gph.spo = gph.spo.add(k, edit)
+			if exists = gph.spo.len() == count; !exists && !empty {
+				gph.index(k, edit)
+			}
+		} else {
+			gph.spo = gph.spo.remove(k, edit)
+			if exists = gph.spo.len() != count; exists && !empty {
+				gph.unindex(k, edit)
+			}
+		}
+		if track {
+			if _, seen := initial[k]; !seen {
+				initial[k] = exists
+			}
+			last[k] = i
+		}
+	}
+
+	if empty {
+		gph.build()
+	}
+
+	var evt ChangeEvent
+	for i, o := range ops {
+		if !track || !known[i] || last[keys[i]] != i {
+			continue
+		}
+		switch {
+		case o.op == addOperation && !initial[keys[i]]:
+			evt.Added = append(evt.Added, o.triple)
+		case o.op == removeOperation && initial[keys[i]]:
+			evt.Removed = append(evt.Removed, o.triple)
+		}
+	}
+	return gph, evt
+}
+
+// build indexes the triples of the spo set at once
+func (g *graph) build() {
+	tris := make([]idKey, 0, g.spo.len())
+	g.spo.each(func(k idKey) {
+		tris = append(tris, k)
+	})
+	// buildMap reorders and overwrites its entries, so refill them for each index
+	entries, scratch := make([]bentry, len(tris)), make([]bentry, len(tris))
+	index := func(key func(k idKey) idKey) pmap {
+		for i, k := range tris {
+			entries[i] = bentry{key: key(k), tri: k}
+		}
+		return buildMap(entries, scratch)
+	}
+	g.s = index(func(k idKey) idKey { return idKey{a: k.a &^ bnodeID} })
+	g.p = index(func(k idKey) idKey { return idKey{b: k.b} })
+	g.o = index(func(k idKey) idKey { return idKey{c: k.c} })
+	g.sp = index(func(k idKey) idKey { return idKey{a: k.a &^ bnodeID, b: k.b} })
+	g.so = index(func(k idKey) idKey { return idKey{a: k.a &^ bnodeID, c: k.c} })
+	g.po = index(func(k idKey) idKey { return idKey{b: k.b, c: k.c} })
+}
+
+// index adds the triple to the indexes of its terms, once added to the spo set
+func (g *graph) index(k idKey, edit *int) {
+	sub, pred, obj := k.a&^bnodeID, k.b, k.c
+
+	g.s = addToIndex(g.s, idKey{a: sub}, k, edit)
+	g.p = addToIndex(g.p, idKey{b: pred}, k, edit)
+	g.o = addToIndex(g.o, idKey{c: obj}, k, edit)
+	g.sp = addToIndex(g.sp, idKey{a: sub, b: pred}, k, edit)
+	g.so = addToIndex(g.so, idKey{a: sub, c: obj}, k, edit)
+	g.po = addToIndex(g.po, idKey{b: pred, c: obj}, k, edit)
+}
+
+// unindex removes the triple from the indexes of its terms, once removed from the spo set
+func (g *graph) unindex(k idKey, edit *int) {
+	sub, pred, obj := k.a&^bnodeID, k.b, k.c
+
+	g.s = removeFromIndex(g.s, idKey{a: sub}, k, edit)
+	g.p = removeFromIndex(g.p, idKey{b: pred}, k, edit)
+	g.o = removeFromIndex(g.o, idKey{c: obj}, k, edit)
+	g.sp = removeFromIndex(g.sp, idKey{a: sub, b: pred}, k, edit)
+	g.so = removeFromIndex(g.so, idKey{a: sub, c: obj}, k, edit)
+	g.po = removeFromIndex(g.po, idKey{b: pred, c: obj}, k, edit)
+}
+
+func addToIndex(idx pmap, key, triKey idKey, edit *int) pmap {
+	return idx.update(key, func(set pset) pset { return set.add(triKey, edit) }, edit)
+}
+
+func removeFromIndex(idx pmap, key, triKey idKey, edit *int) pmap {
+	return idx.update(key, func(set pset) pset { return set.remove(triKey, edit) }, edit)
+}
+
+// matching returns the set of IDs of the triples matching the given subject,
+// predicate and object, a nil component matching anything
+func (g *graph) matching(s, p *string, o Object) (pset, bool) {
+	var key idKey
+	var ok bool
+	if s != nil {
+		if key.a, ok = g.dict.nameID(*s); !ok {
+			return pset{}, false
+		}
+	}
+	if p != nil {
+		if key.b, ok = g.dict.nameID(*p); !ok {
+			return pset{}, false
+		}
+	}
+	if o != nil {
+		if key.c, ok = g.dict.objectID(o.(object)); !ok {
+			return pset{}, false
+		}
+	}
+
+	var idx pmap
+	switch {
+	case s != nil && p != nil && o != nil:
+		var set pset
+		for _, k := range []idKey{key, {a: key.a | bnodeID, b: key.b, c: key.c}} {
+			if g.spo.has(k) {
+				set = set.add(k, nil)
+			}
+		}
+		return set, set.len() > 0
+	case s != nil && p != nil:
+		idx = g.sp
+	case s != nil && o != nil:
+		idx = g.so
+	case p != nil && o != nil:
+		idx = g.po
+	case s != nil:
+		idx = g.s
+	case p != nil:
+		idx = g.p
+	case o != nil:
+		idx = g.o
+	default:
+		return g.spo, true
+	}
+	set, ok := idx.get(key)
+	if !ok {
+		return pset{}, false
+	}
+	return set, true
+}
+
+func (g *graph) rebuild(set pset) []Triple {
+	keys := make([]idKey, 0, set.len())
+	set.each(func(k idKey) {
+		keys = append(keys, k)
+	})
+	return g.dict.triples(keys)
+}
+
+func (g *graph) query(s, p *string, o Object) []Triple {
+	set, ok := g.matching(s, p, o)
+	if !ok {
+		return nil
+	}
+	return g.rebuild(set)
 }
 
 func (g *graph) Contains(t Triple) bool {
-	_, ok := g.spo[t.(*triple).key()]
-	return ok
+	k, known := g.dict.lookup(t.(*triple))
+	if !known {
+		return false
+	}
+	return g.spo.has(k)
 }
+
+// Triples returns a copy of the triples of the graph, rebuilt once and cached.
+// Copying keeps the cache safe from callers but costs a slice of the size of the
+// graph on each call: use Scan to iterate over the triples instead.
 func (g *graph) Triples() []Triple {
 	g.once.Do(func() {
-		for _, t := range g.spo {
-			g.unique = append(g.unique, t)
-		}
+		g.unique = g.rebuild(g.spo)
 	})
-	return g.unique
+	return append([]Triple(nil), g.unique...)
 }
 
 func (g *graph) Count() int {
-	return len(g.spo)
+	return g.spo.len()
+}
+
+// scanChunk is the number of triples a scan rebuilds under a single lock
+const scanChunk = 64
+
+// Scan iterates over the triples matching the given subject, predicate and object
+// without collecting them, walking the index entry directly. Triples are rebuilt
+// by chunks, each yielded triple being a new one that can be kept.
+func (g *graph) Scan(s, p *string, o Object) TripleSeq {
+	return func(yield func(Triple) bool) {
+		set, ok := g.matching(s, p, o)
+		if !ok {
+			return
+		}
+		size := set.len()
+		if size > scanChunk {
+			size = scanChunk
+		}
+		keys := make([]idKey, 0, size)
+		flush := func() bool {
+			for _, t := range g.dict.triples(keys) {
+				if !yield(t) {
+					return false
+				}
+			}
+			keys = keys[:0]
+			return true
+		}
+		more := true
+		set.walk(func(k idKey) bool {
+			if keys = append(keys, k); len(keys) == size {
+				more = flush()
+			}
+			return more
+		})
+		if more && len(keys) > 0 {
+			flush()
+		}
+	}
 }
 
 func (g *graph) WithSubject(s string) []Triple {
-	return g.s[s]
+	return g.query(&s, nil, nil)
 }
 func (g *graph) WithPredicate(p string) []Triple {
-	return g.p[p]
+	return g.query(nil, &p, nil)
 }
 func (g *graph) WithObject(o Object) []Triple {
-	return g.o[o.(object).key()]
+	return g.query(nil, nil, o)
 }
 func (g *graph) WithSubjObj(s string, o Object) []Triple {
-	return g.so[s+o.(object).key()]
+	return g.query(&s, nil, o)
 }
 func (g *graph) WithSubjPred(s, p string) []Triple {
-	return g.sp[s+p]
+	return g.query(&s, &p, nil)
 }
 func (g *graph) WithPredObj(p string, o Object) []Triple {
-	return g.po[p+o.(object).key()]
+	return g.query(nil, &p, o)
 }
@@ -179,7 +179,7 @@ func TestStoreConcurrentAccess(t *testing.T) {
 	wg.Wait()
 }
 
-// BenchmarkSnapshotSource-4   	       1	7462513791 ns/op
+// BenchmarkSnapshotSource-4   	       1	1904941740 ns/op
 func BenchmarkSnapshotSource(b *testing.B) {
 	s := tstore.NewSource()
 	for i := 0; i < 100000; i++ {
@@ -205,3 +205,34 @@ func BenchmarkSnapshotSource(b *testing.B) {
 		}
 	}
 }
+
+func TestSnapshotIndexesWithoutCollision(t *testing.T) {
+	s := tstore.NewSource()
+	s.Add(
+		tstore.SubjPred("ab", "c").Resource("d"),
+		tstore.BnodePred("ab", "c").Resource("d"),
+		tstore.SubjPred("a", "bc").Resource("d"),
+	)
+	g := s.Snapshot()
+
+	if got, want := g.Count(), 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := len(g.WithSubjPred("a", "bc")), 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := len(g.WithSubjPred("ab", "c")), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := len(g.WithSubjObj("a", tstore.Resource("d"))), 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := g.Contains(tstore.BnodePred("a", "bc").Resource("d")), false; got != want {
+		t.Fatalf("got %t, want %t", got, want)
+	}
+
+	s.Remove(tstore.BnodePred("ab", "c").Resource("d"))
+	if got, want := tstore.Triples(s.Snapshot().WithSubject("ab")), (tstore.Triples{tstore.SubjPred("ab", "c").Resource("d")}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
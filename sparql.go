@@ -0,0 +1,950 @@
+package triplestore
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	sparqlSelect    = "SELECT"
+	sparqlAsk       = "ASK"
+	sparqlConstruct = "CONSTRUCT"
+)
+
+// A SparqlQuery is a parsed SPARQL query ready to be evaluated against RDFGraphs.
+//
+// Only a subset of SPARQL 1.1 is supported: SELECT (with DISTINCT and projection),
+// ASK and CONSTRUCT query forms, basic graph patterns, OPTIONAL, UNION, FILTER,
+// ORDER BY, LIMIT and OFFSET.
+type SparqlQuery struct {
+	form     string
+	distinct bool
+	vars     []string
+	template []TriplePattern
+	where    *groupPattern
+	orderBy  []orderCondition
+	limit    int
+	offset   int
+}
+
+// A SparqlResult holds the outcome of a query according to its form:
+// variable bindings for SELECT, a boolean for ASK and triples for CONSTRUCT
+type SparqlResult struct {
+	Vars     []string
+	Bindings []Binding
+	Boolean  bool
+	Triples  []Triple
+}
+
+type groupPattern struct {
+	elems   []interface{}
+	filters []sparqlExpr
+}
+
+type bgpElem struct {
+	patterns []TriplePattern
+}
+
+type optionalElem struct {
+	group *groupPattern
+}
+
+type unionElem struct {
+	groups []*groupPattern
+}
+
+type orderCondition struct {
+	expr       sparqlExpr
+	descending bool
+}
+
+// ParseSparql parses the given SPARQL query.
+//
+// Prefixes and base of the optional context are available to the query
+// and can be overridden by its own PREFIX and BASE declarations. As the library
+// commonly stores compact IRIs (ex: rdf:type), prefixed names with an
+// undeclared prefix are kept as is instead of raising an error.
+func ParseSparql(query string, c *Context) (*SparqlQuery, error) {
+	toks, err := lexSparql(query)
+	if err != nil {
+		return nil, fmt.Errorf("sparql: %s", err)
+	}
+
+	q, err := newSparqlParser(toks, c).parseQuery()
+	if err != nil {
+		return nil, fmt.Errorf("sparql: %s", err)
+	}
+	return q, nil
+}
+
+type sparqlTokenKind int
+
+const (
+	tokEOF sparqlTokenKind = iota
+	tokIRI
+	tokPName
+	tokVar
+	tokBnode
+	tokString
+	tokLang
+	tokDatatype
+	tokInteger
+	tokDecimal
+	tokDouble
+	tokWord
+	tokPunct
+)
+
+type sparqlToken struct {
+	kind sparqlTokenKind
+	val  string
+	pos  int
+}
+
+func (t sparqlToken) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("'%s' at offset %d", t.val, t.pos)
+}
+
+func lexSparql(s string) (toks []sparqlToken, err error) {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '<':
+			if end := scanIRIRef(s[i:]); end > 0 {
+				toks = append(toks, sparqlToken{kind: tokIRI, val: s[i+1 : i+end-1], pos: i})
+				i += end
+			} else if strings.HasPrefix(s[i:], "<=") {
+				toks = append(toks, sparqlToken{kind: tokPunct, val: "<=", pos: i})
+				i += 2
+			} else {
+				toks = append(toks, sparqlToken{kind: tokPunct, val: "<", pos: i})
+				i++
+			}
+		case c == '?' || c == '$':
+			j := i + 1
+			for j < len(s) && isSparqlNameChar(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid variable at offset %d", i)
+			}
+			toks = append(toks, sparqlToken{kind: tokVar, val: s[i+1 : j], pos: i})
+			i = j
+		case c == '"' || c == '\'':
+			val, n, serr := scanSparqlString(s[i:])
+			if serr != nil {
+				return nil, fmt.Errorf("%s at offset %d", serr, i)
+			}
+			toks = append(toks, sparqlToken{kind: tokString, val: val, pos: i})
+			i += n
+		case c == '@':
+			j := i + 1
+			for j < len(s) && (isASCIILetter(s[j]) || isASCIIDigit(s[j]) || s[j] == '-') {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid language tag at offset %d", i)
+			}
+			toks = append(toks, sparqlToken{kind: tokLang, val: s[i+1 : j], pos: i})
+			i = j
+		case c == '^' && strings.HasPrefix(s[i:], "^^"):
+			toks = append(toks, sparqlToken{kind: tokDatatype, val: "^^", pos: i})
+			i += 2
+		case isASCIIDigit(c) || (c == '.' && i+1 < len(s) && isASCIIDigit(s[i+1])):
+			kind, n := scanSparqlNumber(s[i:])
+			toks = append(toks, sparqlToken{kind: kind, val: s[i : i+n], pos: i})
+			i += n
+		case c == '_' && strings.HasPrefix(s[i:], "_:"):
+			j := i + 2
+			for j < len(s) && isSparqlNameChar(s[j]) {
+				j++
+			}
+			toks = append(toks, sparqlToken{kind: tokBnode, val: s[i+2 : j], pos: i})
+			i = j
+		case c == ':' || isSparqlNameStart(s[i:]):
+			j := i
+			for j < len(s) {
+				r, size := utf8.DecodeRuneInString(s[j:])
+				if r == ':' || r == '_' || r == '-' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+					j += size
+					continue
+				}
+				break
+			}
+			for j > i && s[j-1] == '.' {
+				j--
+			}
+			word := s[i:j]
+			if strings.Contains(word, ":") {
+				toks = append(toks, sparqlToken{kind: tokPName, val: word, pos: i})
+			} else {
+				toks = append(toks, sparqlToken{kind: tokWord, val: word, pos: i})
+			}
+			i = j
+		default:
+			for _, op := range []string{"!=", ">=", "&&", "||"} {
+				if strings.HasPrefix(s[i:], op) {
+					toks = append(toks, sparqlToken{kind: tokPunct, val: op, pos: i})
+					i += 2
+					goto next
+				}
+			}
+			if strings.IndexByte("{}()[].;,*=!<>+-/", c) < 0 {
+				return nil, fmt.Errorf("unexpected character '%c' at offset %d", c, i)
+			}
+			toks = append(toks, sparqlToken{kind: tokPunct, val: string(c), pos: i})
+			i++
+		next:
+		}
+	}
+	toks = append(toks, sparqlToken{kind: tokEOF, pos: len(s)})
+	return toks, nil
+}
+
+// scanIRIRef returns the length of the IRI reference starting the string,
+// or 0 when the leading '<' is a comparison operator
+func scanIRIRef(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '>':
+			return i + 1
+		case ' ', '\t', '\n', '\r', '<', '"', '{', '}', '|', '^', '`', '\\':
+			return 0
+		}
+	}
+	return 0
+}
+
+func scanSparqlString(s string) (string, int, error) {
+	quote := s[:1]
+	long := strings.Repeat(quote, 3)
+	if strings.HasPrefix(s, long) {
+		end := strings.Index(s[3:], long)
+		if end < 0 {
+			return "", 0, errors.New("unterminated long string")
+		}
+		val, err := unescapeSparqlString(s[3 : 3+end])
+		return val, end + 6, err
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\n', '\r':
+			return "", 0, errors.New("new line in string")
+		case quote[0]:
+			val, err := unescapeSparqlString(s[1:i])
+			return val, i + 1, err
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+func unescapeSparqlString(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+	var out []rune
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			out = append(out, r)
+			i += size - 1
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", errors.New("invalid escape at end of string")
+		}
+		i++
+		switch s[i] {
+		case 't':
+			out = append(out, '\t')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '"', '\'', '\\':
+			out = append(out, rune(s[i]))
+		case 'u', 'U':
+			size := 4
+			if s[i] == 'U' {
+				size = 8
+			}
+			if i+1+size > len(s) {
+				return "", errors.New("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid unicode escape: %s", err)
+			}
+			out = append(out, rune(code))
+			i += size
+		default:
+			return "", fmt.Errorf("invalid escape '\\%c'", s[i])
+		}
+	}
+	return string(out), nil
+}
+
+func scanSparqlNumber(s string) (sparqlTokenKind, int) {
+	kind := tokInteger
+	i := 0
+	for i < len(s) && isASCIIDigit(s[i]) {
+		i++
+	}
+	if i < len(s) && s[i] == '.' && i+1 < len(s) && isASCIIDigit(s[i+1]) {
+		kind = tokDecimal
+		i++
+		for i < len(s) && isASCIIDigit(s[i]) {
+			i++
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isASCIIDigit(s[j]) {
+			for j < len(s) && isASCIIDigit(s[j]) {
+				j++
+			}
+			kind, i = tokDouble, j
+		}
+	}
+	return kind, i
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSparqlNameChar(c byte) bool {
+	return isASCIILetter(c) || isASCIIDigit(c) || c == '_' || c == '-' || c >= 0x80
+}
+
+func isSparqlNameStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r)
+}
+
+type sparqlParser struct {
+	toks       []sparqlToken
+	pos        int
+	prefixes   map[string]string
+	base       string
+	bnodeCount int
+}
+
+func newSparqlParser(toks []sparqlToken, c *Context) *sparqlParser {
+	p := &sparqlParser{toks: toks, prefixes: make(map[string]string)}
+	if c != nil {
+		p.base = c.Base
+		for k, v := range c.Prefixes {
+			p.prefixes[k] = v
+		}
+	}
+	return p
+}
+
+func (p *sparqlParser) peek() sparqlToken {
+	return p.toks[p.pos]
+}
+
+func (p *sparqlParser) next() sparqlToken {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *sparqlParser) isPunct(val string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.val == val
+}
+
+func (p *sparqlParser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokWord && strings.EqualFold(t.val, kw)
+}
+
+func (p *sparqlParser) acceptPunct(val string) bool {
+	if p.isPunct(val) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sparqlParser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sparqlParser) expectPunct(val string) error {
+	if !p.acceptPunct(val) {
+		return fmt.Errorf("expected '%s', got %s", val, p.peek())
+	}
+	return nil
+}
+
+func (p *sparqlParser) parseQuery() (*SparqlQuery, error) {
+	if err := p.parsePrologue(); err != nil {
+		return nil, err
+	}
+
+	q := &SparqlQuery{limit: -1}
+	switch {
+	case p.acceptKeyword(sparqlSelect):
+		q.form = sparqlSelect
+		if p.acceptKeyword("DISTINCT") {
+			q.distinct = true
+		} else {
+			p.acceptKeyword("REDUCED")
+		}
+		if !p.acceptPunct("*") {
+			for p.peek().kind == tokVar {
+				q.vars = append(q.vars, p.next().val)
+			}
+			if len(q.vars) == 0 {
+				return nil, fmt.Errorf("expected variables or '*' after SELECT, got %s", p.peek())
+			}
+		}
+	case p.acceptKeyword(sparqlAsk):
+		q.form = sparqlAsk
+	case p.acceptKeyword(sparqlConstruct):
+		q.form = sparqlConstruct
+		if err := p.expectPunct("{"); err != nil {
+			return nil, err
+		}
+		for !p.acceptPunct("}") {
+			if p.acceptPunct(".") {
+				continue
+			}
+			if err := p.parseTriplesSameSubject(&q.template); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("expected SELECT, ASK or CONSTRUCT, got %s", p.peek())
+	}
+
+	p.acceptKeyword("WHERE")
+	where, err := p.parseGroup()
+	if err != nil {
+		return nil, err
+	}
+	q.where = where
+
+	if err := p.parseSolutionModifiers(q); err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+	return q, nil
+}
+
+// parsePrologue parses the BASE and PREFIX declarations
+func (p *sparqlParser) parsePrologue() error {
+	for {
+		if p.acceptKeyword("BASE") {
+			t := p.next()
+			if t.kind != tokIRI {
+				return fmt.Errorf("expected IRI after BASE, got %s", t)
+			}
+			p.base = p.resolveIRI(t.val)
+		} else if p.acceptKeyword("PREFIX") {
+			t := p.next()
+			if t.kind != tokPName || !strings.HasSuffix(t.val, ":") {
+				return fmt.Errorf("expected prefix name after PREFIX, got %s", t)
+			}
+			iri := p.next()
+			if iri.kind != tokIRI {
+				return fmt.Errorf("expected IRI after PREFIX %s, got %s", t.val, iri)
+			}
+			p.prefixes[strings.TrimSuffix(t.val, ":")] = p.resolveIRI(iri.val)
+		} else {
+			return nil
+		}
+	}
+}
+
+func (p *sparqlParser) parseSolutionModifiers(q *SparqlQuery) error {
+	if p.acceptKeyword("ORDER") {
+		if !p.acceptKeyword("BY") {
+			return fmt.Errorf("expected BY after ORDER, got %s", p.peek())
+		}
+		for {
+			var cond orderCondition
+			switch {
+			case p.isKeyword("ASC") || p.isKeyword("DESC"):
+				cond.descending = p.isKeyword("DESC")
+				p.next()
+				if err := p.expectPunct("("); err != nil {
+					return err
+				}
+				expr, err := p.parseExpression()
+				if err != nil {
+					return err
+				}
+				if err := p.expectPunct(")"); err != nil {
+					return err
+				}
+				cond.expr = expr
+			case p.peek().kind == tokVar:
+				cond.expr = varExpr{name: p.next().val}
+			case p.isPunct("("), p.peek().kind == tokWord && p.toks[p.pos+1].val == "(":
+				expr, err := p.parsePrimaryExpression()
+				if err != nil {
+					return err
+				}
+				cond.expr = expr
+			default:
+				if len(q.orderBy) == 0 {
+					return fmt.Errorf("expected order condition, got %s", p.peek())
+				}
+			}
+			if cond.expr == nil {
+				break
+			}
+			q.orderBy = append(q.orderBy, cond)
+		}
+	}
+
+	for {
+		switch {
+		case p.acceptKeyword("LIMIT"):
+			n, err := p.parseNonNegativeInteger()
+			if err != nil {
+				return err
+			}
+			q.limit = n
+		case p.acceptKeyword("OFFSET"):
+			n, err := p.parseNonNegativeInteger()
+			if err != nil {
+				return err
+			}
+			q.offset = n
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *sparqlParser) parseNonNegativeInteger() (int, error) {
+	t := p.next()
+	if t.kind != tokInteger {
+		return 0, fmt.Errorf("expected integer, got %s", t)
+	}
+	return strconv.Atoi(t.val)
+}
+
+func (p *sparqlParser) parseGroup() (*groupPattern, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+
+	group := new(groupPattern)
+	addPatterns := func(patterns []TriplePattern) {
+		if n := len(group.elems); n > 0 {
+			if bgp, ok := group.elems[n-1].(*bgpElem); ok {
+				bgp.patterns = append(bgp.patterns, patterns...)
+				return
+			}
+		}
+		group.elems = append(group.elems, &bgpElem{patterns: patterns})
+	}
+
+	for {
+		switch {
+		case p.acceptPunct("}"):
+			return group, nil
+		case p.acceptPunct("."):
+		case p.acceptKeyword("OPTIONAL"):
+			inner, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			group.elems = append(group.elems, &optionalElem{group: inner})
+		case p.acceptKeyword("FILTER"):
+			var expr sparqlExpr
+			var err error
+			if p.isPunct("(") {
+				p.next()
+				if expr, err = p.parseExpression(); err == nil {
+					err = p.expectPunct(")")
+				}
+			} else {
+				expr, err = p.parsePrimaryExpression()
+			}
+			if err != nil {
+				return nil, err
+			}
+			group.filters = append(group.filters, expr)
+		case p.isPunct("{"):
+			union := new(unionElem)
+			for {
+				inner, err := p.parseGroup()
+				if err != nil {
+					return nil, err
+				}
+				union.groups = append(union.groups, inner)
+				if !p.acceptKeyword("UNION") {
+					break
+				}
+			}
+			group.elems = append(group.elems, union)
+		case p.peek().kind == tokEOF:
+			return nil, errors.New("unexpected end of query: missing '}'")
+		default:
+			var patterns []TriplePattern
+			if err := p.parseTriplesSameSubject(&patterns); err != nil {
+				return nil, err
+			}
+			for i, tp := range patterns {
+				patterns[i] = bnodesAsVariables(tp)
+			}
+			addPatterns(patterns)
+		}
+	}
+}
+
+// Blank nodes in graph patterns behave as variables that cannot be projected
+func bnodesAsVariables(tp TriplePattern) TriplePattern {
+	convert := func(t Term) Term {
+		if !t.IsVar() && t.value.isBnode {
+			return Var("_:" + t.value.bnode)
+		}
+		return t
+	}
+	return TriplePattern{Subject: convert(tp.Subject), Predicate: tp.Predicate, Object: convert(tp.Object)}
+}
+
+func (p *sparqlParser) parseTriplesSameSubject(dst *[]TriplePattern) error {
+	sub, err := p.parseTerm()
+	if err != nil {
+		return err
+	}
+	if !sub.IsVar() && sub.value.isLit {
+		return fmt.Errorf("literal cannot be a subject")
+	}
+
+	for {
+		var pred Term
+		if p.acceptKeyword("a") {
+			pred = Res(p.expandPName("rdf:type"))
+		} else {
+			if pred, err = p.parseTerm(); err != nil {
+				return err
+			}
+			if !pred.IsVar() && (pred.value.isLit || pred.value.isBnode) {
+				return fmt.Errorf("predicate must be an IRI or a variable")
+			}
+		}
+
+		for {
+			obj, err := p.parseTerm()
+			if err != nil {
+				return err
+			}
+			*dst = append(*dst, TriplePattern{Subject: sub, Predicate: pred, Object: obj})
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+
+		if !p.acceptPunct(";") {
+			return nil
+		}
+		for p.acceptPunct(";") {
+		}
+		if p.isPunct(".") || p.isPunct("}") {
+			return nil
+		}
+	}
+}
+
+func (p *sparqlParser) parseTerm() (Term, error) {
+	t := p.next()
+	switch t.kind {
+	case tokVar:
+		return Var(t.val), nil
+	case tokIRI:
+		return Res(p.resolveIRI(t.val)), nil
+	case tokPName:
+		return Res(p.expandPName(t.val)), nil
+	case tokBnode:
+		return Val(Bnode(t.val)), nil
+	case tokString, tokInteger, tokDecimal, tokDouble:
+		p.pos--
+		lit, err := p.parseLiteral()
+		return Term{value: lit}, err
+	case tokWord:
+		switch strings.ToLower(t.val) {
+		case "true":
+			return Val(BooleanLiteral(true)), nil
+		case "false":
+			return Val(BooleanLiteral(false)), nil
+		}
+	case tokPunct:
+		switch t.val {
+		case "[":
+			if err := p.expectPunct("]"); err != nil {
+				return Term{}, err
+			}
+			p.bnodeCount++
+			return Val(Bnode(fmt.Sprintf("anon%d", p.bnodeCount))), nil
+		case "-", "+":
+			if n := p.peek().kind; n == tokInteger || n == tokDecimal || n == tokDouble {
+				lit, err := p.parseLiteral()
+				if t.val == "-" {
+					lit.lit.val = "-" + lit.lit.val
+				}
+				return Term{value: lit}, err
+			}
+		}
+	}
+	return Term{}, fmt.Errorf("unexpected %s", t)
+}
+
+func (p *sparqlParser) parseLiteral() (object, error) {
+	t := p.next()
+	switch t.kind {
+	case tokInteger:
+		return object{isLit: true, lit: literal{typ: XsdInteger, val: t.val}}, nil
+	case tokDecimal:
+		return object{isLit: true, lit: literal{typ: XsdDecimal, val: t.val}}, nil
+	case tokDouble:
+		return object{isLit: true, lit: literal{typ: XsdDouble, val: t.val}}, nil
+	case tokString:
+		lit := literal{typ: XsdString, val: t.val}
+		switch p.peek().kind {
+		case tokLang:
+			lit.langtag = p.next().val
+		case tokDatatype:
+			p.next()
+			dt := p.next()
+			switch dt.kind {
+			case tokIRI:
+				lit.typ = xsdTypeFromIRI(p.resolveIRI(dt.val))
+			case tokPName:
+				lit.typ = xsdTypeFromIRI(p.expandPName(dt.val))
+			default:
+				return object{}, fmt.Errorf("expected datatype IRI, got %s", dt)
+			}
+		}
+		return object{isLit: true, lit: lit}, nil
+	}
+	return object{}, fmt.Errorf("expected literal, got %s", t)
+}
+
+func (p *sparqlParser) expandPName(pname string) string {
+	splits := strings.SplitN(pname, ":", 2)
+	if ns, ok := p.prefixes[splits[0]]; ok {
+		return ns + splits[1]
+	}
+	return pname
+}
+
+func (p *sparqlParser) resolveIRI(iri string) string {
+	if p.base == "" {
+		return iri
+	}
+	return resolveIRI(p.base, iri)
+}
+
+func (p *sparqlParser) parseExpression() (sparqlExpr, error) {
+	left, err := p.parseAndExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptPunct("||") {
+		right, err := p.parseAndExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sparqlParser) parseAndExpression() (sparqlExpr, error) {
+	left, err := p.parseRelationalExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptPunct("&&") {
+		right, err := p.parseRelationalExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sparqlParser) parseRelationalExpression() (sparqlExpr, error) {
+	left, err := p.parseAdditiveExpression()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "!=", "<", ">", "<=", ">="} {
+		if p.acceptPunct(op) {
+			right, err := p.parseAdditiveExpression()
+			if err != nil {
+				return nil, err
+			}
+			return binaryExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *sparqlParser) parseAdditiveExpression() (sparqlExpr, error) {
+	left, err := p.parseMultiplicativeExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("+") || p.isPunct("-") {
+		op := p.next().val
+		right, err := p.parseMultiplicativeExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sparqlParser) parseMultiplicativeExpression() (sparqlExpr, error) {
+	left, err := p.parseUnaryExpression()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("*") || p.isPunct("/") {
+		op := p.next().val
+		right, err := p.parseUnaryExpression()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sparqlParser) parseUnaryExpression() (sparqlExpr, error) {
+	if p.isPunct("!") || p.isPunct("-") || p.isPunct("+") {
+		op := p.next().val
+		expr, err := p.parseUnaryExpression()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: op, expr: expr}, nil
+	}
+	return p.parsePrimaryExpression()
+}
+
+func (p *sparqlParser) parsePrimaryExpression() (sparqlExpr, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokPunct:
+		if t.val == "(" {
+			p.next()
+			expr, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expectPunct(")")
+		}
+	case tokVar:
+		p.next()
+		return varExpr{name: t.val}, nil
+	case tokIRI:
+		p.next()
+		return constExpr{val: object{resource: p.resolveIRI(t.val)}}, nil
+	case tokPName:
+		p.next()
+		return constExpr{val: object{resource: p.expandPName(t.val)}}, nil
+	case tokString, tokInteger, tokDecimal, tokDouble:
+		lit, err := p.parseLiteral()
+		return constExpr{val: lit}, err
+	case tokWord:
+		switch strings.ToLower(t.val) {
+		case "true":
+			p.next()
+			return constExpr{val: BooleanLiteral(true).(object)}, nil
+		case "false":
+			p.next()
+			return constExpr{val: BooleanLiteral(false).(object)}, nil
+		}
+		return p.parseFunctionCall()
+	}
+	return nil, fmt.Errorf("unexpected %s in expression", t)
+}
+
+func (p *sparqlParser) parseFunctionCall() (sparqlExpr, error) {
+	t := p.next()
+	name := strings.ToUpper(t.val)
+	arity, ok := sparqlFunctionArities[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported function %s", t)
+	}
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+
+	call := callExpr{name: name}
+	if !p.acceptPunct(")") {
+		for {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(call.args) < arity[0] || len(call.args) > arity[1] {
+		return nil, fmt.Errorf("function %s: wrong number of arguments: %d", name, len(call.args))
+	}
+	if name == "BOUND" {
+		if _, ok := call.args[0].(varExpr); !ok {
+			return nil, errors.New("function BOUND: argument must be a variable")
+		}
+	}
+	return call, nil
+}
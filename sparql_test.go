@@ -0,0 +1,273 @@
+package triplestore_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func sparqlTestGraph() tstore.RDFGraph {
+	s := tstore.NewSource()
+	s.Add(
+		tstore.SubjPred("alice", "rdf:type").Resource("Person"),
+		tstore.SubjPred("alice", "name").StringLiteralWithLang("Alice", "en"),
+		tstore.SubjPred("alice", "age").IntegerLiteral(42),
+		tstore.SubjPred("alice", "worksAt").Resource("acme"),
+		tstore.SubjPred("bob", "rdf:type").Resource("Person"),
+		tstore.SubjPred("bob", "name").StringLiteral("Bob"),
+		tstore.SubjPred("bob", "age").IntegerLiteral(25),
+		tstore.SubjPred("carol", "rdf:type").Resource("Person"),
+		tstore.SubjPred("carol", "name").StringLiteralWithLang("Carole", "fr"),
+		tstore.SubjPred("carol", "age").IntegerLiteral(35),
+		tstore.SubjPred("carol", "worksAt").Resource("initech"),
+		tstore.SubjPred("acme", "city").StringLiteral("Paris"),
+		tstore.SubjPred("initech", "city").StringLiteral("London"),
+		tstore.SubjPred("acme", "rdf:type").Resource("Company"),
+	)
+	return s.Snapshot()
+}
+
+func evalSparql(t *testing.T, g tstore.RDFGraph, query string) *tstore.SparqlResult {
+	q, err := tstore.ParseSparql(query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := q.Eval(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func columnValues(res *tstore.SparqlResult, name string) (out []string) {
+	for _, b := range res.Bindings {
+		val, ok := b[name]
+		if !ok {
+			out = append(out, "")
+			continue
+		}
+		if lit, ok := val.Literal(); ok {
+			out = append(out, lit.Value())
+		} else if bnode, ok := val.Bnode(); ok {
+			out = append(out, "_:"+bnode)
+		} else {
+			res, _ := val.Resource()
+			out = append(out, res)
+		}
+	}
+	return
+}
+
+func TestSparqlSelect(t *testing.T) {
+	g := sparqlTestGraph()
+
+	tcases := []struct {
+		query string
+		col   string
+		exp   []string
+	}{
+		{
+			query: `SELECT ?p WHERE { ?p a <Person> } ORDER BY ?p`,
+			col:   "p", exp: []string{"alice", "bob", "carol"},
+		},
+		{
+			query: `SELECT ?p WHERE { ?p <age> ?age . FILTER(?age > 30) } ORDER BY DESC(?age)`,
+			col:   "p", exp: []string{"alice", "carol"},
+		},
+		{
+			query: `SELECT ?p WHERE { ?p <age> ?age FILTER(?age >= 25 && ?age < 40) } ORDER BY ?age`,
+			col:   "p", exp: []string{"bob", "carol"},
+		},
+		{
+			query: `SELECT ?p WHERE { ?p <age> ?age FILTER(?age * 2 = 84) }`,
+			col:   "p", exp: []string{"alice"},
+		},
+		{
+			query: `SELECT ?p ?city WHERE { ?p a <Person> OPTIONAL { ?p <worksAt> ?org . ?org <city> ?city } } ORDER BY ?p`,
+			col:   "city", exp: []string{"Paris", "", "London"},
+		},
+		{
+			query: `SELECT ?p WHERE { ?p a <Person> OPTIONAL { ?p <worksAt> ?org } FILTER(!BOUND(?org)) }`,
+			col:   "p", exp: []string{"bob"},
+		},
+		{
+			query: `SELECT ?x WHERE { { ?x a <Company> } UNION { ?x <age> 25 } } ORDER BY ?x`,
+			col:   "x", exp: []string{"acme", "bob"},
+		},
+		{
+			query: `SELECT ?n WHERE { ?p <name> ?n FILTER regex(?n, "^car", "i") }`,
+			col:   "n", exp: []string{"Carole"},
+		},
+		{
+			query: `SELECT ?n WHERE { ?p <name> ?n FILTER(lang(?n) = "en") }`,
+			col:   "n", exp: []string{"Alice"},
+		},
+		{
+			query: `PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+			        SELECT ?p WHERE { ?p ?pred ?v FILTER(datatype(?v) = xsd:integer && ?v < 30) }`,
+			col: "p", exp: []string{"bob"},
+		},
+		{
+			query: `SELECT DISTINCT ?type WHERE { ?x a ?type } ORDER BY ?type`,
+			col:   "type", exp: []string{"Company", "Person"},
+		},
+		{
+			query: `SELECT ?p WHERE { ?p a <Person> } ORDER BY ?p LIMIT 1 OFFSET 1`,
+			col:   "p", exp: []string{"bob"},
+		},
+		{
+			query: `SELECT * WHERE { ?p <worksAt> [] ; <age> ?age } ORDER BY ?age`,
+			col:   "p", exp: []string{"carol", "alice"},
+		},
+	}
+
+	for i, tcase := range tcases {
+		res := evalSparql(t, g, tcase.query)
+		if got, want := columnValues(res, tcase.col), tcase.exp; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%d: got %v, want %v", i+1, got, want)
+		}
+	}
+
+	t.Run("projection", func(t *testing.T) {
+		res := evalSparql(t, g, `SELECT ?p WHERE { ?p <age> ?age }`)
+		if got, want := res.Vars, []string{"p"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		for _, b := range res.Bindings {
+			if _, ok := b["age"]; ok {
+				t.Fatalf("unexpected non projected variable in %v", b)
+			}
+		}
+		res = evalSparql(t, g, `SELECT * WHERE { ?p <age> ?age }`)
+		if got, want := res.Vars, []string{"p", "age"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSparqlPrefixesFromContext(t *testing.T) {
+	s := tstore.NewSource()
+	s.Add(
+		tstore.SubjPred("http://ex.org/alice", "http://www.w3.org/1999/02/22-rdf-syntax-ns#type").Resource("http://ex.org/Person"),
+	)
+	g := s.Snapshot()
+
+	c := tstore.NewContext()
+	c.Prefixes["rdf"] = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	c.Base = "http://ex.org/"
+	q, err := tstore.ParseSparql(`PREFIX ex: <http://ex.org/> SELECT ?x WHERE { ?x rdf:type ex:Person . ?x a <Person> }`, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := q.Eval(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := columnValues(res, "x"), []string{"http://ex.org/alice"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestSparqlAskAndConstruct(t *testing.T) {
+	g := sparqlTestGraph()
+
+	if res := evalSparql(t, g, `ASK { <alice> <worksAt> <acme> }`); !res.Boolean {
+		t.Fatal("expected true")
+	}
+	if res := evalSparql(t, g, `ASK WHERE { ?p <age> ?a FILTER(?a > 100) }`); res.Boolean {
+		t.Fatal("expected false")
+	}
+
+	res := evalSparql(t, g, `CONSTRUCT { ?p <livesIn> ?city . ?p <badge> _:b } WHERE { ?p <worksAt> ?o . ?o <city> ?city }`)
+	if got, want := len(res.Triples), 4; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	for _, exp := range []tstore.Triple{
+		tstore.SubjPred("alice", "livesIn").StringLiteral("Paris"),
+		tstore.SubjPred("carol", "livesIn").StringLiteral("London"),
+	} {
+		if !containsTriple(res.Triples, exp) {
+			t.Fatalf("expected %v in %v", exp, res.Triples)
+		}
+	}
+	badges := make(map[string]bool)
+	for _, tri := range res.Triples {
+		if bnode, ok := tri.Object().Bnode(); ok {
+			badges[bnode] = true
+		}
+	}
+	if got, want := len(badges), 2; got != want {
+		t.Fatalf("expected fresh blank node per solution: got %d, want %d", got, want)
+	}
+}
+
+func TestSparqlDecimals(t *testing.T) {
+	tris, err := tstore.NewTurtleDecoder(strings.NewReader(`<book> <price> 1.5 ; <pages> 300 ; <weight> 2.5e2 .`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := tstore.NewSource()
+	s.Add(tris...)
+	g := s.Snapshot()
+
+	if res := evalSparql(t, g, `ASK { <book> <price> 1.5 }`); !res.Boolean {
+		t.Fatal("expected decimal in query to match decimal in data")
+	}
+	if res := evalSparql(t, g, `ASK { <book> <weight> 2.5e2 }`); !res.Boolean {
+		t.Fatal("expected double in query to match double in data")
+	}
+
+	tcases := []struct {
+		filter string
+		exp    bool
+	}{
+		{`DATATYPE(?price / 3) = xsd:decimal`, true},
+		{`DATATYPE(?pages / 7) = xsd:decimal`, true},
+		{`DATATYPE(?pages * 2) = xsd:integer`, true},
+		{`DATATYPE(?price + ?pages) = xsd:decimal`, true},
+		{`DATATYPE(?price * ?weight) = xsd:double`, true},
+		{`?pages / 8 = 37.5`, true},
+		{`?price / 3 = 0.5`, true},
+	}
+	for i, tc := range tcases {
+		query := `PREFIX xsd: <http://www.w3.org/2001/XMLSchema#> ASK { <book> <price> ?price ; <pages> ?pages ; <weight> ?weight FILTER(` + tc.filter + `) }`
+		if got, want := evalSparql(t, g, query).Boolean, tc.exp; got != want {
+			t.Fatalf("case %d: %s: got %t, want %t", i+1, tc.filter, got, want)
+		}
+	}
+}
+
+func TestSparqlParseErrors(t *testing.T) {
+	tcases := []struct {
+		query, err string
+	}{
+		{query: `SELECT WHERE { ?s ?p ?o }`, err: "expected variables"},
+		{query: `SELECT ?s WHERE { ?s ?p ?o `, err: "missing '}'"},
+		{query: `DESCRIBE ?s`, err: "expected SELECT, ASK or CONSTRUCT"},
+		{query: `SELECT ?s WHERE { ?s ?p ?o FILTER(unknown(?o)) }`, err: "unsupported function"},
+		{query: `SELECT ?s WHERE { "lit" ?p ?o }`, err: "literal cannot be a subject"},
+		{query: `SELECT ?s WHERE { ?s ?p "unterminated }`, err: "unterminated string"},
+		{query: `SELECT ?s WHERE { ?s ?p ?o } LIMIT x`, err: "expected integer"},
+	}
+
+	for i, tcase := range tcases {
+		_, err := tstore.ParseSparql(tcase.query, nil)
+		if err == nil {
+			t.Fatalf("%d: expected error", i+1)
+		}
+		if !strings.Contains(err.Error(), tcase.err) {
+			t.Fatalf("%d: got %s, want %s", i+1, err, tcase.err)
+		}
+	}
+}
+
+func containsTriple(tris []tstore.Triple, exp tstore.Triple) bool {
+	for _, tri := range tris {
+		if tri.Equal(exp) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,640 @@
+package triplestore
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Eval evaluates the query against the given graph
+func (q *SparqlQuery) Eval(g RDFGraph) (*SparqlResult, error) {
+	solutions := q.where.eval(g, []Binding{{}})
+
+	if q.form == sparqlAsk {
+		return &SparqlResult{Boolean: len(solutions) > 0}, nil
+	}
+
+	if len(q.orderBy) > 0 {
+		sort.SliceStable(solutions, func(i, j int) bool {
+			for _, cond := range q.orderBy {
+				left, _ := cond.expr.eval(solutions[i])
+				right, _ := cond.expr.eval(solutions[j])
+				cmp := orderTerms(left, right)
+				if cmp == 0 {
+					continue
+				}
+				if cond.descending {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	if q.form == sparqlConstruct {
+		return &SparqlResult{Triples: q.construct(sliceSolutions(solutions, q.offset, q.limit))}, nil
+	}
+
+	vars := q.vars
+	if vars == nil {
+		vars = q.where.variables()
+	}
+
+	projected := make([]Binding, 0, len(solutions))
+	seen := make(map[string]bool)
+	for _, sol := range solutions {
+		b := make(Binding, len(vars))
+		var key []string
+		for _, v := range vars {
+			if val, ok := sol[v]; ok {
+				b[v] = val
+				key = append(key, val.(object).key())
+			} else {
+				key = append(key, "")
+			}
+		}
+		if q.distinct {
+			k := strings.Join(key, "\x00")
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		projected = append(projected, b)
+	}
+
+	return &SparqlResult{Vars: vars, Bindings: sliceSolutions(projected, q.offset, q.limit)}, nil
+}
+
+func sliceSolutions(solutions []Binding, offset, limit int) []Binding {
+	if offset >= len(solutions) {
+		return nil
+	}
+	solutions = solutions[offset:]
+	if limit >= 0 && limit < len(solutions) {
+		solutions = solutions[:limit]
+	}
+	return solutions
+}
+
+func (q *SparqlQuery) construct(solutions []Binding) (out []Triple) {
+	seen := make(map[string]bool)
+	for i, sol := range solutions {
+		instantiate := func(t Term) (object, bool) {
+			if t.IsVar() {
+				val, ok := sol[t.variable]
+				if !ok {
+					return object{}, false
+				}
+				return val.(object), true
+			}
+			if t.value.isBnode {
+				return object{bnode: fmt.Sprintf("%s_%d", t.value.bnode, i), isBnode: true}, true
+			}
+			return t.value, true
+		}
+
+		for _, tp := range q.template {
+			s, sok := instantiate(tp.Subject)
+			p, pok := instantiate(tp.Predicate)
+			o, ook := instantiate(tp.Object)
+			if !sok || !pok || !ook || s.isLit || p.isLit || p.isBnode {
+				continue
+			}
+			tri := &triple{sub: subjectString(s), isSubBnode: s.isBnode, pred: p.resource, obj: o}
+			if seen[tri.key()] {
+				continue
+			}
+			seen[tri.key()] = true
+			out = append(out, tri)
+		}
+	}
+	return
+}
+
+func (gp *groupPattern) eval(g RDFGraph, seeds []Binding) []Binding {
+	solutions := seeds
+	for _, elem := range gp.elems {
+		switch e := elem.(type) {
+		case *bgpElem:
+			solutions = evalBGP(g, e.patterns, solutions)
+		case *optionalElem:
+			var next []Binding
+			for _, sol := range solutions {
+				if extended := e.group.eval(g, []Binding{sol}); len(extended) > 0 {
+					next = append(next, extended...)
+				} else {
+					next = append(next, sol)
+				}
+			}
+			solutions = next
+		case *unionElem:
+			var next []Binding
+			for _, branch := range e.groups {
+				next = append(next, branch.eval(g, solutions)...)
+			}
+			solutions = next
+		}
+	}
+
+	if len(gp.filters) == 0 {
+		return solutions
+	}
+
+	var filtered []Binding
+	for _, sol := range solutions {
+		keep := true
+		for _, f := range gp.filters {
+			if ok, err := effectiveBooleanValue(f, sol); err != nil || !ok {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			filtered = append(filtered, sol)
+		}
+	}
+	return filtered
+}
+
+// variables returns the projectable variables of the group in order of appearance
+func (gp *groupPattern) variables() (out []string) {
+	seen := make(map[string]bool)
+	var walk func(*groupPattern)
+	walk = func(group *groupPattern) {
+		for _, elem := range group.elems {
+			switch e := elem.(type) {
+			case *bgpElem:
+				for _, tp := range e.patterns {
+					for _, t := range tp.terms() {
+						if t.IsVar() && !strings.HasPrefix(t.variable, "_:") && !seen[t.variable] {
+							seen[t.variable] = true
+							out = append(out, t.variable)
+						}
+					}
+				}
+			case *optionalElem:
+				walk(e.group)
+			case *unionElem:
+				for _, branch := range e.groups {
+					walk(branch)
+				}
+			}
+		}
+	}
+	walk(gp)
+	return
+}
+
+type sparqlExpr interface {
+	eval(Binding) (object, error)
+}
+
+var errUnboundVariable = errors.New("unbound variable")
+
+type varExpr struct {
+	name string
+}
+
+func (e varExpr) eval(b Binding) (object, error) {
+	if val, ok := b[e.name]; ok {
+		return val.(object), nil
+	}
+	return object{}, errUnboundVariable
+}
+
+type constExpr struct {
+	val object
+}
+
+func (e constExpr) eval(Binding) (object, error) {
+	return e.val, nil
+}
+
+type unaryExpr struct {
+	op   string
+	expr sparqlExpr
+}
+
+func (e unaryExpr) eval(b Binding) (object, error) {
+	if e.op == "!" {
+		ok, err := effectiveBooleanValue(e.expr, b)
+		if err != nil {
+			return object{}, err
+		}
+		return BooleanLiteral(!ok).(object), nil
+	}
+
+	val, err := e.expr.eval(b)
+	if err != nil {
+		return object{}, err
+	}
+	num, typ, ok := numericValue(val)
+	if !ok {
+		return object{}, fmt.Errorf("unary %s: not a number", e.op)
+	}
+	if e.op == "-" {
+		num.Neg(num)
+	}
+	return numericLiteral(num, typ), nil
+}
+
+type binaryExpr struct {
+	op          string
+	left, right sparqlExpr
+}
+
+func (e binaryExpr) eval(b Binding) (object, error) {
+	switch e.op {
+	case "||", "&&":
+		left, lerr := effectiveBooleanValue(e.left, b)
+		right, rerr := effectiveBooleanValue(e.right, b)
+		short := e.op == "||"
+		if (lerr == nil && left == short) || (rerr == nil && right == short) {
+			return BooleanLiteral(short).(object), nil
+		}
+		if lerr != nil {
+			return object{}, lerr
+		}
+		if rerr != nil {
+			return object{}, rerr
+		}
+		return BooleanLiteral(!short).(object), nil
+	}
+
+	left, err := e.left.eval(b)
+	if err != nil {
+		return object{}, err
+	}
+	right, err := e.right.eval(b)
+	if err != nil {
+		return object{}, err
+	}
+
+	switch e.op {
+	case "=", "!=":
+		equal, err := equalTerms(left, right)
+		if err != nil {
+			return object{}, err
+		}
+		return BooleanLiteral(equal == (e.op == "=")).(object), nil
+	case "<", ">", "<=", ">=":
+		cmp, err := compareTerms(left, right)
+		if err != nil {
+			return object{}, err
+		}
+		var res bool
+		switch e.op {
+		case "<":
+			res = cmp < 0
+		case ">":
+			res = cmp > 0
+		case "<=":
+			res = cmp <= 0
+		case ">=":
+			res = cmp >= 0
+		}
+		return BooleanLiteral(res).(object), nil
+	}
+
+	lnum, ltyp, lok := numericValue(left)
+	rnum, rtyp, rok := numericValue(right)
+	if !lok || !rok {
+		return object{}, fmt.Errorf("operator %s: operands are not numbers", e.op)
+	}
+	res := new(big.Rat)
+	switch e.op {
+	case "+":
+		res.Add(lnum, rnum)
+	case "-":
+		res.Sub(lnum, rnum)
+	case "*":
+		res.Mul(lnum, rnum)
+	case "/":
+		if rnum.Sign() == 0 {
+			return object{}, errors.New("division by zero")
+		}
+		// the division of integers is a decimal
+		return numericLiteral(res.Quo(lnum, rnum), promoteNumeric(promoteNumeric(ltyp, rtyp), XsdDecimal)), nil
+	}
+	return numericLiteral(res, promoteNumeric(ltyp, rtyp)), nil
+}
+
+type callExpr struct {
+	name string
+	args []sparqlExpr
+}
+
+var sparqlFunctionArities = map[string][2]int{
+	"BOUND":       {1, 1},
+	"STR":         {1, 1},
+	"LANG":        {1, 1},
+	"DATATYPE":    {1, 1},
+	"REGEX":       {2, 3},
+	"LANGMATCHES": {2, 2},
+	"SAMETERM":    {2, 2},
+	"ISIRI":       {1, 1},
+	"ISURI":       {1, 1},
+	"ISBLANK":     {1, 1},
+	"ISLITERAL":   {1, 1},
+}
+
+func (e callExpr) eval(b Binding) (object, error) {
+	if e.name == "BOUND" {
+		_, ok := b[e.args[0].(varExpr).name]
+		return BooleanLiteral(ok).(object), nil
+	}
+
+	var args []object
+	for _, arg := range e.args {
+		val, err := arg.eval(b)
+		if err != nil {
+			return object{}, err
+		}
+		args = append(args, val)
+	}
+
+	switch e.name {
+	case "STR":
+		if args[0].isBnode {
+			return object{}, errors.New("function STR: blank node argument")
+		}
+		if args[0].isLit {
+			return StringLiteral(args[0].lit.val).(object), nil
+		}
+		return StringLiteral(args[0].resource).(object), nil
+	case "LANG":
+		if !args[0].isLit {
+			return object{}, errors.New("function LANG: argument is not a literal")
+		}
+		return StringLiteral(args[0].lit.langtag).(object), nil
+	case "DATATYPE":
+		if !args[0].isLit {
+			return object{}, errors.New("function DATATYPE: argument is not a literal")
+		}
+		if args[0].lit.langtag != "" {
+			return object{resource: "rdf:langString"}, nil
+		}
+		return object{resource: compactIRI(string(args[0].lit.typ))}, nil
+	case "REGEX":
+		text, ok := stringValue(args[0])
+		if !ok {
+			return object{}, errors.New("function REGEX: text is not a string literal")
+		}
+		pattern, ok := stringValue(args[1])
+		if !ok {
+			return object{}, errors.New("function REGEX: pattern is not a string literal")
+		}
+		if len(args) == 3 {
+			flags, ok := stringValue(args[2])
+			if !ok || strings.Trim(flags, "ism") != "" {
+				return object{}, errors.New("function REGEX: invalid flags")
+			}
+			if flags != "" {
+				pattern = "(?" + flags + ")" + pattern
+			}
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return object{}, fmt.Errorf("function REGEX: %s", err)
+		}
+		return BooleanLiteral(re.MatchString(text)).(object), nil
+	case "LANGMATCHES":
+		tag, ok := stringValue(args[0])
+		rng, rok := stringValue(args[1])
+		if !ok || !rok {
+			return object{}, errors.New("function LANGMATCHES: arguments are not string literals")
+		}
+		tag, rng = strings.ToLower(tag), strings.ToLower(rng)
+		match := (rng == "*" && tag != "") || tag == rng || strings.HasPrefix(tag, rng+"-")
+		return BooleanLiteral(match).(object), nil
+	case "SAMETERM":
+		return BooleanLiteral(args[0].key() == args[1].key()).(object), nil
+	case "ISIRI", "ISURI":
+		return BooleanLiteral(!args[0].isLit && !args[0].isBnode).(object), nil
+	case "ISBLANK":
+		return BooleanLiteral(args[0].isBnode).(object), nil
+	case "ISLITERAL":
+		return BooleanLiteral(args[0].isLit).(object), nil
+	}
+	return object{}, fmt.Errorf("unsupported function %s", e.name)
+}
+
+func effectiveBooleanValue(e sparqlExpr, b Binding) (bool, error) {
+	val, err := e.eval(b)
+	if err != nil {
+		return false, err
+	}
+	if !val.isLit {
+		return false, errors.New("no effective boolean value for non literal")
+	}
+	if lit := normalizedLiteral(val); lit.lit.typ == XsdBoolean {
+		return ParseBoolean(lit)
+	}
+	if num, _, ok := numericValue(val); ok {
+		return num.Sign() != 0, nil
+	}
+	if s, ok := stringValue(val); ok {
+		return s != "", nil
+	}
+	return false, fmt.Errorf("no effective boolean value for type %s", val.lit.typ)
+}
+
+// normalizedLiteral returns the literal with a short XsdType when its datatype is a full XML schema IRI
+func normalizedLiteral(o object) object {
+	if o.isLit {
+		o.lit.typ = xsdTypeFromIRI(string(o.lit.typ))
+	}
+	return o
+}
+
+var standardNamespaces = []struct{ prefix, ns string }{
+	{"xsd:", "http://www.w3.org/2001/XMLSchema#"},
+	{"rdf:", "http://www.w3.org/1999/02/22-rdf-syntax-ns#"},
+	{"rdfs:", "http://www.w3.org/2000/01/rdf-schema#"},
+}
+
+// compactIRI shortens IRIs of standard vocabularies so that IRIs stored in
+// full and compact forms compare equal in expressions
+func compactIRI(iri string) string {
+	for _, std := range standardNamespaces {
+		if strings.HasPrefix(iri, std.ns) {
+			return std.prefix + strings.TrimPrefix(iri, std.ns)
+		}
+	}
+	return iri
+}
+
+func stringValue(o object) (string, bool) {
+	if o.isLit && normalizedLiteral(o).lit.typ == XsdString {
+		return o.lit.val, true
+	}
+	return "", false
+}
+
+// numericValue returns the exact value of a numeric literal and its numeric
+// type for arithmetic: XsdInteger, XsdDecimal or XsdDouble
+func numericValue(o object) (*big.Rat, XsdType, bool) {
+	if !o.isLit {
+		return nil, "", false
+	}
+	lit := normalizedLiteral(o)
+	if lit.lit.typ == XsdDecimal {
+		r, ok := new(big.Rat).SetString(lit.lit.val)
+		return r, XsdDecimal, ok
+	}
+
+	val, err := ParseLiteral(lit)
+	if err != nil {
+		return nil, "", false
+	}
+	r := new(big.Rat)
+	switch v := val.(type) {
+	case int:
+		return r.SetInt64(int64(v)), XsdInteger, true
+	case int8:
+		return r.SetInt64(int64(v)), XsdInteger, true
+	case int16:
+		return r.SetInt64(int64(v)), XsdInteger, true
+	case uint:
+		return r.SetUint64(uint64(v)), XsdInteger, true
+	case uint8:
+		return r.SetUint64(uint64(v)), XsdInteger, true
+	case uint16:
+		return r.SetUint64(uint64(v)), XsdInteger, true
+	case float32:
+		if _, ok := r.SetString(lit.lit.val); ok {
+			return r, XsdDouble, true
+		}
+	case float64:
+		if _, ok := r.SetString(lit.lit.val); ok {
+			return r, XsdDouble, true
+		}
+	}
+	return nil, "", false
+}
+
+// promoteNumeric returns the type of the result of an operation on
+// numbers of the given types: integer, then decimal, then double
+func promoteNumeric(a, b XsdType) XsdType {
+	switch {
+	case a == XsdDouble || b == XsdDouble:
+		return XsdDouble
+	case a == XsdDecimal || b == XsdDecimal:
+		return XsdDecimal
+	}
+	return XsdInteger
+}
+
+func numericLiteral(r *big.Rat, typ XsdType) object {
+	switch {
+	case typ == XsdInteger && r.IsInt():
+		return object{isLit: true, lit: literal{typ: XsdInteger, val: r.Num().String()}}
+	case typ == XsdInteger, typ == XsdDecimal:
+		return object{isLit: true, lit: literal{typ: XsdDecimal, val: decimalString(r)}}
+	}
+	f, _ := r.Float64()
+	return Float64Literal(f).(object)
+}
+
+// decimalString returns the decimal lexical form of the number, rounded
+// to 20 fractional digits for numbers without finite decimal expansion
+func decimalString(r *big.Rat) string {
+	s := strings.TrimRight(r.FloatString(20), "0")
+	if strings.HasSuffix(s, ".") {
+		s += "0"
+	}
+	return s
+}
+
+func equalTerms(a, b object) (bool, error) {
+	if a.isLit && b.isLit {
+		if cmp, err := compareTerms(a, b); err == nil {
+			return cmp == 0 && a.lit.langtag == b.lit.langtag, nil
+		}
+		return normalizedLiteral(a).key() == normalizedLiteral(b).key(), nil
+	}
+	if !a.isLit && !b.isLit && !a.isBnode && !b.isBnode {
+		return compactIRI(a.resource) == compactIRI(b.resource), nil
+	}
+	return a.key() == b.key(), nil
+}
+
+// compareTerms compares literals of compatible types using their typed values
+func compareTerms(a, b object) (int, error) {
+	if anum, _, ok := numericValue(a); ok {
+		if bnum, _, ok := numericValue(b); ok {
+			return anum.Cmp(bnum), nil
+		}
+		return 0, errors.New("cannot compare number with non number")
+	}
+
+	if as, ok := stringValue(a); ok {
+		if bs, ok := stringValue(b); ok {
+			return strings.Compare(as, bs), nil
+		}
+		return 0, errors.New("cannot compare string with non string")
+	}
+
+	aval, aerr := ParseLiteral(normalizedLiteral(a))
+	bval, berr := ParseLiteral(normalizedLiteral(b))
+	if aerr != nil || berr != nil {
+		return 0, errors.New("cannot compare non literals or unknown literal types")
+	}
+	switch av := aval.(type) {
+	case bool:
+		if bv, ok := bval.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, nil
+			case !av:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	case time.Time:
+		if bv, ok := bval.(time.Time); ok {
+			switch {
+			case av.Equal(bv):
+				return 0, nil
+			case av.Before(bv):
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", a.lit.typ, b.lit.typ)
+}
+
+// orderTerms totally orders terms for ORDER BY: unbound values first,
+// then blank nodes, IRIs and literals
+func orderTerms(a, b object) int {
+	rank := func(o object) int {
+		switch {
+		case o == object{}:
+			return 0
+		case o.isBnode:
+			return 1
+		case !o.isLit:
+			return 2
+		default:
+			return 3
+		}
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	if a.isLit {
+		if cmp, err := compareTerms(a, b); err == nil {
+			return cmp
+		}
+	}
+	return strings.Compare(a.key(), b.key())
+}
@@ -0,0 +1,142 @@
+package triplestore
+
+import "sort"
+
+// Statistics describes the content of a graph
+type Statistics struct {
+	Triples          int
+	DistinctSubjects int
+	DistinctObjects  int
+	// per predicate
+	Predicates map[string]PredicateStatistics
+	// number of triples per literal datatype, rdf:langString for literals with a language tag
+	Datatypes map[XsdType]int
+}
+
+// PredicateStatistics describes the triples with a given predicate
+type PredicateStatistics struct {
+	Triples          int
+	DistinctSubjects int
+	DistinctObjects  int
+}
+
+const (
+	voidNamespace = "http://rdfs.org/ns/void#"
+	rdfLangString = XsdType("rdf:langString")
+)
+
+// Stats computes the statistics of the graph. For snapshots, counts are read
+// from the indexes without rebuilding triples.
+func Stats(g RDFGraph) Statistics {
+	stats := Statistics{
+		Predicates: make(map[string]PredicateStatistics),
+		Datatypes:  make(map[XsdType]int),
+	}
+	if gph, ok := g.(*graph); ok {
+		gph.stats(&stats)
+		return stats
+	}
+
+	subjects := make(map[string]bool)
+	objects := make(map[string]bool)
+	predSubjects := make(map[string]map[string]bool)
+	predObjects := make(map[string]map[string]bool)
+	All(g)(func(t Triple) bool {
+		tt := t.(*triple)
+		stats.Triples++
+		subjects[tt.sub] = true
+		objects[tt.obj.key()] = true
+		if predSubjects[tt.pred] == nil {
+			predSubjects[tt.pred], predObjects[tt.pred] = make(map[string]bool), make(map[string]bool)
+		}
+		predSubjects[tt.pred][tt.sub] = true
+		predObjects[tt.pred][tt.obj.key()] = true
+		ps := stats.Predicates[tt.pred]
+		ps.Triples++
+		stats.Predicates[tt.pred] = ps
+		if tt.obj.isLit {
+			stats.Datatypes[literalDatatype(tt.obj.lit)]++
+		}
+		return true
+	})
+	stats.DistinctSubjects, stats.DistinctObjects = len(subjects), len(objects)
+	for pred, ps := range stats.Predicates {
+		ps.DistinctSubjects, ps.DistinctObjects = len(predSubjects[pred]), len(predObjects[pred])
+		stats.Predicates[pred] = ps
+	}
+	return stats
+}
+
+func (g *graph) stats(stats *Statistics) {
+	stats.Triples = g.spo.len()
+	stats.DistinctSubjects = g.s.len()
+	stats.DistinctObjects = g.o.len()
+
+	g.p.each(func(k idKey, set pset) {
+		subjects := make(map[uint32]bool)
+		objects := make(map[uint32]bool)
+		set.each(func(tk idKey) {
+			subjects[tk.a&^bnodeID] = true
+			objects[tk.c] = true
+		})
+		stats.Predicates[g.dict.name(k.b)] = PredicateStatistics{
+			Triples:          set.len(),
+			DistinctSubjects: len(subjects),
+			DistinctObjects:  len(objects),
+		}
+	})
+	g.o.each(func(k idKey, set pset) {
+		if obj := g.dict.object(k.c); obj.isLit {
+			stats.Datatypes[literalDatatype(obj.lit)] += set.len()
+		}
+	})
+}
+
+func literalDatatype(l literal) XsdType {
+	if l.langtag != "" {
+		return rdfLangString
+	}
+	return l.typ
+}
+
+// Cardinality returns the number of triples matching the given subject, predicate
+// and object, a nil component matching anything. For snapshots, it is read from
+// the size of the index entry.
+func Cardinality(g RDFGraph, s, p *string, o Object) int {
+	if gph, ok := g.(*graph); ok {
+		set, _ := gph.matching(s, p, o)
+		return set.len()
+	}
+	return g.Scan(s, p, o).Count()
+}
+
+// VoID describes the statistics as triples of the VoID vocabulary, the dataset
+// being identified by the given resource. Each predicate is described by a
+// property partition, a generated blank node not used by other descriptions.
+// Datatypes have no VoID equivalent and are not described.
+func (stats Statistics) VoID(dataset string) []Triple {
+	tris := []Triple{
+		SubjPred(dataset, RDFContext.Prefixes["rdf"]+"type").Resource(voidNamespace + "Dataset"),
+		SubjPred(dataset, voidNamespace+"triples").IntegerLiteral(stats.Triples),
+		SubjPred(dataset, voidNamespace+"distinctSubjects").IntegerLiteral(stats.DistinctSubjects),
+		SubjPred(dataset, voidNamespace+"distinctObjects").IntegerLiteral(stats.DistinctObjects),
+		SubjPred(dataset, voidNamespace+"properties").IntegerLiteral(len(stats.Predicates)),
+	}
+
+	var preds []string
+	for pred := range stats.Predicates {
+		preds = append(preds, pred)
+	}
+	sort.Strings(preds)
+	for _, pred := range preds {
+		ps, partition := stats.Predicates[pred], newGeneratedBnode()
+		tris = append(tris,
+			SubjPred(dataset, voidNamespace+"propertyPartition").Bnode(partition),
+			BnodePred(partition, voidNamespace+"property").Resource(pred),
+			BnodePred(partition, voidNamespace+"triples").IntegerLiteral(ps.Triples),
+			BnodePred(partition, voidNamespace+"distinctSubjects").IntegerLiteral(ps.DistinctSubjects),
+			BnodePred(partition, voidNamespace+"distinctObjects").IntegerLiteral(ps.DistinctObjects),
+		)
+	}
+	return tris
+}
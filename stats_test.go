@@ -0,0 +1,73 @@
+package triplestore_test
+
+import (
+	"reflect"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestStats(t *testing.T) {
+	src := tstore.NewSource()
+	src.Add(
+		tstore.SubjPred("me", "age").IntegerLiteral(26),
+		tstore.SubjPred("you", "age").IntegerLiteral(26),
+		tstore.SubjPred("me", "name").StringLiteral("jsmith"),
+		tstore.SubjPred("me", "name").StringLiteralWithLang("jean", "fr"),
+		tstore.SubjPred("me", "knows").Resource("you"),
+		tstore.BnodePred("b", "knows").Resource("you"),
+	)
+	snap := src.Snapshot()
+
+	expected := tstore.Statistics{
+		Triples:          6,
+		DistinctSubjects: 3,
+		DistinctObjects:  4,
+		Predicates: map[string]tstore.PredicateStatistics{
+			"age":   {Triples: 2, DistinctSubjects: 2, DistinctObjects: 1},
+			"name":  {Triples: 2, DistinctSubjects: 1, DistinctObjects: 2},
+			"knows": {Triples: 2, DistinctSubjects: 2, DistinctObjects: 1},
+		},
+		Datatypes: map[tstore.XsdType]int{
+			tstore.XsdInteger:                2,
+			tstore.XsdString:                 1,
+			tstore.XsdType("rdf:langString"): 1,
+		},
+	}
+	for _, g := range []tstore.RDFGraph{snap, tstore.Union(snap)} {
+		if got, want := tstore.Stats(g), expected; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+		age := "age"
+		if got, want := tstore.Cardinality(g, nil, &age, tstore.IntegerLiteral(26)), 2; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	}
+
+	void := tstore.NewSource()
+	void.Add(expected.VoID("http://example.org/dataset")...)
+	triples := tstore.Triples(void.Snapshot().WithPredicate("http://rdfs.org/ns/void#triples"))
+	if got, want := len(triples), 4; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	partitions := void.Snapshot().WithPredObj("http://rdfs.org/ns/void#property", tstore.Resource("name"))
+	if got, want := len(partitions), 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	count := void.Snapshot().WithSubjPred(partitions[0].Subject(), "http://rdfs.org/ns/void#distinctObjects")
+	if got, want := count[0].Object(), tstore.IntegerLiteral(2); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// partitions of descriptions merged together stay apart
+	void.Add(expected.VoID("http://example.org/other")...)
+	partitions = void.Snapshot().WithPredObj("http://rdfs.org/ns/void#property", tstore.Resource("name"))
+	if got, want := len(partitions), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	for _, p := range partitions {
+		if got, want := len(void.Snapshot().WithSubjPred(p.Subject(), "http://rdfs.org/ns/void#triples")), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	}
+}
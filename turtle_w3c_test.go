@@ -0,0 +1,165 @@
+package triplestore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTurtleW3CTestSuite runs a selection of the syntax tests of the W3C
+// Turtle suite, positive ones being compared to their N-Triples when given.
+// It is not the full suite: evaluation tests of the manifest are not included.
+func TestTurtleW3CTestSuite(t *testing.T) {
+	t.Run("positives", func(t *testing.T) {
+		path := filepath.Join("testdata", "turtle", "w3c_suite", "positives", "*.ttl")
+		filenames, _ := filepath.Glob(path)
+
+		for _, filename := range filenames {
+			b, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("cannot read file %s", filename)
+			}
+
+			context := &Context{Base: "http://www.w3.org/2013/TurtleTests/" + filepath.Base(filename)}
+			tris, err := NewTurtleDecoderWithContext(bytes.NewReader(b), context).Decode()
+			if err != nil {
+				t.Fatalf("file %s: %s", filename, err)
+			}
+
+			expectedFilepath := strings.TrimSuffix(filename, ".ttl") + ".nt"
+			if _, err := os.Stat(expectedFilepath); os.IsNotExist(err) {
+				continue
+			}
+			nt, err := ioutil.ReadFile(expectedFilepath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// ntriples being a subset of turtle, the expected results are read with the turtle decoder
+			expected, err := NewTurtleDecoder(bytes.NewReader(nt)).Decode()
+			if err != nil {
+				t.Fatalf("file %s: %s", expectedFilepath, err)
+			}
+
+			if !isomorphicTriples(tris, expected) {
+				var buf bytes.Buffer
+				NewLenientNTEncoder(&buf).Encode(tris...)
+				t.Fatalf("file %s: mismatch\n\ngot\n%s\n\nwant\n%s\n", filename, buf.Bytes(), nt)
+			}
+		}
+	})
+
+	t.Run("negatives", func(t *testing.T) {
+		path := filepath.Join("testdata", "turtle", "w3c_suite", "negatives", "*.ttl")
+		filenames, _ := filepath.Glob(path)
+
+		for _, filename := range filenames {
+			b, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("cannot read file %s", filename)
+			}
+
+			if _, err := NewTurtleDecoder(bytes.NewReader(b)).Decode(); err == nil {
+				t.Fatalf("filename '%s': expected err, got none", filename)
+			}
+		}
+	})
+}
+
+// isomorphicTriples tells whether both sets of triples are equal
+// up to a renaming of their blank nodes
+func isomorphicTriples(a, b []Triple) bool {
+	a, b = dedupTriples(a), dedupTriples(b)
+	if len(a) != len(b) {
+		return false
+	}
+	bKeys := make(map[string]bool)
+	for _, tri := range b {
+		bKeys[tri.(*triple).key()] = true
+	}
+	aLabels, bLabels := bnodeLabels(a), bnodeLabels(b)
+	if len(aLabels) != len(bLabels) {
+		return false
+	}
+
+	mapping := make(map[string]string)
+	used := make(map[string]bool)
+	var try func(int) bool
+	try = func(i int) bool {
+		for _, tri := range a {
+			if renamed, ok := renameBnodes(tri.(*triple), mapping); ok && !bKeys[renamed.key()] {
+				return false
+			}
+		}
+		if i == len(aLabels) {
+			return true
+		}
+		for _, l := range bLabels {
+			if used[l] {
+				continue
+			}
+			mapping[aLabels[i]], used[l] = l, true
+			if try(i + 1) {
+				return true
+			}
+			delete(mapping, aLabels[i])
+			used[l] = false
+		}
+		return false
+	}
+	return try(0)
+}
+
+func dedupTriples(tris []Triple) (out []Triple) {
+	seen := make(map[string]bool)
+	for _, tri := range tris {
+		if k := tri.(*triple).key(); !seen[k] {
+			seen[k] = true
+			out = append(out, tri)
+		}
+	}
+	return
+}
+
+func bnodeLabels(tris []Triple) (out []string) {
+	seen := make(map[string]bool)
+	add := func(l string) {
+		if !seen[l] {
+			seen[l] = true
+			out = append(out, l)
+		}
+	}
+	for _, tri := range tris {
+		tr := tri.(*triple)
+		if tr.isSubBnode {
+			add(tr.sub)
+		}
+		if tr.obj.isBnode {
+			add(tr.obj.bnode)
+		}
+	}
+	return
+}
+
+// renameBnodes returns the triple with its blank nodes renamed,
+// only when all of them are mapped
+func renameBnodes(tr *triple, mapping map[string]string) (*triple, bool) {
+	renamed := &triple{sub: tr.sub, isSubBnode: tr.isSubBnode, pred: tr.pred, obj: tr.obj}
+	if tr.isSubBnode {
+		l, ok := mapping[tr.sub]
+		if !ok {
+			return nil, false
+		}
+		renamed.sub = l
+	}
+	if tr.obj.isBnode {
+		l, ok := mapping[tr.obj.bnode]
+		if !ok {
+			return nil, false
+		}
+		renamed.obj.bnode = l
+	}
+	return renamed, true
+}
@@ -0,0 +1,352 @@
+package triplestore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultTurtleStreamBufferSize is the number of triples grouped at once
+// when stream encoding, unless specified otherwise
+const DefaultTurtleStreamBufferSize = 1000
+
+type turtleEncoder struct {
+	w          io.Writer
+	c          *Context
+	bufferSize int
+}
+
+// NewTurtleEncoder encodes triples in the Turtle format,
+// grouped by subject then by predicate
+func NewTurtleEncoder(w io.Writer) Encoder {
+	return &turtleEncoder{w: w}
+}
+
+// NewTurtleEncoderWithContext encodes triples in the Turtle format,
+// compacting IRIs to prefixed names or to IRIs relative to the base
+// of the context. Blank nodes referenced only once are nested.
+func NewTurtleEncoderWithContext(w io.Writer, c *Context) Encoder {
+	return &turtleEncoder{w: w, c: c}
+}
+
+// NewTurtleStreamEncoder encodes triples in the Turtle format, grouping
+// them by chunks of at most bufferSize triples to bound memory usage.
+// Blank nodes are never nested as triples of later chunks may refer to them.
+func NewTurtleStreamEncoder(w io.Writer, c *Context, bufferSize int) StreamEncoder {
+	if bufferSize <= 0 {
+		bufferSize = DefaultTurtleStreamBufferSize
+	}
+	return &turtleEncoder{w: w, c: c, bufferSize: bufferSize}
+}
+
+func (enc *turtleEncoder) Encode(tris ...Triple) error {
+	var buf bytes.Buffer
+	tw := newTurtleWriter(enc.c, &buf)
+	tw.writeHeader()
+	tw.writeTriples(tris, true)
+	_, err := enc.w.Write(buf.Bytes())
+	return err
+}
+
+func (enc *turtleEncoder) StreamEncode(ctx context.Context, triples <-chan Triple) error {
+	if triples == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	tw := newTurtleWriter(enc.c, &buf)
+	tw.writeHeader()
+
+	var pending []Triple
+	flush := func() error {
+		tw.writeTriples(pending, false)
+		pending = pending[:0]
+		_, err := enc.w.Write(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+	for {
+		select {
+		case tri, ok := <-triples:
+			if !ok {
+				return flush()
+			}
+			pending = append(pending, tri)
+			if len(pending) >= enc.bufferSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ctx.Done():
+			return flush()
+		}
+	}
+}
+
+type turtleSubject struct {
+	sub   object
+	preds []string
+	objs  map[string][]object
+}
+
+type turtleWriter struct {
+	c          *Context
+	buf        *bytes.Buffer
+	namespaces []string // prefixes sorted by decreasing namespace length
+	bnodes     map[string]string
+
+	// state of the triples being written
+	subjects map[string]*turtleSubject
+	nested   map[string]bool
+	written  map[string]bool
+}
+
+func newTurtleWriter(c *Context, buf *bytes.Buffer) *turtleWriter {
+	if c == nil {
+		c = NewContext()
+	}
+	tw := &turtleWriter{c: c, buf: buf, bnodes: make(map[string]string)}
+	for prefix := range c.Prefixes {
+		tw.namespaces = append(tw.namespaces, prefix)
+	}
+	sort.Slice(tw.namespaces, func(i, j int) bool {
+		ni, nj := c.Prefixes[tw.namespaces[i]], c.Prefixes[tw.namespaces[j]]
+		if len(ni) != len(nj) {
+			return len(ni) > len(nj)
+		}
+		return tw.namespaces[i] < tw.namespaces[j]
+	})
+	return tw
+}
+
+func (tw *turtleWriter) writeHeader() {
+	if tw.c.Base != "" {
+		fmt.Fprintf(tw.buf, "@base <%s> .\n", escapeTurtleIRI(tw.c.Base))
+	}
+	var prefixes []string
+	for prefix := range tw.c.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		fmt.Fprintf(tw.buf, "@prefix %s: <%s> .\n", prefix, escapeTurtleIRI(tw.c.Prefixes[prefix]))
+	}
+	if tw.c.Base != "" || len(prefixes) > 0 {
+		tw.buf.WriteByte('\n')
+	}
+}
+
+// writeTriples writes triples grouped by subjects, in order of appearance.
+// With nest set, blank nodes referenced only once are written in place.
+func (tw *turtleWriter) writeTriples(tris []Triple, nest bool) {
+	tw.subjects = make(map[string]*turtleSubject)
+	tw.nested = make(map[string]bool)
+	tw.written = make(map[string]bool)
+
+	var order []string
+	seen := make(map[string]bool)
+	refs := make(map[string]int)
+	for _, t := range tris {
+		tt := t.(*triple)
+		if seen[tt.key()] {
+			continue
+		}
+		seen[tt.key()] = true
+
+		sub := subjectObject(tt)
+		k := sub.key()
+		s, ok := tw.subjects[k]
+		if !ok {
+			s = &turtleSubject{sub: sub, objs: make(map[string][]object)}
+			tw.subjects[k] = s
+			order = append(order, k)
+		}
+		if _, ok := s.objs[tt.pred]; !ok {
+			s.preds = append(s.preds, tt.pred)
+		}
+		s.objs[tt.pred] = append(s.objs[tt.pred], tt.obj)
+		if tt.obj.isBnode {
+			refs[tt.obj.bnode]++
+		}
+	}
+
+	if nest {
+		for bnode, count := range refs {
+			if count == 1 {
+				tw.nested[bnode] = true
+			}
+		}
+	}
+
+	for _, k := range order {
+		if s := tw.subjects[k]; !(s.sub.isBnode && tw.nested[s.sub.bnode]) {
+			tw.writeSubject(k)
+		}
+	}
+	// nested blank nodes only referring to each other in a cycle
+	for _, k := range order {
+		if !tw.written[k] {
+			tw.writeSubject(k)
+		}
+	}
+}
+
+func (tw *turtleWriter) writeSubject(k string) {
+	s := tw.subjects[k]
+	tw.written[k] = true
+	tw.buf.WriteString(tw.term(s.sub))
+	tw.buf.WriteByte(' ')
+	tw.writePredicateObjects(s, "\n    ")
+	tw.buf.WriteString(" .\n")
+}
+
+func (tw *turtleWriter) writePredicateObjects(s *turtleSubject, sep string) {
+	for i, pred := range s.preds {
+		if i > 0 {
+			tw.buf.WriteString(" ;" + sep)
+		}
+		if pred == rdfType || pred == "rdf:type" {
+			tw.buf.WriteString("a")
+		} else {
+			tw.buf.WriteString(tw.iri(pred))
+		}
+		for j, obj := range s.objs[pred] {
+			if j > 0 {
+				tw.buf.WriteByte(',')
+			}
+			tw.buf.WriteByte(' ')
+			tw.writeObject(obj)
+		}
+	}
+}
+
+func (tw *turtleWriter) writeObject(o object) {
+	if !o.isBnode || !tw.nested[o.bnode] || tw.written[o.key()] {
+		tw.buf.WriteString(tw.term(o))
+		return
+	}
+	tw.written[o.key()] = true
+	s, ok := tw.subjects[o.key()]
+	if !ok {
+		tw.buf.WriteString("[]")
+		return
+	}
+	tw.buf.WriteString("[ ")
+	tw.writePredicateObjects(s, " ")
+	tw.buf.WriteString(" ]")
+}
+
+func (tw *turtleWriter) term(o object) string {
+	switch {
+	case o.isLit:
+		return tw.literal(o.lit)
+	case o.isBnode:
+		return "_:" + tw.bnodeLabel(o.bnode)
+	default:
+		return tw.iri(o.resource)
+	}
+}
+
+var validBnodeLabel = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_.-]*[A-Za-z0-9_-])?$`)
+
+// bnodeLabel keeps labels valid in turtle, others are relabelled
+func (tw *turtleWriter) bnodeLabel(label string) string {
+	if validBnodeLabel.MatchString(label) {
+		return label
+	}
+	if l, ok := tw.bnodes[label]; ok {
+		return l
+	}
+	l := fmt.Sprintf("b%d_", len(tw.bnodes))
+	tw.bnodes[label] = l
+	return l
+}
+
+// iri writes an IRI, possibly given in its prefixed form, as a prefixed
+// name when a namespace of the context matches, or relative to the base
+// when longer than the namespace and resolving back to the IRI
+func (tw *turtleWriter) iri(id string) string {
+	id = expandPrefixedIRI(tw.c, id)
+	rel, relative := relativeIRI(tw.c.Base, id)
+	for _, prefix := range tw.namespaces {
+		ns := tw.c.Prefixes[prefix]
+		if (relative && len(ns) <= len(tw.c.Base)) || !strings.HasPrefix(id, ns) {
+			continue
+		}
+		if local, ok := turtleLocalName(strings.TrimPrefix(id, ns)); ok {
+			return prefix + ":" + local
+		}
+	}
+	if relative {
+		id = rel
+	}
+	return "<" + escapeTurtleIRI(id) + ">"
+}
+
+var (
+	turtleInteger = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	turtleDecimal = regexp.MustCompile(`^[+-]?[0-9]*\.[0-9]+$`)
+	turtleDouble  = regexp.MustCompile(`^[+-]?([0-9]+\.[0-9]*|\.?[0-9]+)[eE][+-]?[0-9]+$`)
+)
+
+func (tw *turtleWriter) literal(lit literal) string {
+	if lit.langtag != "" {
+		return quoteTurtleString(lit.val) + "@" + lit.langtag
+	}
+	switch {
+	case lit.typ == XsdString:
+		return quoteTurtleString(lit.val)
+	case lit.typ == XsdInteger && turtleInteger.MatchString(lit.val),
+		lit.typ == XsdDecimal && turtleDecimal.MatchString(lit.val),
+		lit.typ == XsdDouble && turtleDouble.MatchString(lit.val),
+		lit.typ == XsdBoolean && (lit.val == "true" || lit.val == "false"):
+		return lit.val
+	}
+	typ := string(lit.typ)
+	if strings.HasPrefix(typ, "xsd:") {
+		typ = lit.typ.NTriplesNamespaced()
+	}
+	return quoteTurtleString(lit.val) + "^^" + tw.iri(typ)
+}
+
+var turtleStringEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\r", "\\r")
+
+func quoteTurtleString(s string) string {
+	return "\"" + turtleStringEscaper.Replace(s) + "\""
+}
+
+func escapeTurtleIRI(iri string) string {
+	var buf bytes.Buffer
+	for _, r := range iri {
+		if r <= 0x20 || strings.ContainsRune("<>\"{}|^`\\", r) {
+			fmt.Fprintf(&buf, "\\u%04X", r)
+		} else {
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+// turtleLocalName escapes the local part of a prefixed name,
+// returning false when it cannot be written as such
+func turtleLocalName(local string) (string, bool) {
+	var buf bytes.Buffer
+	runes := []rune(local)
+	for i, r := range runes {
+		switch {
+		case r == '.' && (i == 0 || i == len(runes)-1):
+			buf.WriteString("\\.")
+		case isPNChars(r) && !(i == 0 && !isPNCharsU(r) && !(r >= '0' && r <= '9')),
+			r == ':', r == '.':
+			buf.WriteRune(r)
+		case strings.ContainsRune("_~.-!$&'()*+,;=/?#@%", r):
+			buf.WriteString("\\" + string(r))
+		default:
+			return "", false
+		}
+	}
+	return buf.String(), true
+}
@@ -0,0 +1,157 @@
+package triplestore
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeTurtle(t *testing.T) {
+	c := &Context{
+		Base: "http://ex.org/people/",
+		Prefixes: map[string]string{
+			"ex":  "http://ex.org/",
+			"xsd": "http://www.w3.org/2001/XMLSchema#",
+		},
+	}
+	tris := []Triple{
+		SubjPred("http://ex.org/people/me", "rdf:type").Resource("ex:Person"),
+		SubjPred("http://ex.org/people/me", "http://ex.org/name").StringLiteral(`john "the" smith`),
+		SubjPred("http://ex.org/people/me", "http://ex.org/age").IntegerLiteral(26),
+		SubjPred("http://ex.org/people/me", "http://ex.org/knows").Resource("http://ex.org/people/you"),
+		SubjPred("http://ex.org/people/me", "http://ex.org/knows").Resource("http://other.org/her"),
+		SubjPred("http://ex.org/people/me", "http://ex.org/address").Bnode("addr"),
+		BnodePred("addr", "http://ex.org/city").StringLiteralWithLang("Paris", "fr"),
+		SubjPred("http://ex.org/people/you", "http://ex.org/born").DateTimeLiteral(time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)),
+		SubjPred("http://ex.org/people/you", "http://ex.org/tag").Resource("http://ex.org/a/b"),
+	}
+
+	var buf bytes.Buffer
+	if err := NewTurtleEncoderWithContext(&buf, c).Encode(tris...); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `@base <http://ex.org/people/> .
+@prefix ex: <http://ex.org/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+
+<me> a ex:Person ;
+    ex:name "john \"the\" smith" ;
+    ex:age 26 ;
+    ex:knows <you>, <http://other.org/her> ;
+    ex:address [ ex:city "Paris"@fr ] .
+<you> ex:born "2017-01-02T00:00:00Z"^^xsd:dateTime ;
+    ex:tag ex:a\/b .
+`
+	if got, want := buf.String(), expected; got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	decoded, err := NewTurtleDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expanded, err := NewTurtleDecoder(strings.NewReader(`@prefix ex: <http://ex.org/> .
+<http://ex.org/people/me> a ex:Person ; ex:name "john \"the\" smith" ; ex:age 26 ;
+  ex:knows <http://ex.org/people/you>, <http://other.org/her> ; ex:address _:addr .
+_:addr ex:city "Paris"@fr .
+<http://ex.org/people/you> ex:born "2017-01-02T00:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime> ; ex:tag ex:a\/b .`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isomorphicTriples(decoded, expanded) {
+		t.Fatalf("got %v, want %v", decoded, expanded)
+	}
+}
+
+func TestEncodeTurtleRelativeIRIs(t *testing.T) {
+	c := &Context{Base: "http://example.org/data", Prefixes: map[string]string{}}
+	var tris []Triple
+	for _, iri := range []string{
+		"http://example.org/data/b",
+		"http://example.org/datax",
+		"http://example.org/data:c",
+		"http://example.org/data",
+		"http://example.org/data#frag",
+		"http://example.org/data?q",
+		"http://example.org/b",
+		"http://example.org/data//b",
+		"http://other.org/data",
+	} {
+		tris = append(tris, SubjPred("http://example.org/s", "http://example.org/p").Resource(iri))
+	}
+
+	var buf bytes.Buffer
+	if err := NewTurtleEncoderWithContext(&buf, c).Encode(tris...); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<data/b>", "<datax>", "<http://example.org/data:c>", "<#frag>", "<?q>", "<b>"} {
+		if got := buf.String(); !strings.Contains(got, want) {
+			t.Fatalf("expected %s in %s", want, got)
+		}
+	}
+
+	decoded, err := NewTurtleDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Triples(decoded), Triples(tris); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestEncodeTurtleBnodes(t *testing.T) {
+	tris := []Triple{
+		SubjPred("one", "two").Bnode("shared"),
+		SubjPred("three", "four").Bnode("shared"),
+		BnodePred("shared", "five").Bnode("empty"),
+		BnodePred("cycle1", "six").Bnode("cycle2"),
+		BnodePred("cycle2", "six").Bnode("cycle1"),
+		BnodePred("my label", "seven").Bnode("my label"),
+	}
+
+	var buf bytes.Buffer
+	if err := NewTurtleEncoder(&buf).Encode(tris...); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "_:shared <five> [] .\n"; !strings.Contains(got, want) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	decoded, err := NewTurtleDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isomorphicTriples(decoded, tris) {
+		t.Fatalf("got %v, want %v", decoded, tris)
+	}
+}
+
+func TestStreamEncodeTurtle(t *testing.T) {
+	tris := []Triple{
+		SubjPred("one", "two").Resource("three"),
+		SubjPred("one", "two").Resource("four"),
+		SubjPred("one", "five").Bnode("six"),
+		BnodePred("six", "seven").BooleanLiteral(true),
+		SubjPred("one", "eight").StringLiteral("nine"),
+	}
+
+	var buf bytes.Buffer
+	triC := make(chan Triple)
+	go tripleChan(tris, triC)
+	if err := NewTurtleStreamEncoder(&buf, RDFContext, 2).StreamEncode(context.Background(), triC); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := strings.Count(buf.String(), "<one> "), 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	decoded, err := NewTurtleDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isomorphicTriples(decoded, tris) {
+		t.Fatalf("got %v, want %v", decoded, tris)
+	}
+}
@@ -0,0 +1,937 @@
+package triplestore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	rdfType      = rdfNamespace + "type"
+	rdfFirst     = rdfNamespace + "first"
+	rdfRest      = rdfNamespace + "rest"
+	rdfNil       = rdfNamespace + "nil"
+)
+
+// errStopParsing aborts parsing without error, when stream decoding is cancelled
+var errStopParsing = errors.New("parsing stopped")
+
+// turtleParser is a streaming parser of the W3C Turtle syntax.
+//
+// IRIs are expanded against prefixes and resolved against the base IRI, except
+// relative IRIs without base which are kept as is. Blank nodes created by '[]'
+// and collections are labelled 'genid' followed by a counter, and labels of
+// the document starting with 'genid' are relabelled not to collide with them.
+type turtleParser struct {
+	r        *bufio.Reader
+	line     int
+	base     string
+	prefixes map[string]string
+	genid    int
+	emit     func(Triple) error
+	// labels blank nodes of the document, not to collide with generated ones
+	label func(string) string
+}
+
+func newTurtleParser(r io.Reader, c *Context) *turtleParser {
+	p := &turtleParser{r: bufio.NewReader(r), line: 1, prefixes: make(map[string]string), label: documentBnode}
+	if c != nil {
+		p.base = c.Base
+		for k, v := range c.Prefixes {
+			p.prefixes[k] = v
+		}
+	}
+	return p
+}
+
+func (p *turtleParser) Parse() (out []Triple, err error) {
+	err = p.parse(func(t Triple) error {
+		out = append(out, t)
+		return nil
+	})
+	return
+}
+
+func (p *turtleParser) parse(emit func(Triple) error) error {
+	p.emit = emit
+	for {
+		if err := p.skipWS(); err != nil {
+			return p.wrapErr(err)
+		}
+		if p.eof() {
+			return nil
+		}
+		if err := p.statement(); err != nil {
+			if err == errStopParsing {
+				return nil
+			}
+			return p.wrapErr(err)
+		}
+	}
+}
+
+func (p *turtleParser) wrapErr(err error) error {
+	return fmt.Errorf("turtle: line %d: %s", p.line, err)
+}
+
+func (p *turtleParser) eof() bool {
+	_, err := p.r.Peek(1)
+	return err != nil
+}
+
+// peekRune returns the rune starting at the given byte offset, 0 at end of input
+func (p *turtleParser) peekRune(offset int) (rune, int) {
+	b, _ := p.r.Peek(offset + utf8.UTFMax)
+	if len(b) <= offset {
+		return 0, 0
+	}
+	return utf8.DecodeRune(b[offset:])
+}
+
+func (p *turtleParser) peek() rune {
+	r, _ := p.peekRune(0)
+	return r
+}
+
+func (p *turtleParser) readRune() (rune, error) {
+	r, size, err := p.r.ReadRune()
+	if err == io.EOF {
+		return 0, errors.New("unexpected end of input")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if r == utf8.RuneError && size == 1 {
+		return 0, errors.New("invalid utf8 encoding")
+	}
+	if r == '\n' {
+		p.line++
+	}
+	return r, nil
+}
+
+func (p *turtleParser) skipWS() error {
+	for {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			if _, err := p.readRune(); err != nil {
+				return err
+			}
+		case '#':
+			for r := p.peek(); r != '\n' && r != 0; r = p.peek() {
+				if _, err := p.readRune(); err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *turtleParser) expect(c rune) error {
+	if err := p.skipWS(); err != nil {
+		return err
+	}
+	r, err := p.readRune()
+	if err != nil {
+		return fmt.Errorf("expected '%c': %s", c, err)
+	}
+	if r != c {
+		return fmt.Errorf("expected '%c', got '%c'", c, r)
+	}
+	return nil
+}
+
+// peekKeyword tells whether the given keyword comes next, not followed by
+// a character which would make it a prefixed name
+func (p *turtleParser) peekKeyword(kw string, fold bool) bool {
+	b, _ := p.r.Peek(len(kw))
+	if len(b) < len(kw) {
+		return false
+	}
+	if !(string(b) == kw || (fold && strings.EqualFold(string(b), kw))) {
+		return false
+	}
+	return !p.continuesName(len(kw))
+}
+
+func (p *turtleParser) discard(n int) {
+	for i := 0; i < n; i++ {
+		p.readRune()
+	}
+}
+
+func (p *turtleParser) statement() error {
+	switch {
+	case p.peek() == '@':
+		p.readRune()
+		word := p.readWhile(isASCIILetterRune)
+		switch word {
+		case "prefix":
+			if err := p.prefixDirective(); err != nil {
+				return err
+			}
+		case "base":
+			if err := p.baseDirective(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown directive '@%s'", word)
+		}
+		return p.expect('.')
+	case p.peekKeyword("PREFIX", true):
+		p.discard(len("PREFIX"))
+		return p.prefixDirective()
+	case p.peekKeyword("BASE", true):
+		p.discard(len("BASE"))
+		return p.baseDirective()
+	}
+
+	if err := p.triples(); err != nil {
+		return err
+	}
+	return p.expect('.')
+}
+
+func (p *turtleParser) prefixDirective() error {
+	if err := p.skipWS(); err != nil {
+		return err
+	}
+	prefix, err := p.readPrefix()
+	if err != nil {
+		return err
+	}
+	if r, _ := p.readRune(); r != ':' {
+		return fmt.Errorf("expected ':' after prefix '%s'", prefix)
+	}
+	if err := p.skipWS(); err != nil {
+		return err
+	}
+	iri, err := p.iriRef()
+	if err != nil {
+		return err
+	}
+	p.prefixes[prefix] = iri
+	return nil
+}
+
+func (p *turtleParser) baseDirective() error {
+	if err := p.skipWS(); err != nil {
+		return err
+	}
+	iri, err := p.iriRef()
+	if err != nil {
+		return err
+	}
+	p.base = iri
+	return nil
+}
+
+func (p *turtleParser) triples() error {
+	var sub object
+	var err error
+	switch p.peek() {
+	case '[':
+		p.readRune()
+		if err := p.skipWS(); err != nil {
+			return err
+		}
+		sub = p.freshBnode()
+		if p.peek() == ']' {
+			p.readRune()
+			break
+		}
+		if err := p.predicateObjectList(sub); err != nil {
+			return err
+		}
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+		if err := p.skipWS(); err != nil {
+			return err
+		}
+		if p.peek() == '.' {
+			return nil
+		}
+		return p.predicateObjectList(sub)
+	case '(':
+		if sub, err = p.collection(); err != nil {
+			return err
+		}
+	default:
+		if sub, err = p.subjectTerm(); err != nil {
+			return err
+		}
+	}
+
+	return p.predicateObjectList(sub)
+}
+
+func (p *turtleParser) subjectTerm() (object, error) {
+	if p.peek() == '_' {
+		return p.blankNodeLabel()
+	}
+	iri, err := p.iri()
+	if err != nil {
+		return object{}, fmt.Errorf("invalid subject: %s", err)
+	}
+	return object{resource: iri}, nil
+}
+
+func (p *turtleParser) predicateObjectList(sub object) error {
+	for {
+		if err := p.skipWS(); err != nil {
+			return err
+		}
+		var pred string
+		if p.peek() == 'a' && !p.continuesName(1) {
+			p.readRune()
+			pred = rdfType
+		} else {
+			iri, err := p.iri()
+			if err != nil {
+				return fmt.Errorf("invalid predicate: %s", err)
+			}
+			pred = iri
+		}
+
+		if err := p.objectList(sub, pred); err != nil {
+			return err
+		}
+
+		if err := p.skipWS(); err != nil {
+			return err
+		}
+		if p.peek() != ';' {
+			return nil
+		}
+		for p.peek() == ';' {
+			p.readRune()
+			if err := p.skipWS(); err != nil {
+				return err
+			}
+		}
+		if r := p.peek(); r == '.' || r == ']' || r == 0 {
+			return nil
+		}
+	}
+}
+
+// continuesName tells whether the rune at the given offset may continue a name.
+// Dots only do so when followed by other name characters.
+func (p *turtleParser) continuesName(offset int) bool {
+	for {
+		r, size := p.peekRune(offset)
+		if r != '.' {
+			return isPNChars(r) || r == ':'
+		}
+		offset += size
+	}
+}
+
+func (p *turtleParser) objectList(sub object, pred string) error {
+	for {
+		if err := p.skipWS(); err != nil {
+			return err
+		}
+		obj, err := p.object()
+		if err != nil {
+			return err
+		}
+		if err := p.emitTriple(sub, pred, obj); err != nil {
+			return err
+		}
+		if err := p.skipWS(); err != nil {
+			return err
+		}
+		if p.peek() != ',' {
+			return nil
+		}
+		p.readRune()
+	}
+}
+
+func (p *turtleParser) emitTriple(sub object, pred string, obj object) error {
+	return p.emit(&triple{
+		sub:        subjectString(sub),
+		isSubBnode: sub.isBnode,
+		pred:       pred,
+		obj:        obj,
+	})
+}
+
+func (p *turtleParser) freshBnode() object {
+	p.genid++
+	return object{bnode: generatedBnode(p.genid), isBnode: true}
+}
+
+func (p *turtleParser) object() (object, error) {
+	switch r := p.peek(); {
+	case r == '<' || r == ':' || isPNCharsBase(r):
+		for _, kw := range []string{"true", "false"} {
+			if p.peekKeyword(kw, false) {
+				p.discard(len(kw))
+				return object{isLit: true, lit: literal{typ: XsdBoolean, val: kw}}, nil
+			}
+		}
+		iri, err := p.iri()
+		return object{resource: iri}, err
+	case r == '_':
+		return p.blankNodeLabel()
+	case r == '[':
+		p.readRune()
+		bnode := p.freshBnode()
+		if err := p.skipWS(); err != nil {
+			return object{}, err
+		}
+		if p.peek() == ']' {
+			p.readRune()
+			return bnode, nil
+		}
+		if err := p.predicateObjectList(bnode); err != nil {
+			return object{}, err
+		}
+		return bnode, p.expect(']')
+	case r == '(':
+		return p.collection()
+	case r == '"' || r == '\'':
+		return p.rdfLiteral()
+	case r == '+' || r == '-' || r == '.' || (r >= '0' && r <= '9'):
+		return p.numericLiteral()
+	case r == 0:
+		return object{}, errors.New("unexpected end of input")
+	default:
+		return object{}, fmt.Errorf("invalid object starting with '%c'", r)
+	}
+}
+
+func (p *turtleParser) collection() (object, error) {
+	p.readRune() // '('
+	var items []object
+	for {
+		if err := p.skipWS(); err != nil {
+			return object{}, err
+		}
+		if p.peek() == ')' {
+			p.readRune()
+			break
+		}
+		item, err := p.object()
+		if err != nil {
+			return object{}, err
+		}
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		return object{resource: rdfNil}, nil
+	}
+
+	head := p.freshBnode()
+	node := head
+	for i, item := range items {
+		if err := p.emitTriple(node, rdfFirst, item); err != nil {
+			return object{}, err
+		}
+		next := object{resource: rdfNil}
+		if i < len(items)-1 {
+			next = p.freshBnode()
+		}
+		if err := p.emitTriple(node, rdfRest, next); err != nil {
+			return object{}, err
+		}
+		node = next
+	}
+	return head, nil
+}
+
+func (p *turtleParser) blankNodeLabel() (object, error) {
+	b, _ := p.r.Peek(2)
+	if string(b) != "_:" {
+		return object{}, errors.New("invalid blank node")
+	}
+	p.discard(2)
+
+	first := p.peek()
+	if !isPNCharsU(first) && !(first >= '0' && first <= '9') {
+		return object{}, errors.New("invalid blank node label")
+	}
+	label := p.readNameChars(false)
+	return object{bnode: p.label(label), isBnode: true}, nil
+}
+
+// readNameChars reads name characters, dots included when not trailing.
+// With local set, it reads a local name with its colons and escapes.
+func (p *turtleParser) readNameChars(local bool) string {
+	var buf bytes.Buffer
+	for {
+		r, size := p.peekRune(0)
+		switch {
+		case isPNChars(r) || (local && r == ':'):
+			p.readRune()
+			buf.WriteRune(r)
+		case local && r == '%':
+			b, _ := p.r.Peek(3)
+			if len(b) < 3 || !isHex(b[1]) || !isHex(b[2]) {
+				return buf.String()
+			}
+			p.discard(3)
+			buf.Write(b)
+		case local && r == '\\':
+			esc, _ := p.peekRune(1)
+			if !strings.ContainsRune("_~.-!$&'()*+,;=/?#@%", esc) {
+				return buf.String()
+			}
+			p.discard(2)
+			buf.WriteRune(esc)
+		case r == '.':
+			// a dot belongs to the name only when followed by another name character
+			offset := size
+			for {
+				next, nsize := p.peekRune(offset)
+				if next == '.' {
+					offset += nsize
+					continue
+				}
+				if !(isPNChars(next) || (local && (next == ':' || next == '%' || next == '\\'))) {
+					return buf.String()
+				}
+				break
+			}
+			p.readRune()
+			buf.WriteRune(r)
+		default:
+			return buf.String()
+		}
+	}
+}
+
+func (p *turtleParser) readWhile(fn func(rune) bool) string {
+	var buf bytes.Buffer
+	for r := p.peek(); r != 0 && fn(r); r = p.peek() {
+		p.readRune()
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func (p *turtleParser) readPrefix() (string, error) {
+	if p.peek() == ':' {
+		return "", nil
+	}
+	if !isPNCharsBase(p.peek()) {
+		return "", fmt.Errorf("invalid prefix starting with '%c'", p.peek())
+	}
+	return p.readNameChars(false), nil
+}
+
+func (p *turtleParser) iri() (string, error) {
+	if p.peek() == '<' {
+		return p.iriRef()
+	}
+
+	prefix, err := p.readPrefix()
+	if err != nil {
+		return "", err
+	}
+	if r, _ := p.peekRune(0); r != ':' {
+		return "", fmt.Errorf("invalid prefixed name '%s'", prefix)
+	}
+	p.readRune()
+
+	ns, ok := p.prefixes[prefix]
+	if !ok {
+		return "", fmt.Errorf("undeclared prefix '%s'", prefix)
+	}
+
+	var localName string
+	if r := p.peek(); isPNCharsU(r) || r == ':' || (r >= '0' && r <= '9') || r == '%' || r == '\\' {
+		localName = p.readNameChars(true)
+	}
+	return ns + localName, nil
+}
+
+func (p *turtleParser) iriRef() (string, error) {
+	if r, _ := p.readRune(); r != '<' {
+		return "", fmt.Errorf("expected IRI, got '%c'", r)
+	}
+	var buf bytes.Buffer
+	for {
+		r, err := p.readRune()
+		if err != nil {
+			return "", err
+		}
+		if r == '>' {
+			break
+		}
+		if r == '\\' {
+			if r, err = p.readUchar(); err != nil {
+				return "", err
+			}
+		}
+		if r <= 0x20 || strings.ContainsRune("<>\"{}|^`\\", r) {
+			return "", fmt.Errorf("invalid character %q in IRI", r)
+		}
+		buf.WriteRune(r)
+	}
+
+	iri := buf.String()
+	if p.base != "" {
+		iri = resolveIRI(p.base, iri)
+	}
+	return iri, nil
+}
+
+func (p *turtleParser) readUchar() (rune, error) {
+	r, err := p.readRune()
+	if err != nil {
+		return 0, err
+	}
+	var size int
+	switch r {
+	case 'u':
+		size = 4
+	case 'U':
+		size = 8
+	default:
+		return 0, fmt.Errorf("invalid escape '\\%c'", r)
+	}
+	hex := make([]byte, size)
+	if _, err := io.ReadFull(p.r, hex); err != nil {
+		return 0, errors.New("invalid unicode escape")
+	}
+	code, err := strconv.ParseUint(string(hex), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, fmt.Errorf("invalid unicode escape '%s'", hex)
+	}
+	return rune(code), nil
+}
+
+func (p *turtleParser) rdfLiteral() (object, error) {
+	val, err := p.stringLiteral()
+	if err != nil {
+		return object{}, err
+	}
+
+	lit := literal{typ: XsdString, val: val}
+	switch p.peek() {
+	case '@':
+		p.readRune()
+		lang := p.readWhile(isASCIILetterRune)
+		if lang == "" {
+			return object{}, errors.New("invalid language tag")
+		}
+		for p.peek() == '-' {
+			p.readRune()
+			sub := p.readWhile(func(r rune) bool { return isASCIILetterRune(r) || (r >= '0' && r <= '9') })
+			if sub == "" {
+				return object{}, errors.New("invalid language tag")
+			}
+			lang += "-" + sub
+		}
+		lit.langtag = lang
+	case '^':
+		b, _ := p.r.Peek(2)
+		if string(b) != "^^" {
+			return object{}, errors.New("invalid datatype marker")
+		}
+		p.discard(2)
+		dt, err := p.iri()
+		if err != nil {
+			return object{}, fmt.Errorf("invalid datatype: %s", err)
+		}
+		lit.typ = xsdTypeFromIRI(dt)
+	}
+	return object{isLit: true, lit: lit}, nil
+}
+
+func (p *turtleParser) stringLiteral() (string, error) {
+	quote, _ := p.readRune()
+	long := false
+	if b, _ := p.r.Peek(2); len(b) == 2 && rune(b[0]) == quote && rune(b[1]) == quote {
+		p.discard(2)
+		long = true
+	} else if len(b) > 0 && rune(b[0]) == quote {
+		p.readRune()
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	for {
+		r, err := p.readRune()
+		if err != nil {
+			return "", fmt.Errorf("unterminated string: %s", err)
+		}
+		switch {
+		case r == quote && !long:
+			return buf.String(), nil
+		case r == quote && long:
+			if b, _ := p.r.Peek(2); len(b) == 2 && rune(b[0]) == quote && rune(b[1]) == quote {
+				p.discard(2)
+				// closing quotes are the last three of a run of at most five quotes,
+				// as the string can end with two quotes
+				for i := 0; i < 2 && p.peek() == quote; i++ {
+					p.readRune()
+					buf.WriteRune(quote)
+				}
+				return buf.String(), nil
+			}
+			buf.WriteRune(r)
+		case (r == '\n' || r == '\r') && !long:
+			return "", errors.New("new line in string")
+		case r == '\\':
+			esc, err := p.readEchar()
+			if err != nil {
+				return "", err
+			}
+			buf.WriteRune(esc)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func (p *turtleParser) readEchar() (rune, error) {
+	switch r := p.peek(); r {
+	case 't':
+		p.readRune()
+		return '\t', nil
+	case 'b':
+		p.readRune()
+		return '\b', nil
+	case 'n':
+		p.readRune()
+		return '\n', nil
+	case 'r':
+		p.readRune()
+		return '\r', nil
+	case 'f':
+		p.readRune()
+		return '\f', nil
+	case '"', '\'', '\\':
+		p.readRune()
+		return r, nil
+	default:
+		return p.readUchar()
+	}
+}
+
+func (p *turtleParser) numericLiteral() (object, error) {
+	var buf bytes.Buffer
+	if r := p.peek(); r == '+' || r == '-' {
+		p.readRune()
+		buf.WriteRune(r)
+	}
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+	intPart := p.readWhile(isDigit)
+	buf.WriteString(intPart)
+
+	typ := XsdInteger
+	if p.peek() == '.' {
+		next, _ := p.peekRune(1)
+		if isDigit(next) || ((next == 'e' || next == 'E') && intPart != "") {
+			p.readRune()
+			buf.WriteRune('.')
+			buf.WriteString(p.readWhile(isDigit))
+			typ = XsdDecimal
+		}
+	}
+	if r := p.peek(); r == 'e' || r == 'E' {
+		p.readRune()
+		buf.WriteRune(r)
+		if s := p.peek(); s == '+' || s == '-' {
+			p.readRune()
+			buf.WriteRune(s)
+		}
+		exp := p.readWhile(isDigit)
+		if exp == "" {
+			return object{}, errors.New("invalid exponent in number")
+		}
+		buf.WriteString(exp)
+		typ = XsdDouble
+	}
+
+	val := buf.String()
+	if strings.Trim(val, "+-.") == "" {
+		return object{}, fmt.Errorf("invalid number '%s'", val)
+	}
+	return object{isLit: true, lit: literal{typ: typ, val: val}}, nil
+}
+
+func isHex(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+func isASCIILetterRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isPNCharsBase(r rune) bool {
+	return isASCIILetterRune(r) ||
+		(r >= 0xC0 && r <= 0xD6) || (r >= 0xD8 && r <= 0xF6) || (r >= 0xF8 && r <= 0x2FF) ||
+		(r >= 0x370 && r <= 0x37D) || (r >= 0x37F && r <= 0x1FFF) || (r >= 0x200C && r <= 0x200D) ||
+		(r >= 0x2070 && r <= 0x218F) || (r >= 0x2C00 && r <= 0x2FEF) || (r >= 0x3001 && r <= 0xD7FF) ||
+		(r >= 0xF900 && r <= 0xFDCF) || (r >= 0xFDF0 && r <= 0xFFFD) || (r >= 0x10000 && r <= 0xEFFFF)
+}
+
+func isPNCharsU(r rune) bool {
+	return isPNCharsBase(r) || r == '_'
+}
+
+func isPNChars(r rune) bool {
+	return isPNCharsU(r) || r == '-' || (r >= '0' && r <= '9') || r == 0xB7 ||
+		(r >= 0x300 && r <= 0x36F) || (r >= 0x203F && r <= 0x2040)
+}
+
+// resolveIRI resolves an IRI reference against a base IRI as per RFC 3986.
+// Unlike net/url, it leaves non ASCII characters unescaped.
+func resolveIRI(base, ref string) string {
+	r := splitIRI(ref)
+	if r.scheme != "" {
+		r.path = removeDotSegments(r.path)
+		return r.String()
+	}
+
+	b := splitIRI(base)
+	t := iriParts{scheme: b.scheme, fragment: r.fragment, hasFragment: r.hasFragment}
+	switch {
+	case r.hasAuthority:
+		t.authority, t.hasAuthority = r.authority, true
+		t.path = removeDotSegments(r.path)
+		t.query, t.hasQuery = r.query, r.hasQuery
+	case r.path == "":
+		t.authority, t.hasAuthority = b.authority, b.hasAuthority
+		t.path = b.path
+		t.query, t.hasQuery = b.query, b.hasQuery
+		if r.hasQuery {
+			t.query, t.hasQuery = r.query, true
+		}
+	default:
+		t.authority, t.hasAuthority = b.authority, b.hasAuthority
+		t.query, t.hasQuery = r.query, r.hasQuery
+		if strings.HasPrefix(r.path, "/") {
+			t.path = removeDotSegments(r.path)
+		} else if b.hasAuthority && b.path == "" {
+			t.path = removeDotSegments("/" + r.path)
+		} else {
+			merged := r.path
+			if i := strings.LastIndex(b.path, "/"); i >= 0 {
+				merged = b.path[:i+1] + r.path
+			}
+			t.path = removeDotSegments(merged)
+		}
+	}
+	return t.String()
+}
+
+// relativeIRI returns the IRI relative to the base, either as its suffix after
+// the base or after the last segment of the base path, only when resolving it
+// against the base gives back the IRI
+func relativeIRI(base, iri string) (string, bool) {
+	if base == "" {
+		return "", false
+	}
+	candidates := []string{base}
+	if i := strings.LastIndex(base, "/"); i >= 0 && i > strings.Index(base, "//")+1 {
+		candidates = append(candidates, base[:i+1])
+	}
+	for _, prefix := range candidates {
+		if !strings.HasPrefix(iri, prefix) {
+			continue
+		}
+		if rel := strings.TrimPrefix(iri, prefix); resolveIRI(base, rel) == iri {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+type iriParts struct {
+	scheme, authority, path, query, fragment string
+	hasAuthority, hasQuery, hasFragment      bool
+}
+
+func splitIRI(s string) (p iriParts) {
+	if i := strings.IndexAny(s, ":/?#"); i > 0 && s[i] == ':' && isASCIILetter(s[0]) {
+		p.scheme, s = s[:i], s[i+1:]
+	}
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		p.fragment, p.hasFragment, s = s[i+1:], true, s[:i]
+	}
+	if i := strings.IndexByte(s, '?'); i >= 0 {
+		p.query, p.hasQuery, s = s[i+1:], true, s[:i]
+	}
+	if strings.HasPrefix(s, "//") {
+		s = s[2:]
+		i := strings.IndexByte(s, '/')
+		if i < 0 {
+			i = len(s)
+		}
+		p.authority, p.hasAuthority, s = s[:i], true, s[i:]
+	}
+	p.path = s
+	return
+}
+
+func (p iriParts) String() string {
+	var buf bytes.Buffer
+	if p.scheme != "" {
+		buf.WriteString(p.scheme + ":")
+	}
+	if p.hasAuthority {
+		buf.WriteString("//" + p.authority)
+	}
+	buf.WriteString(p.path)
+	if p.hasQuery {
+		buf.WriteString("?" + p.query)
+	}
+	if p.hasFragment {
+		buf.WriteString("#" + p.fragment)
+	}
+	return buf.String()
+}
+
+func removeDotSegments(path string) string {
+	var out []string
+	for path != "" {
+		switch {
+		case strings.HasPrefix(path, "../"):
+			path = path[3:]
+		case strings.HasPrefix(path, "./"):
+			path = path[2:]
+		case strings.HasPrefix(path, "/./"):
+			path = path[2:]
+		case path == "/.":
+			path = "/"
+		case strings.HasPrefix(path, "/../"):
+			path = path[3:]
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case path == "/..":
+			path = "/"
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case path == "." || path == "..":
+			path = ""
+		default:
+			i := strings.IndexByte(path[1:], '/')
+			if i < 0 {
+				out = append(out, path)
+				path = ""
+			} else {
+				out = append(out, path[:i+1])
+				path = path[i+1:]
+			}
+		}
+	}
+	return strings.Join(out, "")
+}
@@ -0,0 +1,255 @@
+package triplestore
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseTurtle(t *testing.T) {
+	tcases := []struct {
+		input    string
+		expected []Triple
+	}{
+		{
+			input: `@prefix ex: <http://ex.org/> .
+ex:me ex:name "jsmith" ; ex:age 26, 27 ; a ex:Person .`,
+			expected: []Triple{
+				SubjPred("http://ex.org/me", "http://ex.org/name").StringLiteral("jsmith"),
+				SubjPred("http://ex.org/me", "http://ex.org/age").IntegerLiteral(26),
+				SubjPred("http://ex.org/me", "http://ex.org/age").IntegerLiteral(27),
+				SubjPred("http://ex.org/me", rdfType).Resource("http://ex.org/Person"),
+			},
+		},
+		{
+			input: `<me> <male> true ; <size> 1.86 ; <weight> 7.2e1 .`,
+			expected: []Triple{
+				SubjPred("me", "male").BooleanLiteral(true),
+				&triple{sub: "me", pred: "size", obj: object{isLit: true, lit: literal{typ: XsdDecimal, val: "1.86"}}},
+				&triple{sub: "me", pred: "weight", obj: object{isLit: true, lit: literal{typ: XsdDouble, val: "7.2e1"}}},
+			},
+		},
+		{
+			input: `PREFIX xsd: <http://www.w3.org/2001/XMLSchema#>
+<me> <born> "2017-09-01T00:00:00Z"^^xsd:dateTime ; <name> "jsmith"@en .`,
+			expected: []Triple{
+				&triple{sub: "me", pred: "born", obj: object{isLit: true, lit: literal{typ: XsdDateTime, val: "2017-09-01T00:00:00Z"}}},
+				SubjPred("me", "name").StringLiteralWithLang("jsmith", "en"),
+			},
+		},
+		{
+			input: `<me> <address> [ <city> "Paris" ] .`,
+			expected: []Triple{
+				BnodePred("genid1", "city").StringLiteral("Paris"),
+				SubjPred("me", "address").Bnode("genid1"),
+			},
+		},
+		{
+			input: `_:genid1 <p> <o> . [] <p> <o2> . _:b <p> _:genid1 .`,
+			expected: []Triple{
+				BnodePred("genid_genid1", "p").Resource("o"),
+				BnodePred("genid1", "p").Resource("o2"),
+				BnodePred("b", "p").Bnode("genid_genid1"),
+			},
+		},
+		{
+			input: `<s> <p> """a""""" , '''b'\'''' .`,
+			expected: []Triple{
+				SubjPred("s", "p").StringLiteral(`a""`),
+				SubjPred("s", "p").StringLiteral(`b''`),
+			},
+		},
+		{
+			input: `<me> <kids> ( <bob> ) .`,
+			expected: []Triple{
+				BnodePred("genid1", rdfFirst).Resource("bob"),
+				BnodePred("genid1", rdfRest).Resource(rdfNil),
+				SubjPred("me", "kids").Bnode("genid1"),
+			},
+		},
+	}
+
+	for _, tcase := range tcases {
+		tris, err := newTurtleParser(strings.NewReader(tcase.input), nil).Parse()
+		if err != nil {
+			t.Fatalf("input %s: %s", tcase.input, err)
+		}
+		if got, want := len(tris), len(tcase.expected); got != want {
+			t.Fatalf("input %s: got %d, want %d", tcase.input, got, want)
+		}
+		for i := range tris {
+			if got, want := tris[i], tcase.expected[i]; !got.Equal(want) {
+				t.Fatalf("input %s: got %v, want %v", tcase.input, got, want)
+			}
+		}
+	}
+}
+
+func TestParseTurtleWithContext(t *testing.T) {
+	c := &Context{Base: "http://ex.org/people/", Prefixes: map[string]string{"foaf": "http://xmlns.com/foaf/0.1/"}}
+	tris, err := NewTurtleDecoderWithContext(strings.NewReader(`<me> foaf:knows <../pets/rex> .`), c).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tris[0], SubjPred("http://ex.org/people/me", "http://xmlns.com/foaf/0.1/knows").Resource("http://ex.org/pets/rex"); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	_, err = NewTurtleDecoder(strings.NewReader("<me> <name> \"jsmith\" .\n<me> foaf:knows <you> .")).Decode()
+	if err == nil {
+		t.Fatal("expected error on undeclared prefix")
+	}
+	if got, want := err.Error(), "line 2"; !strings.Contains(got, want) {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+// Syntax errors of the W3C Turtle suite that are not in testdata
+func TestParseTurtleErrors(t *testing.T) {
+	tcases := []string{
+		`<s> <p> """a""""""" .`,
+		`<s> <p> "\U0000WXYZ" .`,
+		`<s> <p> "string"@1 .`,
+		`<s> <p> 'abc" .`,
+		`<s> <p> "abc' .`,
+		`<s> <p> '''abc""" .`,
+		`<s> <p> """abc''' .`,
+		"<s> <p> \"abc\ndef\" .",
+		`@prefix : <http://ex.org/> . :s :p :o ; is :p of :x .`,
+		`@prefix : <http://ex.org/> . @keywords a . :x a :Item .`,
+		`@prefix : <http://ex.org/> . :a.:b.:c .`,
+		`@prefix : <http://ex.org/> . :a^:b^:c .`,
+		`@prefix : <http://ex.org/> . @forSome :x .`,
+		`@prefix : <http://ex.org/> . @forAll :x .`,
+		`@prefix : <http://ex.org/> . :x => :y .`,
+		`@prefix : <http://ex.org/> . { :a :b :c } :p :o .`,
+		`@prefix : <http://ex.org/> . :s :p ?x .`,
+		`<s> <p> 0x123 .`,
+		`<s> <p> +-1 .`,
+		`<s> <p> 1.0e .`,
+		`<s> <p> 123.abc .`,
+		`"s" <p> <o> .`,
+		`<s> "p" <o> .`,
+		`<s> [] <o> .`,
+		`<s> _:p <o> .`,
+		`@prefix valid: <http://ex.org/> . valid:s valid:p invalid.:o .`,
+		`@prefix valid: <http://ex.org/> . valid:s valid:p .undefined:o .`,
+		`<s> <p> <o>`,
+	}
+	for _, tc := range tcases {
+		if tris, err := NewTurtleDecoder(strings.NewReader(tc)).Decode(); err == nil {
+			t.Errorf("input %s: expected error, got %v", tc, tris)
+		}
+	}
+}
+
+func TestStreamTurtleDecoding(t *testing.T) {
+	input := `@prefix : <http://ex.org/> .
+:one :two :three .
+:four :five 6 .
+:seven :eight "nine" .`
+
+	var tris []Triple
+	for r := range NewTurtleStreamDecoder(strings.NewReader(input)).StreamDecode(context.Background()) {
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		tris = append(tris, r.Tri)
+	}
+	if got, want := len(tris), 3; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	var errs int
+	for r := range NewTurtleStreamDecoder(strings.NewReader(input + "\n:ten :eleven")).StreamDecode(context.Background()) {
+		if r.Err != nil {
+			errs++
+		}
+	}
+	if got, want := errs, 1; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := NewTurtleStreamDecoder(strings.NewReader(input)).StreamDecode(ctx)
+	<-results
+	cancel()
+	for range results {
+	}
+}
+
+func TestAutoDecodeTurtle(t *testing.T) {
+	tris, err := NewAutoDecoder(strings.NewReader("@prefix : <http://ex.org/> .\n:one :two :three .")).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tris[0], SubjPred("http://ex.org/one", "http://ex.org/two").Resource("http://ex.org/three"); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	NewBinaryEncoder(&buf).Encode(SubjPred("one", "two").Resource("three"))
+	tris, err = NewAutoDecoder(&buf).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tris[0], SubjPred("one", "two").Resource("three"); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAutoDecodeTextFormats(t *testing.T) {
+	want := SubjPred("http://ex.org/one", "http://ex.org/two").Resource("http://ex.org/three")
+	for i, in := range []string{
+		`{"@id": "http://ex.org/one", "http://ex.org/two": {"@id": "http://ex.org/three"}}`,
+		` [ {"@id": "http://ex.org/one", "http://ex.org/two": {"@id": "http://ex.org/three"}} ]`,
+		"# comment\n<http://ex.org/one> <http://ex.org/two> <http://ex.org/three> .",
+		"PREFIX ex: <http://ex.org/>\nex:one ex:two ex:three .",
+	} {
+		tris, err := NewAutoDecoder(strings.NewReader(in)).Decode()
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+		if len(tris) != 1 || !tris[0].Equal(want) {
+			t.Fatalf("case %d: got %v, want %v", i, tris, want)
+		}
+	}
+
+	_, err := NewAutoDecoder(strings.NewReader("%unknown")).Decode()
+	if err == nil || !strings.Contains(err.Error(), "unknown format") {
+		t.Fatalf("got %v, want unknown format error", err)
+	}
+}
+
+func TestResolveIRI(t *testing.T) {
+	base := "http://a/b/c/d;p?q"
+	tcases := map[string]string{
+		"g:h":        "g:h",
+		"g":          "http://a/b/c/g",
+		"./g":        "http://a/b/c/g",
+		"g/":         "http://a/b/c/g/",
+		"/g":         "http://a/g",
+		"//g":        "http://g",
+		"?y":         "http://a/b/c/d;p?y",
+		"g?y":        "http://a/b/c/g?y",
+		"#s":         "http://a/b/c/d;p?q#s",
+		"g#s":        "http://a/b/c/g#s",
+		";x":         "http://a/b/c/;x",
+		"":           "http://a/b/c/d;p?q",
+		".":          "http://a/b/c/",
+		"..":         "http://a/b/",
+		"../g":       "http://a/b/g",
+		"../..":      "http://a/",
+		"../../g":    "http://a/g",
+		"../../../g": "http://a/g",
+		"/./g":       "http://a/g",
+		"g.":         "http://a/b/c/g.",
+		"été":        "http://a/b/c/été",
+	}
+	for ref, want := range tcases {
+		if got := resolveIRI(base, ref); got != want {
+			t.Fatalf("ref '%s': got %s, want %s", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package triplestore
+
+import "errors"
+
+// A Tx is a transaction on a source. Its additions and removals are
+// applied atomically on commit: snapshots of the source see either
+// all of them or none of them.
+//
+// A transaction reads the snapshot of the source taken when it began.
+// Its commit fails with ErrTxConflict when one of the triples it adds or
+// removes was added or removed by another writer in the meantime.
+type Tx interface {
+	Add(...Triple)
+	Remove(...Triple)
+	// Snapshot returns the triples of the source when the transaction began, including its uncommitted writes
+	Snapshot() RDFGraph
+	Commit() error
+	Rollback()
+}
+
+// ErrTxConflict is returned when committing a transaction that conflicts with
+// a write made since it began. The transaction can then be run again.
+var ErrTxConflict = errors.New("triplestore: transaction conflicts with a concurrent write")
+
+var errTxDone = errors.New("triplestore: transaction already committed or rolled back")
+
+type operation struct {
+	op     uint8
+	triple Triple
+}
+
+func operations(op uint8, ts []Triple) []operation {
+	ops := make([]operation, len(ts))
+	for i, t := range ts {
+		ops[i] = operation{op: op, triple: t}
+	}
+	return ops
+}
+
+type tx struct {
+	base   *graph
+	commit func(*graph, []operation) error
+	ops    []operation
+	done   bool
+}
+
+func (t *tx) Add(ts ...Triple) {
+	t.record(addOperation, ts)
+}
+
+func (t *tx) Remove(ts ...Triple) {
+	t.record(removeOperation, ts)
+}
+
+func (t *tx) record(op uint8, ts []Triple) {
+	if t.done {
+		return
+	}
+	for _, tri := range ts {
+		t.ops = append(t.ops, operation{op: op, triple: tri})
+	}
+}
+
+// Snapshot indexes the writes of the transaction in graphs of their own, with
+// their own dictionary: terms of uncommitted writes are thus not interned in
+// the dictionary shared by the snapshots of the source.
+func (t *tx) Snapshot() RDFGraph {
+	if len(t.ops) == 0 {
+		return t.base
+	}
+	last := make(map[string]int, len(t.ops))
+	for i, o := range t.ops {
+		last[o.triple.(*triple).key()] = i
+	}
+	var added, removed []operation
+	for i, o := range t.ops {
+		if last[o.triple.(*triple).key()] != i {
+			continue
+		}
+		if o.op == addOperation {
+			added = append(added, o)
+		} else {
+			removed = append(removed, operation{op: addOperation, triple: o.triple})
+		}
+	}
+	return Union(Difference(t.base, newGraph().with(removed)), newGraph().with(added))
+}
+
+func (t *tx) Commit() error {
+	if t.done {
+		return errTxDone
+	}
+	t.done = true
+	if len(t.ops) == 0 {
+		return nil
+	}
+	return t.commit(t.base, t.ops)
+}
+
+func (t *tx) Rollback() {
+	t.done = true
+	t.ops = nil
+}
+
+// Begin starts a transaction on the source
+func (s *source) Begin() Tx {
+	return &tx{base: s.Snapshot().(*graph), commit: s.commit}
+}
+
+// Update runs the function in a transaction, committed when it returns no error
+func (s *source) Update(fn func(Tx) error) error {
+	return update(s.Begin(), fn)
+}
+
+// commit applies the operations of a transaction begun on the base snapshot
+func (s *source) commit(base *graph, ops []operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conflicts(base, ops) {
+		return ErrTxConflict
+	}
+	s.change(ops)
+	return nil
+}
+
+// conflicts tells whether one of the triples of the operations was added or
+// removed since the base snapshot. It must be called with the lock held.
+func (s *source) conflicts(base *graph, ops []operation) bool {
+	for _, o := range ops {
+		if base.Contains(o.triple) != s.contains(o.triple.(*triple).key(), o.triple) {
+			return true
+		}
+	}
+	return false
+}
+
+func update(t Tx, fn func(Tx) error) error {
+	if err := fn(t); err != nil {
+		t.Rollback()
+		return err
+	}
+	return t.Commit()
+}
@@ -0,0 +1,195 @@
+package triplestore_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestTransactions(t *testing.T) {
+	old := tstore.SubjPred("me", "age").IntegerLiteral(25)
+	current := tstore.SubjPred("me", "age").IntegerLiteral(26)
+	name := tstore.SubjPred("me", "name").StringLiteral("jsmith")
+
+	s := tstore.NewSource()
+	s.Add(old)
+
+	tx := s.Begin()
+	tx.Remove(old)
+	tx.Add(current, name)
+
+	if got, want := tstore.Triples(tx.Snapshot().Triples()), (tstore.Triples{current, name}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := tstore.Triples(s.Snapshot().Triples()), (tstore.Triples{old}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tstore.Triples(s.Snapshot().Triples()), (tstore.Triples{current, name}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected error when committing twice")
+	}
+
+	tx = s.Begin()
+	tx.Remove(name)
+	tx.Rollback()
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected error when committing after rollback")
+	}
+	if got, want := s.Snapshot().Count(), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	errAbort := errors.New("abort")
+	err := s.Update(func(tx tstore.Tx) error {
+		tx.Remove(name)
+		return errAbort
+	})
+	if got, want := err, errAbort; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := s.Snapshot().Count(), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	err = s.Update(func(tx tstore.Tx) error {
+		tx.Add(old)
+		tx.Remove(old, current)
+		if got, want := tx.Snapshot().Count(), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tstore.Triples(s.Snapshot().Triples()), (tstore.Triples{name}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTransactionsAreAtomic(t *testing.T) {
+	s := tstore.NewSource()
+	s.Add(tstore.SubjPred("me", "age").IntegerLiteral(0))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i < 200; i++ {
+			s.Update(func(tx tstore.Tx) error {
+				tx.Remove(tstore.SubjPred("me", "age").IntegerLiteral(i - 1))
+				tx.Add(tstore.SubjPred("me", "age").IntegerLiteral(i))
+				return nil
+			})
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		if got, want := len(s.Snapshot().WithSubjPred("me", "age")), 1; got != want {
+			t.Fatalf("got %d, want %d", got, want)
+		}
+	}
+	wg.Wait()
+}
+
+func TestLogSourceTransactions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triplestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := tstore.SubjPred("me", "age").IntegerLiteral(25)
+	current := tstore.SubjPred("me", "age").IntegerLiteral(26)
+
+	s, err := tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add(old)
+	err = s.Update(func(tx tstore.Tx) error {
+		tx.Remove(old)
+		tx.Add(current)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if err := s.Update(func(tx tstore.Tx) error {
+		tx.Add(old)
+		return nil
+	}); err == nil {
+		t.Fatal("expected error when committing to closed source")
+	}
+
+	s, err = tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got, want := tstore.Triples(s.Snapshot().Triples()), (tstore.Triples{current}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestTransactionConflicts(t *testing.T) {
+	age := tstore.SubjPred("me", "age").IntegerLiteral(25)
+	older := tstore.SubjPred("me", "age").IntegerLiteral(26)
+	name := tstore.SubjPred("me", "name").StringLiteral("jsmith")
+
+	s := tstore.NewSource()
+	s.Add(age)
+
+	first, second, other := s.Begin(), s.Begin(), s.Begin()
+	first.Remove(age)
+	first.Add(older)
+	second.Remove(age)
+	other.Add(name)
+
+	s.Add(tstore.SubjPred("you", "age").IntegerLiteral(30))
+	if got, want := other.Snapshot().Count(), 2; got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	if err := first.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := second.Commit(), tstore.ErrTxConflict; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if err := other.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tstore.Triples(s.Snapshot().WithSubject("me")), (tstore.Triples{older, name}); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	dir, err := ioutil.TempDir("", "triplestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ls, err := tstore.OpenLogSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := ls.Begin()
+	tx.Add(age)
+	ls.Add(age)
+	if got, want := tx.Commit(), tstore.ErrTxConflict; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	ls.Close()
+}
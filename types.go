@@ -2,7 +2,10 @@ package triplestore
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type XsdType string
@@ -16,6 +19,8 @@ var (
 	XsdDouble = XsdType("xsd:double")
 	// 32-bit floating point numbers
 	XsdFloat = XsdType("xsd:float")
+	// arbitrary precision decimal numbers
+	XsdDecimal = XsdType("xsd:decimal")
 
 	// signed 32 or 64 bit
 	XsdInteger = XsdType("xsd:integer")
@@ -42,3 +47,70 @@ func (x XsdType) NTriplesNamespaced() string {
 
 	return fmt.Sprintf("%s#%s", XMLSchemaNamespace, splits[1])
 }
+
+// xsdTypeFromIRI maps a datatype IRI of the XML schema namespace to its
+// short XsdType form (ex: xsd:integer) as used by the literal DSL
+func xsdTypeFromIRI(iri string) XsdType {
+	if strings.HasPrefix(iri, XMLSchemaNamespace+"#") {
+		return XsdType("xsd:" + strings.TrimPrefix(iri, XMLSchemaNamespace+"#"))
+	}
+	return XsdType(iri)
+}
+
+var (
+	xsdIntegerLexical  = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	xsdDecimalLexical  = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+	xsdDoubleLexical   = regexp.MustCompile(`^([+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?|[+-]?INF|NaN)$`)
+	xsdDateTimeLexical = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2})(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	xsdDateLexical     = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2})(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+)
+
+// xsdIntegerBits gives the size of the bounded integer types of the XML schema
+var xsdIntegerBits = map[XsdType]int{
+	"xsd:long": 64, "xsd:int": 32, XsdShort: 16, XsdByte: 8,
+	"xsd:unsignedLong": 64, XsdUinteger: 32, XsdUnsignedShort: 16, XsdUnsignedByte: 8,
+}
+
+// validLexicalForm returns false when the value is not a lexical form of the
+// given built-in XML schema type (ex: "abc" as xsd:integer). Values of other
+// types are always valid.
+func validLexicalForm(typ XsdType, val string) bool {
+	typ = xsdTypeFromIRI(string(typ))
+	switch typ {
+	case XsdBoolean:
+		return val == "true" || val == "false" || val == "1" || val == "0"
+	case XsdInteger:
+		return xsdIntegerLexical.MatchString(val)
+	case XsdDecimal:
+		return xsdDecimalLexical.MatchString(val)
+	case XsdDouble, XsdFloat:
+		return xsdDoubleLexical.MatchString(val)
+	case XsdDateTime:
+		m := xsdDateTimeLexical.FindStringSubmatch(val)
+		if m == nil {
+			return false
+		}
+		_, err := time.Parse("2006-01-02T15:04:05", m[1])
+		return err == nil
+	case "xsd:date":
+		m := xsdDateLexical.FindStringSubmatch(val)
+		if m == nil {
+			return false
+		}
+		_, err := time.Parse("2006-01-02", m[1])
+		return err == nil
+	}
+	if bits, ok := xsdIntegerBits[typ]; ok {
+		if !xsdIntegerLexical.MatchString(val) {
+			return false
+		}
+		var err error
+		if strings.HasPrefix(string(typ), "xsd:unsigned") {
+			_, err = strconv.ParseUint(strings.TrimPrefix(val, "+"), 10, bits)
+		} else {
+			_, err = strconv.ParseInt(val, 10, bits)
+		}
+		return err == nil
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package triplestore
+
+import "sync"
+
+// Union returns a lazy view of the triples of all the graphs. Queries are
+// delegated to each graph, triples found in several graphs being returned once.
+func Union(graphs ...RDFGraph) RDFGraph {
+	return &unionGraph{graphs: graphs}
+}
+
+// Intersection returns a lazy view of the triples found in all the graphs.
+// Queries are delegated to the first graph, results being checked against the others.
+func Intersection(graphs ...RDFGraph) RDFGraph {
+	if len(graphs) == 0 {
+		return newGraph()
+	}
+	others := graphs[1:]
+	return newFilterGraph(graphs[0], func(t Triple) bool {
+		for _, g := range others {
+			if !g.Contains(t) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Difference returns a lazy view of the triples of a that are not in b
+func Difference(a, b RDFGraph) RDFGraph {
+	return newFilterGraph(a, func(t Triple) bool { return !b.Contains(t) })
+}
+
+// Filter returns a lazy view of the triples of the graph satisfying the predicate
+func Filter(g RDFGraph, keep func(Triple) bool) RDFGraph {
+	return newFilterGraph(g, keep)
+}
+
+type unionGraph struct {
+	graphs []RDFGraph
+	once   sync.Once
+	unique []Triple
+}
+
+func (u *unionGraph) Contains(t Triple) bool {
+	return containedIn(u.graphs, t)
+}
+
+// merge collects the results of the query on each graph,
+// skipping triples found in a previous graph
+func (u *unionGraph) merge(query func(RDFGraph) []Triple) (out []Triple) {
+	for i, g := range u.graphs {
+		for _, t := range query(g) {
+			if !containedIn(u.graphs[:i], t) {
+				out = append(out, t)
+			}
+		}
+	}
+	return
+}
+
+func containedIn(graphs []RDFGraph, t Triple) bool {
+	for _, g := range graphs {
+		if g.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *unionGraph) all() []Triple {
+	u.once.Do(func() {
+		u.unique = u.merge(func(g RDFGraph) []Triple { return g.Triples() })
+	})
+	return u.unique
+}
+
+func (u *unionGraph) Triples() []Triple {
+	return append([]Triple(nil), u.all()...)
+}
+
+func (u *unionGraph) Count() int {
+	return len(u.all())
+}
+
+func (u *unionGraph) Scan(s, p *string, o Object) TripleSeq {
+	return func(yield func(Triple) bool) {
+		more := true
+		for i, g := range u.graphs {
+			g.Scan(s, p, o)(func(t Triple) bool {
+				if containedIn(u.graphs[:i], t) {
+					return true
+				}
+				more = yield(t)
+				return more
+			})
+			if !more {
+				return
+			}
+		}
+	}
+}
+
+func (u *unionGraph) WithSubject(s string) []Triple {
+	return u.merge(func(g RDFGraph) []Triple { return g.WithSubject(s) })
+}
+func (u *unionGraph) WithPredicate(p string) []Triple {
+	return u.merge(func(g RDFGraph) []Triple { return g.WithPredicate(p) })
+}
+func (u *unionGraph) WithObject(o Object) []Triple {
+	return u.merge(func(g RDFGraph) []Triple { return g.WithObject(o) })
+}
+func (u *unionGraph) WithSubjObj(s string, o Object) []Triple {
+	return u.merge(func(g RDFGraph) []Triple { return g.WithSubjObj(s, o) })
+}
+func (u *unionGraph) WithSubjPred(s, p string) []Triple {
+	return u.merge(func(g RDFGraph) []Triple { return g.WithSubjPred(s, p) })
+}
+func (u *unionGraph) WithPredObj(p string, o Object) []Triple {
+	return u.merge(func(g RDFGraph) []Triple { return g.WithPredObj(p, o) })
+}
+
+type filterGraph struct {
+	g      RDFGraph
+	keep   func(Triple) bool
+	once   sync.Once
+	unique []Triple
+}
+
+func newFilterGraph(g RDFGraph, keep func(Triple) bool) *filterGraph {
+	return &filterGraph{g: g, keep: keep}
+}
+
+func (f *filterGraph) filter(tris []Triple) (out []Triple) {
+	for _, t := range tris {
+		if f.keep(t) {
+			out = append(out, t)
+		}
+	}
+	return
+}
+
+func (f *filterGraph) Contains(t Triple) bool {
+	return f.g.Contains(t) && f.keep(t)
+}
+
+func (f *filterGraph) all() []Triple {
+	f.once.Do(func() {
+		f.unique = f.filter(f.g.Triples())
+	})
+	return f.unique
+}
+
+func (f *filterGraph) Triples() []Triple {
+	return append([]Triple(nil), f.all()...)
+}
+
+func (f *filterGraph) Count() int {
+	return len(f.all())
+}
+
+func (f *filterGraph) Scan(s, p *string, o Object) TripleSeq {
+	return func(yield func(Triple) bool) {
+		f.g.Scan(s, p, o)(func(t Triple) bool {
+			if !f.keep(t) {
+				return true
+			}
+			return yield(t)
+		})
+	}
+}
+
+func (f *filterGraph) WithSubject(s string) []Triple {
+	return f.filter(f.g.WithSubject(s))
+}
+func (f *filterGraph) WithPredicate(p string) []Triple {
+	return f.filter(f.g.WithPredicate(p))
+}
+func (f *filterGraph) WithObject(o Object) []Triple {
+	return f.filter(f.g.WithObject(o))
+}
+func (f *filterGraph) WithSubjObj(s string, o Object) []Triple {
+	return f.filter(f.g.WithSubjObj(s, o))
+}
+func (f *filterGraph) WithSubjPred(s, p string) []Triple {
+	return f.filter(f.g.WithSubjPred(s, p))
+}
+func (f *filterGraph) WithPredObj(p string, o Object) []Triple {
+	return f.filter(f.g.WithPredObj(p, o))
+}
@@ -0,0 +1,79 @@
+package triplestore_test
+
+import (
+	"testing"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestGraphViews(t *testing.T) {
+	one := tstore.SubjPred("me", "age").IntegerLiteral(26)
+	two := tstore.SubjPred("me", "name").StringLiteral("jsmith")
+	three := tstore.SubjPred("you", "name").StringLiteral("jdoe")
+	four := tstore.SubjPred("me", "knows").Resource("you")
+
+	a, b := tstore.NewSource(), tstore.NewSource()
+	a.Add(one, two, four)
+	b.Add(two, three)
+	ga, gb := a.Snapshot(), b.Snapshot()
+
+	tcases := []struct {
+		name     string
+		g        tstore.RDFGraph
+		expected tstore.Triples
+	}{
+		{"union", tstore.Union(ga, gb), tstore.Triples{one, two, three, four}},
+		{"intersection", tstore.Intersection(ga, gb), tstore.Triples{two}},
+		{"difference", tstore.Difference(ga, gb), tstore.Triples{one, four}},
+		{"filter", tstore.Filter(ga, func(t tstore.Triple) bool { return t.Predicate() != "age" }), tstore.Triples{two, four}},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := tc.g
+			if got, want := g.Count(), len(tc.expected); got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			if got, want := tstore.Triples(g.Triples()), tc.expected; !got.Equal(want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			for _, tri := range []tstore.Triple{one, two, three, four} {
+				var expected bool
+				for _, e := range tc.expected {
+					expected = expected || e.Equal(tri)
+				}
+				if got, want := g.Contains(tri), expected; got != want {
+					t.Fatalf("%v: got %t, want %t", tri, got, want)
+				}
+			}
+
+			// queries must agree with a filtering of all the triples of the view
+			queries := []struct {
+				result []tstore.Triple
+				match  func(tstore.Triple) bool
+			}{
+				{g.WithSubject("me"), func(t tstore.Triple) bool { return t.Subject() == "me" }},
+				{g.WithPredicate("name"), func(t tstore.Triple) bool { return t.Predicate() == "name" }},
+				{g.WithObject(tstore.Resource("you")), func(t tstore.Triple) bool { return t.Object().Equal(tstore.Resource("you")) }},
+				{g.WithSubjObj("me", tstore.IntegerLiteral(26)), func(t tstore.Triple) bool {
+					return t.Subject() == "me" && t.Object().Equal(tstore.IntegerLiteral(26))
+				}},
+				{g.WithSubjPred("me", "name"), func(t tstore.Triple) bool { return t.Subject() == "me" && t.Predicate() == "name" }},
+				{g.WithPredObj("name", tstore.StringLiteral("jdoe")), func(t tstore.Triple) bool {
+					return t.Predicate() == "name" && t.Object().Equal(tstore.StringLiteral("jdoe"))
+				}},
+			}
+			for i, q := range queries {
+				var expected tstore.Triples
+				for _, e := range tc.expected {
+					if q.match(e) {
+						expected = append(expected, e)
+					}
+				}
+				if got, want := tstore.Triples(q.result), expected; !got.Equal(want) {
+					t.Fatalf("query %d: got %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
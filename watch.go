@@ -0,0 +1,84 @@
+package triplestore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// A ChangeEvent is the batch of triples actually added to or removed from
+// a source by a single Add, Remove or transaction commit. Version is the
+// version of the source once the batch is applied.
+type ChangeEvent struct {
+	Version uint64
+	Added   []Triple
+	Removed []Triple
+}
+
+// Version returns the number of batches that changed the source
+func (s *source) Version() uint64 {
+	return atomic.LoadUint64(&s.version)
+}
+
+// Watch returns a channel delivering the changes of the source in commit
+// order, until the context is done. Writers are never blocked by slow
+// watchers: pending events are queued until delivered.
+func (s *source) Watch(ctx context.Context) <-chan ChangeEvent {
+	w := &watcher{notify: make(chan struct{}, 1)}
+	events := make(chan ChangeEvent)
+
+	s.mu.Lock()
+	s.watchers[w] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		defer close(events)
+		defer func() {
+			s.mu.Lock()
+			delete(s.watchers, w)
+			s.mu.Unlock()
+		}()
+
+		for {
+			for _, evt := range w.pop() {
+				select {
+				case events <- evt:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-w.notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+type watcher struct {
+	mu      sync.Mutex
+	pending []ChangeEvent
+	notify  chan struct{}
+}
+
+func (w *watcher) push(evt ChangeEvent) {
+	w.mu.Lock()
+	w.pending = append(w.pending, evt)
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) pop() []ChangeEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := w.pending
+	w.pending = nil
+	return pending
+}
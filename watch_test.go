@@ -0,0 +1,73 @@
+package triplestore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tstore "github.com/wallix/triplestore"
+)
+
+func TestWatchSource(t *testing.T) {
+	s := tstore.NewSource()
+	one := tstore.SubjPred("me", "age").IntegerLiteral(25)
+	two := tstore.SubjPred("me", "age").IntegerLiteral(26)
+	three := tstore.SubjPred("me", "name").StringLiteral("jsmith")
+
+	s.Add(one)
+	if got, want := s.Version(), uint64(1); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := s.Watch(ctx)
+
+	s.Add(one, three)
+	s.Remove(two)
+	s.Update(func(tx tstore.Tx) error {
+		tx.Remove(one)
+		tx.Add(two)
+		tx.Add(three)
+		tx.Remove(three)
+		return nil
+	})
+	s.Remove(two)
+	s.Add(three)
+
+	if got, want := s.Version(), uint64(5); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	expected := []tstore.ChangeEvent{
+		{Version: 2, Added: []tstore.Triple{three}},
+		{Version: 3, Added: []tstore.Triple{two}, Removed: []tstore.Triple{one, three}},
+		{Version: 4, Removed: []tstore.Triple{two}},
+		{Version: 5, Added: []tstore.Triple{three}},
+	}
+	for _, exp := range expected {
+		select {
+		case evt := <-events:
+			if got, want := evt.Version, exp.Version; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			if got, want := tstore.Triples(evt.Added), tstore.Triples(exp.Added); !got.Equal(want) {
+				t.Fatalf("version %d: got %v, want %v", exp.Version, got, want)
+			}
+			if got, want := tstore.Triples(evt.Removed), tstore.Triples(exp.Removed); !got.Equal(want) {
+				t.Fatalf("version %d: got %v, want %v", exp.Version, got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("version %d: no event received", exp.Version)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("expected no more event")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected channel to be closed")
+	}
+}